@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker پس از چند خطای پیاپی، درخواست‌ها را تا پایان زمان استراحت فوراً رد می‌کند.
+// پس از آن یک درخواست آزمایشی عبور می‌کند؛ موفقیتش مدار را می‌بندد و شکستش دوباره بازش می‌کند.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow آیا درخواست جدید مجاز است؟
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	// نیمه‌باز: فقط یک درخواست آزمایشی
+	b.probing = true
+	return true
+}
+
+// Open آیا مدار در حال حاضر باز است؟ (بدون مصرف درخواست آزمایشی)
+func (b *circuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold && (time.Now().Before(b.openUntil) || b.probing)
+}
+
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// Release آزاد کردن درخواست آزمایشی بدون تغییر وضعیت مدار؛ برای درخواستی که اصلاً به سرویس نرسید یا لغو شد
+func (b *circuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache حافظه موقت پاسخ‌های تکراری؛ برای هر کلید چند پاسخ متفاوت نگه می‌دارد
+// و پس از پر شدن، به نوبت بین آن‌ها می‌چرخد تا پاسخ عیناً تکرار نشود.
+type Cache struct {
+	mu          sync.Mutex
+	maxVariants int
+	entries     map[string]*cacheEntry
+	hits        uint64
+	misses      uint64
+}
+
+type cacheEntry struct {
+	variants []string
+	next     int
+	expires  time.Time
+}
+
+func NewCache(maxVariants int) *Cache {
+	if maxVariants <= 0 {
+		maxVariants = 1
+	}
+	return &Cache{
+		maxVariants: maxVariants,
+		entries:     make(map[string]*cacheEntry),
+	}
+}
+
+// Get پاسخ بعدی برای کلید؛ تا وقتی تعداد پاسخ‌ها به سقف نرسیده، miss برمی‌گرداند تا پاسخ تازه ساخته شود
+func (c *Cache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok || len(entry.variants) < c.maxVariants {
+		c.misses++
+		return "", false
+	}
+
+	value := entry.variants[entry.next]
+	entry.next = (entry.next + 1) % len(entry.variants)
+	c.hits++
+	return value, true
+}
+
+// Add افزودن پاسخ تازه؛ زمان انقضا از اولین پاسخ کلید حساب می‌شود
+func (c *Cache) Add(key string, value string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expires) {
+		c.sweep(now)
+		entry = &cacheEntry{expires: now.Add(ttl)}
+		c.entries[key] = entry
+	}
+	if len(entry.variants) < c.maxVariants {
+		entry.variants = append(entry.variants, value)
+	}
+}
+
+// Stats تعداد hit و miss از زمان راه‌اندازی و تعداد کلیدهای ذخیره‌شده
+func (c *Cache) Stats() (hits uint64, misses uint64, entries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses, len(c.entries)
+}
+
+// sweep حذف کلیدهای منقضی‌شده (با قفل گرفته‌شده)
+func (c *Cache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheVariantRotation(t *testing.T) {
+	c := NewCache(3)
+
+	// تا پر شدن سقف پاسخ‌ها، هر Get باید miss باشد تا پاسخ تازه ساخته شود
+	for i, v := range []string{"a", "b", "c"} {
+		if _, ok := c.Get("k"); ok {
+			t.Fatalf("Get before %d variants should miss", i+1)
+		}
+		c.Add("k", v, time.Minute)
+	}
+	c.Add("k", "d", time.Minute) // بیش از سقف نادیده گرفته می‌شود
+
+	want := []string{"a", "b", "c", "a", "b"}
+	for i, w := range want {
+		got, ok := c.Get("k")
+		if !ok || got != w {
+			t.Fatalf("Get #%d = (%q, %v), want (%q, true)", i, got, ok, w)
+		}
+	}
+	if _, ok := c.Get("other"); ok {
+		t.Fatal("unknown key should miss")
+	}
+
+	hits, misses, entries := c.Stats()
+	if hits != 5 || misses != 4 || entries != 1 {
+		t.Fatalf("Stats() = (%d, %d, %d), want (5, 4, 1)", hits, misses, entries)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		wait    time.Duration
+		wantHit bool
+	}{
+		{"fresh", time.Minute, 0, true},
+		{"expired", 10 * time.Millisecond, 30 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCache(1)
+			c.Add("k", "v", tt.ttl)
+			time.Sleep(tt.wait)
+			if _, ok := c.Get("k"); ok != tt.wantHit {
+				t.Fatalf("Get hit = %v, want %v", ok, tt.wantHit)
+			}
+			if !tt.wantHit {
+				if _, _, entries := c.Stats(); entries != 0 {
+					t.Fatalf("expired entry not removed: %d entries", entries)
+				}
+				// پس از انقضا کلید از نو با پاسخ تازه پر می‌شود
+				c.Add("k", "new", time.Minute)
+				if got, ok := c.Get("k"); !ok || got != "new" {
+					t.Fatalf("Get after refill = (%q, %v), want (\"new\", true)", got, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheSweepOnAdd(t *testing.T) {
+	c := NewCache(1)
+	c.Add("old", "v", 10*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	c.Add("new", "v", time.Minute)
+	if _, _, entries := c.Stats(); entries != 1 {
+		t.Fatalf("entries = %d, want 1 after sweeping expired key", entries)
+	}
+}
@@ -0,0 +1,38 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+)
+
+// خطاهای پایه‌ای که دستورات با errors.Is می‌توانند از هم تشخیص دهند
+var (
+	// ErrQuota سهمیه یا اعتبار سرویس تمام شده یا محدودیت نرخ رسیده است
+	ErrQuota = errors.New("ai quota exceeded")
+	// ErrTimeout پاسخ سرویس در زمان مجاز نرسید
+	ErrTimeout = errors.New("ai request timed out")
+	// ErrContent درخواست یا پاسخ توسط فیلتر محتوا رد شد یا پاسخی تولید نشد
+	ErrContent = errors.New("ai content rejected")
+	// ErrRequest سرویس درخواست را نامعتبر دانست (پارامتر اشتباه، مدل ناموجود، کلید نامعتبر و ...)
+	ErrRequest = errors.New("ai request rejected")
+	// ErrUnavailable سرویس در دسترس نیست (خطای سرور یا باز بودن مدارشکن)
+	ErrUnavailable = errors.New("ai service unavailable")
+)
+
+// APIError خطای برگشتی از API همراه با کد وضعیت؛ Kind یکی از خطاهای پایه بالاست
+type APIError struct {
+	Kind       error
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%v: %s", e.Kind, e.Body)
+	}
+	return fmt.Sprintf("%v (HTTP %d): %s", e.Kind, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
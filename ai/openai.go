@@ -2,11 +2,18 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"redhat-bot/config"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type DeepSeekClient struct {
@@ -14,6 +21,11 @@ type DeepSeekClient struct {
 	refererURL string
 	siteTitle  string
 	client     *http.Client
+	model      string
+	maxTokens  int
+	timeout    time.Duration
+	maxRetries int
+	breaker    *circuitBreaker
 }
 
 type Message struct {
@@ -22,55 +34,191 @@ type Message struct {
 }
 
 type ChatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model     string    `json:"model"`
+	Messages  []Message `json:"messages"`
+	MaxTokens int       `json:"max_tokens,omitempty"`
+}
+
+// Usage مصرف توکن گزارش‌شده توسط سرویس
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type ChatResponse struct {
+	Model   string `json:"model"`
+	Usage   Usage  `json:"usage"`
 	Choices []struct {
 		Message struct {
 			Content string `json:"content"`
 		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
 }
 
+// Options تنظیمات اختیاری هر درخواست؛ مقادیر صفر یعنی پیش‌فرض کلاینت
+type Options struct {
+	Model     string
+	MaxTokens int
+}
+
+// Result پاسخ کامل یک درخواست همراه با مدل و مصرف توکن
+type Result struct {
+	Content string
+	Model   string
+	Usage   Usage
+}
+
+// حداکثر زمان انتظار بین تلاش‌ها (حتی اگر Retry-After بیشتر بگوید)
+const maxRetryWait = 30 * time.Second
+
 func NewDeepSeekClient() *DeepSeekClient {
+	timeout := time.Duration(config.AppConfig.AITimeoutSeconds) * time.Second
 	return &DeepSeekClient{
 		apiKey:     config.AppConfig.DeepSeekToken,
 		refererURL: "<YOUR_SITE_URL>",  // تنظیم از کانفیگ
 		siteTitle:  "<YOUR_SITE_NAME>", // تنظیم از کانفیگ
-		client:     &http.Client{},
+		// تایم‌اوت کلاینت پشتیبان تایم‌اوت هر درخواست است تا اتصال معلق هرگز گوروتین را قفل نکند
+		client:     &http.Client{Timeout: timeout + 5*time.Second},
+		model:      config.AppConfig.AIModel,
+		maxTokens:  config.AppConfig.AIMaxTokens,
+		timeout:    timeout,
+		maxRetries: config.AppConfig.AIMaxRetries,
+		breaker: newCircuitBreaker(
+			config.AppConfig.AIBreakerThreshold,
+			time.Duration(config.AppConfig.AIBreakerCooldownSeconds)*time.Second,
+		),
 	}
 }
 
+// Available آیا سرویس در دسترس است؟ (false یعنی مدارشکن باز است و درخواست فوراً رد می‌شود)
+func (d *DeepSeekClient) Available() bool {
+	return !d.breaker.Open()
+}
+
 func (d *DeepSeekClient) AskQuestion(question string) (string, error) {
-	messages := []Message{
-		{
-			Role:    "user",
-			Content: question,
-		},
+	return d.AskWithSystem("", question)
+}
+
+// AskWithSystem ارسال سوال به همراه پیام سیستمی (شخصیت/قوانین پاسخ‌گویی)
+func (d *DeepSeekClient) AskWithSystem(systemPrompt string, question string) (string, error) {
+	return d.Chat(systemPrompt, nil, question)
+}
+
+// Chat ارسال سوال به همراه پیام سیستمی و تاریخچه گفتگو (پیام‌های قبلی user/assistant)
+func (d *DeepSeekClient) Chat(systemPrompt string, history []Message, question string) (string, error) {
+	return d.ChatContext(context.Background(), systemPrompt, history, question)
+}
+
+// ChatContext مانند Chat با امکان لغو از طریق context
+func (d *DeepSeekClient) ChatContext(ctx context.Context, systemPrompt string, history []Message, question string) (string, error) {
+	result, err := d.Complete(ctx, Options{}, systemPrompt, history, question)
+	if err != nil {
+		return "", err
 	}
-	return d.makeRequest(messages)
+	return result.Content, nil
 }
 
-func (d *DeepSeekClient) makeRequest(messages []Message) (string, error) {
+// Complete ارسال درخواست با تنظیمات مدل و برگرداندن پاسخ به همراه مصرف توکن
+func (d *DeepSeekClient) Complete(ctx context.Context, opts Options, systemPrompt string, history []Message, question string) (*Result, error) {
+	var messages []Message
+	if systemPrompt != "" {
+		messages = append(messages, Message{
+			Role:    "system",
+			Content: systemPrompt,
+		})
+	}
+	messages = append(messages, history...)
+	messages = append(messages, Message{
+		Role:    "user",
+		Content: question,
+	})
+	return d.makeRequest(ctx, opts, messages)
+}
+
+// makeRequest ارسال درخواست با تلاش مجدد روی 429/5xx و رعایت مدارشکن
+func (d *DeepSeekClient) makeRequest(ctx context.Context, opts Options, messages []Message) (*Result, error) {
+	if !d.breaker.Allow() {
+		return nil, &APIError{Kind: ErrUnavailable, Body: "circuit breaker is open"}
+	}
+
 	requestBody := ChatRequest{
-		Model:    "deepseek/deepseek-r1-0528:free",
-		Messages: messages,
+		Model:     d.model,
+		Messages:  messages,
+		MaxTokens: d.maxTokens,
+	}
+	if opts.Model != "" {
+		requestBody.Model = opts.Model
+	}
+	if opts.MaxTokens > 0 {
+		requestBody.MaxTokens = opts.MaxTokens
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("خطا در تبدیل درخواست: %v", err)
+		d.breaker.Release()
+		return nil, fmt.Errorf("خطا در تبدیل درخواست: %v", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= d.maxRetries; attempt++ {
+		result, retryAfter, err := d.doRequest(ctx, jsonData)
+		if err == nil {
+			d.breaker.Success()
+			if result.Model == "" {
+				result.Model = requestBody.Model
+			}
+			return result, nil
+		}
+		lastErr = err
+
+		// لغو توسط فراخواننده: نه تلاش مجدد، نه تغییر وضعیت مدار (سلامت سرویس معلوم نشد)
+		if ctx.Err() != nil {
+			d.breaker.Release()
+			return nil, ctx.Err()
+		}
+		if !retryable(err) || attempt == d.maxRetries {
+			break
+		}
+
+		wait := backoff(attempt)
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if wait > maxRetryWait {
+			wait = maxRetryWait
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			d.breaker.Release()
+			return nil, ctx.Err()
+		}
 	}
 
-	req, err := http.NewRequest(
+	if errors.Is(lastErr, ErrContent) {
+		// سرویس سالم پاسخ داده؛ فقط محتوا رد شده است
+		d.breaker.Success()
+	} else {
+		d.breaker.Failure()
+	}
+	return nil, lastErr
+}
+
+// doRequest یک تلاش با تایم‌اوت مستقل؛ مقدار دوم زمان Retry-After اعلام‌شده است
+func (d *DeepSeekClient) doRequest(ctx context.Context, jsonData []byte) (*Result, time.Duration, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(
+		reqCtx,
 		"POST",
 		"https://openrouter.ai/api/v1/chat/completions",
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
-		return "", fmt.Errorf("خطا در ایجاد درخواست: %v", err)
+		return nil, 0, fmt.Errorf("خطا در ایجاد درخواست: %v", err)
 	}
 
 	// تنظیم هدرهای مورد نیاز
@@ -81,27 +229,119 @@ func (d *DeepSeekClient) makeRequest(messages []Message) (string, error) {
 
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("درخواست ناموفق بود: %v", err)
+		if isTimeout(err) {
+			return nil, 0, &APIError{Kind: ErrTimeout, Body: err.Error()}
+		}
+		return nil, 0, &APIError{Kind: ErrUnavailable, Body: err.Error()}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("خطا در خواندن پاسخ: %v", err)
+		if isTimeout(err) {
+			return nil, 0, &APIError{Kind: ErrTimeout, Body: err.Error()}
+		}
+		return nil, 0, &APIError{Kind: ErrUnavailable, Body: err.Error()}
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("خطای API: %s", body)
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &APIError{
+			Kind:       statusKind(resp.StatusCode, string(body)),
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+		}
 	}
 
 	var chatResp ChatResponse
 	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return "", fmt.Errorf("خطا در تجزیه پاسخ: %v", err)
+		return nil, 0, fmt.Errorf("خطا در تجزیه پاسخ: %v", err)
+	}
+
+	if len(chatResp.Choices) == 0 || chatResp.Choices[0].FinishReason == "content_filter" {
+		return nil, 0, &APIError{Kind: ErrContent, StatusCode: resp.StatusCode, Body: "پاسخی تولید نشد"}
+	}
+
+	return &Result{
+		Content: chatResp.Choices[0].Message.Content,
+		Model:   chatResp.Model,
+		Usage:   chatResp.Usage,
+	}, 0, nil
+}
+
+// statusKind دسته‌بندی کد وضعیت HTTP؛ رد محتوا فقط از روی متن خطای سرویس تشخیص داده می‌شود
+func statusKind(status int, body string) error {
+	switch {
+	case status == http.StatusTooManyRequests, status == http.StatusPaymentRequired:
+		return ErrQuota
+	case status == http.StatusRequestTimeout, status == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case (status == http.StatusBadRequest || status == http.StatusForbidden) && isContentRejection(body):
+		// OpenRouter درخواست‌های پرچم‌خورده توسط مدریشن را با 403 (و برخی مدل‌ها با 400) رد می‌کنند
+		return ErrContent
+	case status >= 400 && status < 500:
+		return ErrRequest
+	default:
+		return ErrUnavailable
 	}
+}
 
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("پاسخی تولید نشد")
+// contentRejectionMarkers نشانه‌های خطای فیلتر محتوا/ایمنی در بدنه پاسخ خطا
+var contentRejectionMarkers = []string{"content_filter", "content filter", "content_policy", "content policy", "moderation", "flagged", "safety"}
+
+func isContentRejection(body string) bool {
+	body = strings.ToLower(body)
+	for _, marker := range contentRejectionMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
 	}
+	return false
+}
 
-	return chatResp.Choices[0].Message.Content, nil
+// retryable فقط 429 و خطاهای سرور/شبکه دوباره تلاش می‌شوند
+func retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if apiErr.Kind == ErrUnavailable {
+		return apiErr.StatusCode == 0 || apiErr.StatusCode >= 500
+	}
+	return false
+}
+
+// backoff تأخیر نمایی با jitter کامل: تصادفی بین ۰ و 500ms*2^attempt
+func backoff(attempt int) time.Duration {
+	max := 500 * time.Millisecond << attempt
+	if max > 8*time.Second {
+		max = 8 * time.Second
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// parseRetryAfter پشتیبانی از هر دو فرمت ثانیه و تاریخ HTTP
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(t); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
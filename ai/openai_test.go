@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStatusKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"rate limit", 429, "", ErrQuota},
+		{"no credit", 402, "", ErrQuota},
+		{"gateway timeout", 504, "", ErrTimeout},
+		{"bad parameter", 400, `{"error":{"message":"max_tokens must be positive"}}`, ErrRequest},
+		{"unknown model", 404, `{"error":{"message":"No endpoints found"}}`, ErrRequest},
+		{"invalid key", 401, "", ErrRequest},
+		{"forbidden without reason", 403, `{"error":{"message":"Key disabled"}}`, ErrRequest},
+		{"moderation", 403, `{"error":{"message":"Input was flagged by moderation"}}`, ErrContent},
+		{"provider safety", 400, `{"error":{"code":"content_filter","message":"Safety check failed"}}`, ErrContent},
+		{"server error", 500, "", ErrUnavailable},
+		{"bad gateway", 502, "", ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusKind(tt.status, tt.body); !errors.Is(got, tt.want) {
+				t.Fatalf("statusKind(%d) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{Kind: ErrQuota, StatusCode: 429}, true},
+		{&APIError{Kind: ErrUnavailable, StatusCode: 503}, true},
+		{&APIError{Kind: ErrUnavailable}, true},
+		{&APIError{Kind: ErrRequest, StatusCode: 400}, false},
+		{&APIError{Kind: ErrContent, StatusCode: 403}, false},
+		{errors.New("plain"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
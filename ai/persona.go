@@ -0,0 +1,65 @@
+package ai
+
+// Persona یک شخصیت از پیش تعریف‌شده برای پاسخ‌های هوش مصنوعی
+type Persona struct {
+	Key    string
+	Title  string
+	Prompt string
+}
+
+// PersonaCustom کلید شخصیتی که از پرامپت سفارشی گروه استفاده می‌کند
+const PersonaCustom = "custom"
+
+// Personas شخصیت‌های قابل انتخاب در پنل گروه
+var Personas = []Persona{
+	{
+		Key:    "formal",
+		Title:  "🎩 دستیار رسمی",
+		Prompt: "تو دستیار هوشمند و مودب «کوو» هستی. پاسخ‌ها را دقیق، مختصر و با لحن رسمی بنویس و از شوخی و اصطلاحات عامیانه پرهیز کن.",
+	},
+	{
+		Key:    "funny",
+		Title:  "😜 رفیق شوخ",
+		Prompt: "تو «کوو» هستی، یک رفیق شوخ‌طبع و خودمونی. با لحن صمیمی و کمی شوخی جواب بده ولی اطلاعات درست و مفید بده و به کسی توهین نکن.",
+	},
+	{
+		Key:    "safe",
+		Title:  "👨‍👩‍👧 خانوادگی و سخت‌گیر",
+		Prompt: "تو «کوو» هستی، یک دستیار مناسب همه سنین. از هرگونه محتوای جنسی، خشونت‌آمیز، توهین‌آمیز یا ناسزا خودداری کن و اگر درخواستی نامناسب بود، مودبانه رد کن.",
+	},
+}
+
+// Languages زبان‌های قابل انتخاب برای پاسخ و دستور مربوط به هرکدام
+var Languages = map[string]string{
+	"fa":   "همیشه به زبان فارسی پاسخ بده.",
+	"en":   "Always answer in English.",
+	"auto": "به همان زبانی که کاربر نوشته پاسخ بده.",
+}
+
+// FindPersona جستجوی شخصیت بر اساس کلید
+func FindPersona(key string) (Persona, bool) {
+	for _, p := range Personas {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return Persona{}, false
+}
+
+// BuildSystemPrompt ساخت پیام سیستمی از شخصیت، پرامپت سفارشی و زبان پاسخ
+func BuildSystemPrompt(personaKey string, customPrompt string, language string) string {
+	prompt := ""
+	if personaKey == PersonaCustom {
+		prompt = customPrompt
+	} else if p, ok := FindPersona(personaKey); ok {
+		prompt = p.Prompt
+	}
+
+	if instruction, ok := Languages[language]; ok {
+		if prompt != "" {
+			prompt += "\n"
+		}
+		prompt += instruction
+	}
+	return prompt
+}
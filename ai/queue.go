@@ -0,0 +1,254 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull کاربر بیش از حد مجاز درخواست در صف دارد
+var ErrQueueFull = errors.New("ai queue is full for this user")
+
+// Ticket یک درخواست در صف؛ Context آن با لغو یا پایان کار بسته می‌شود
+type Ticket struct {
+	ID     uint64
+	UserID int64
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	ready   chan struct{}
+	started bool
+	done    bool
+}
+
+// Ready کانالی که با رسیدن نوبت اجرا بسته می‌شود
+func (t *Ticket) Ready() <-chan struct{} {
+	return t.ready
+}
+
+// Context کانتکست درخواست که باید به فراخوانی هوش مصنوعی داده شود
+func (t *Ticket) Context() context.Context {
+	return t.ctx
+}
+
+// Queue صف درخواست‌های هوش مصنوعی با سقف اجرای همزمان سراسری.
+// نوبت‌دهی بین کاربران چرخشی است تا یک کاربر پرکار بقیه را پشت سر نگه ندارد.
+type Queue struct {
+	mu         sync.Mutex
+	maxActive  int
+	maxPerUser int
+	active     int
+	nextID     uint64
+	pending    map[int64][]*Ticket // صف هر کاربر به ترتیب ورود
+	users      []int64             // ترتیب چرخشی کاربرانی که درخواست منتظر دارند
+	tickets    map[uint64]*Ticket
+}
+
+// NewQueue ساخت صف؛ maxPerUser=0 یعنی بدون سقف برای هر کاربر
+func NewQueue(maxActive int, maxPerUser int) *Queue {
+	if maxActive <= 0 {
+		maxActive = 1
+	}
+	return &Queue{
+		maxActive:  maxActive,
+		maxPerUser: maxPerUser,
+		pending:    make(map[int64][]*Ticket),
+		tickets:    make(map[uint64]*Ticket),
+	}
+}
+
+// Enqueue ثبت درخواست جدید در صف
+func (q *Queue) Enqueue(parent context.Context, userID int64) (*Ticket, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.maxPerUser > 0 && q.userTickets(userID) >= q.maxPerUser {
+		return nil, ErrQueueFull
+	}
+
+	q.nextID++
+	ctx, cancel := context.WithCancel(parent)
+	t := &Ticket{
+		ID:     q.nextID,
+		UserID: userID,
+		ctx:    ctx,
+		cancel: cancel,
+		ready:  make(chan struct{}),
+	}
+	q.tickets[t.ID] = t
+	if len(q.pending[userID]) == 0 {
+		q.users = append(q.users, userID)
+	}
+	q.pending[userID] = append(q.pending[userID], t)
+	q.dispatch()
+	return t, nil
+}
+
+// Wait تا رسیدن نوبت یا لغو درخواست صبر می‌کند
+func (q *Queue) Wait(t *Ticket) error {
+	select {
+	case <-t.ready:
+		return nil
+	case <-t.ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if t.started {
+			// همزمان با لغو نوبتش رسیده بود
+			return t.ctx.Err()
+		}
+		q.remove(t)
+		return t.ctx.Err()
+	}
+}
+
+// Done پایان کار درخواست و آزاد کردن جایگاه اجرا
+func (q *Queue) Done(t *Ticket) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if t.done {
+		return
+	}
+	t.done = true
+	t.cancel()
+	delete(q.tickets, t.ID)
+	if t.started {
+		q.active--
+	} else {
+		q.remove(t)
+	}
+	q.dispatch()
+}
+
+// Cancel لغو درخواست توسط صاحب آن؛ false یعنی درخواست پیدا نشد یا متعلق به کاربر دیگری است
+func (q *Queue) Cancel(id uint64, userID int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, ok := q.tickets[id]
+	if !ok || t.UserID != userID {
+		return false
+	}
+	t.cancel()
+	return true
+}
+
+// Position جایگاه درخواست در صف (از ۱)؛ ۰ یعنی در حال اجراست یا دیگر در صف نیست
+func (q *Queue) Position(t *Ticket) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if t.started || t.done {
+		return 0
+	}
+	index := -1
+	for i, p := range q.pending[t.UserID] {
+		if p == t {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return 0
+	}
+
+	// شبیه‌سازی نوبت‌دهی چرخشی: در هر دور از هر کاربر یک درخواست
+	position := 1
+	for _, userID := range q.users {
+		n := len(q.pending[userID])
+		if userID == t.UserID {
+			position += index
+			continue
+		}
+		if n > index {
+			// کاربران قبلی در همین دور هم یک نوبت جلوترند
+			position += index
+			if q.before(userID, t.UserID) {
+				position++
+			}
+		} else {
+			position += n
+		}
+	}
+	return position
+}
+
+// Stats تعداد درخواست‌های در حال اجرا و منتظر
+func (q *Queue) Stats() (active int, waiting int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, list := range q.pending {
+		waiting += len(list)
+	}
+	return q.active, waiting
+}
+
+// dispatch تا وقتی جایگاه خالی هست، نوبت را به نفر بعدی در چرخه می‌دهد (با قفل گرفته‌شده)
+func (q *Queue) dispatch() {
+	for q.active < q.maxActive && len(q.users) > 0 {
+		userID := q.users[0]
+		q.users = q.users[1:]
+
+		list := q.pending[userID]
+		t := list[0]
+		if len(list) > 1 {
+			q.pending[userID] = list[1:]
+			// کاربر به انتهای چرخه می‌رود
+			q.users = append(q.users, userID)
+		} else {
+			delete(q.pending, userID)
+		}
+
+		t.started = true
+		q.active++
+		close(t.ready)
+	}
+}
+
+// remove حذف درخواست منتظر از صف (با قفل گرفته‌شده)
+func (q *Queue) remove(t *Ticket) {
+	delete(q.tickets, t.ID)
+	list := q.pending[t.UserID]
+	for i, p := range list {
+		if p == t {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) > 0 {
+		q.pending[t.UserID] = list
+		return
+	}
+	delete(q.pending, t.UserID)
+	for i, userID := range q.users {
+		if userID == t.UserID {
+			q.users = append(q.users[:i], q.users[i+1:]...)
+			break
+		}
+	}
+}
+
+// userTickets تعداد درخواست‌های منتظر و در حال اجرای یک کاربر (با قفل گرفته‌شده)
+func (q *Queue) userTickets(userID int64) int {
+	count := 0
+	for _, t := range q.tickets {
+		if t.UserID == userID {
+			count++
+		}
+	}
+	return count
+}
+
+// before آیا کاربر a در چرخه جلوتر از کاربر b است؟ (با قفل گرفته‌شده)
+func (q *Queue) before(a int64, b int64) bool {
+	for _, userID := range q.users {
+		if userID == a {
+			return true
+		}
+		if userID == b {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func isReady(t *Ticket) bool {
+	select {
+	case <-t.Ready():
+		return true
+	default:
+		return false
+	}
+}
+
+func mustEnqueue(t *testing.T, q *Queue, userID int64) *Ticket {
+	t.Helper()
+	ticket, err := q.Enqueue(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("Enqueue(%d): %v", userID, err)
+	}
+	return ticket
+}
+
+func TestQueueActiveCap(t *testing.T) {
+	q := NewQueue(2, 0)
+	a := mustEnqueue(t, q, 1)
+	b := mustEnqueue(t, q, 2)
+	c := mustEnqueue(t, q, 3)
+
+	if !isReady(a) || !isReady(b) {
+		t.Fatal("first two tickets should start immediately")
+	}
+	if isReady(c) {
+		t.Fatal("third ticket must wait while the cap is reached")
+	}
+	if active, waiting := q.Stats(); active != 2 || waiting != 1 {
+		t.Fatalf("Stats() = (%d, %d), want (2, 1)", active, waiting)
+	}
+
+	q.Done(a)
+	if !isReady(c) {
+		t.Fatal("waiting ticket should start after a slot is freed")
+	}
+	q.Done(a) // تکرار Done نباید جایگاه اضافه آزاد کند
+	if active, _ := q.Stats(); active != 2 {
+		t.Fatalf("active = %d after duplicate Done, want 2", active)
+	}
+}
+
+func TestQueuePerUserCap(t *testing.T) {
+	q := NewQueue(1, 2)
+	first := mustEnqueue(t, q, 1)
+	mustEnqueue(t, q, 1)
+	if _, err := q.Enqueue(context.Background(), 1); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("third ticket of the same user: err = %v, want ErrQueueFull", err)
+	}
+	// کاربر دیگر محدود نمی‌شود
+	mustEnqueue(t, q, 2)
+
+	q.Done(first)
+	if _, err := q.Enqueue(context.Background(), 1); err != nil {
+		t.Fatalf("after Done the user should have room again: %v", err)
+	}
+}
+
+func TestQueueRoundRobinAndPosition(t *testing.T) {
+	q := NewQueue(1, 0)
+	a1 := mustEnqueue(t, q, 1)
+	a2 := mustEnqueue(t, q, 1)
+	a3 := mustEnqueue(t, q, 1)
+	b1 := mustEnqueue(t, q, 2)
+
+	tests := []struct {
+		name   string
+		ticket *Ticket
+		want   int
+	}{
+		{"running", a1, 0},
+		{"next of busy user", a2, 1},
+		{"other user jumps ahead of third request", b1, 2},
+		{"third request of busy user", a3, 3},
+	}
+	for _, tt := range tests {
+		if got := q.Position(tt.ticket); got != tt.want {
+			t.Errorf("%s: Position = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// ترتیب اجرا باید با جایگاه‌ها یکی باشد: a2، b1، a3
+	order := []*Ticket{a2, b1, a3}
+	running := a1
+	for i, next := range order {
+		q.Done(running)
+		if !isReady(next) {
+			t.Fatalf("step %d: expected ticket %d to start", i, next.ID)
+		}
+		for _, other := range order[i+1:] {
+			if isReady(other) {
+				t.Fatalf("step %d: ticket %d started out of turn", i, other.ID)
+			}
+		}
+		running = next
+	}
+	q.Done(running)
+	if active, waiting := q.Stats(); active != 0 || waiting != 0 {
+		t.Fatalf("Stats() = (%d, %d), want (0, 0)", active, waiting)
+	}
+}
+
+func TestQueueCancelWaiting(t *testing.T) {
+	q := NewQueue(1, 0)
+	running := mustEnqueue(t, q, 1)
+	waiting := mustEnqueue(t, q, 2)
+	after := mustEnqueue(t, q, 3)
+
+	if q.Cancel(waiting.ID, 99) {
+		t.Fatal("Cancel by another user must fail")
+	}
+	if !q.Cancel(waiting.ID, 2) {
+		t.Fatal("Cancel by owner should succeed")
+	}
+	if err := q.Wait(waiting); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait after cancel: err = %v, want context.Canceled", err)
+	}
+	if got := q.Position(after); got != 1 {
+		t.Fatalf("Position after cancel = %d, want 1", got)
+	}
+
+	q.Done(running)
+	if !isReady(after) {
+		t.Fatal("cancelled ticket must not take the freed slot")
+	}
+}
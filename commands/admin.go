@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"redhat-bot/storage"
 
@@ -13,22 +14,33 @@ import (
 type AdminCommand struct {
 	bot     *tgbotapi.BotAPI
 	storage *storage.MySQLStorage
-	// وضعیت موقت برای دریافت ورودی لینک جدید از ادمین‌ها (کلاینت خصوصی)
-	pendingAdd map[int64]bool // key: admin user id
+	ai      *AIService
+	// گفتگوی دریافت لینک جدید از ادمین‌ها (کلاینت خصوصی)
+	conversations *ConversationManager
 }
 
+// adminChannelFlow گفتگوی افزودن لینک عضویت اجباری
+const adminChannelFlow = "admin_channel"
+
+// adminPromptFlow گفتگوی دریافت متن جدید یک قالب پرامپت
+const adminPromptFlow = "admin_prompt"
+
 // لیست ادمین‌های مجاز
 var adminUsers = map[int64]string{
 	1234567890: "x",
 	2345678901: "y",
 }
 
-func NewAdminCommand(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage) *AdminCommand {
-	return &AdminCommand{
-		bot:        bot,
-		storage:    storage,
-		pendingAdd: make(map[int64]bool),
+func NewAdminCommand(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage, aiService *AIService, conversations *ConversationManager) *AdminCommand {
+	r := &AdminCommand{
+		bot:           bot,
+		storage:       storage,
+		ai:            aiService,
+		conversations: conversations,
 	}
+	conversations.Register(adminChannelFlow, 15*time.Minute, r.handleChannelLinkStep)
+	conversations.Register(adminPromptFlow, 15*time.Minute, r.handlePromptBodyStep)
+	return r
 }
 
 // بررسی اینکه آیا کاربر ادمین است یا نه
@@ -54,6 +66,9 @@ func (r *AdminCommand) GetAdminWelcome(userID int64) string {
 🛠️ *دستورات ادمین:*
 • /showusers - نمایش لیست تمام کاربران
 • /showgroups - نمایش لیست تمام گروه‌ها
+• /aiusage - گزارش مصرف توکن هوش مصنوعی
+• /aifeedback - گزارش رضایت از پاسخ‌های هوش مصنوعی
+• /prompts - قالب‌های پرامپت هوش مصنوعی
 • /admin - بازگشت به منوی ادمین
 
 ✨ از اینکه منو ساختی ممنونم! 💖`, name)
@@ -66,6 +81,9 @@ func (r *AdminCommand) GetAdminWelcome(userID int64) string {
 🛠️ *دستورات ادمین:*
 • /showusers - نمایش لیست تمام کاربران
 • /showgroups - نمایش لیست تمام گروه‌ها
+• /aiusage - گزارش مصرف توکن هوش مصنوعی
+• /aifeedback - گزارش رضایت از پاسخ‌های هوش مصنوعی
+• /prompts - قالب‌های پرامپت هوش مصنوعی
 • /admin - بازگشت به منوی ادمین
 
 ✨ آماده خدمت‌رسانی هستم! 💪`, name)
@@ -100,6 +118,12 @@ func (r *AdminCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📣 تبلیغات / عضویت اجباری", "admin_ads"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🤖 مصرف هوش مصنوعی", "admin_ai_usage"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👍 کیفیت پاسخ‌ها", "admin_ai_feedback"),
+		),
 	)
 
 	msg := tgbotapi.NewMessage(chatID, r.GetAdminWelcome(userID))
@@ -161,9 +185,14 @@ func (r *AdminCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackC
 		return tgbotapi.NewCallback(update.CallbackQuery.ID, "")
 
 	case data == "admin_ads_add":
-		r.pendingAdd[userID] = true
+		if err := r.conversations.Start(chatID, userID, adminChannelFlow, "link", nil); err != nil {
+			log.Printf("Error starting channel conversation: %v", err)
+			return tgbotapi.NewCallback(update.CallbackQuery.ID, "❌ خطا")
+		}
 		prompt := "لطفاً لینک کانال را ارسال کنید.\n\nفرمت‌های قابل قبول:\n• لینک عمومی: https://t.me/<username> | عنوان دلخواه\n• لینک خصوصی: https://t.me/+joincode | عنوان دلخواه\n(می‌توانید عنوان را ننویسید)"
-		r.bot.Send(tgbotapi.NewMessage(chatID, prompt))
+		msg := tgbotapi.NewMessage(chatID, prompt)
+		msg.ReplyMarkup = conversationCancelKeyboard()
+		r.bot.Send(msg)
 		return tgbotapi.NewCallback(update.CallbackQuery.ID, "")
 
 	case data == "admin_ads_del_menu":
@@ -202,6 +231,12 @@ func (r *AdminCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackC
 		r.bot.Send(tgbotapi.NewMessage(chatID, "✅ لینک حذف شد"))
 		return tgbotapi.NewCallback(update.CallbackQuery.ID, "")
 
+	case data == "admin_ai_usage":
+		r.bot.Send(tgbotapi.NewMessage(chatID, r.buildAIUsageReport()))
+
+	case data == "admin_ai_feedback":
+		r.bot.Send(tgbotapi.NewMessage(chatID, r.buildAIFeedbackReport()))
+
 	case data == "admin_showusers":
 		users, err := r.storage.GetAllUsers()
 		if err != nil {
@@ -312,22 +347,175 @@ func (r *AdminCommand) HandleShowGroups(update tgbotapi.Update) tgbotapi.Message
 	return msg
 }
 
-// HasPendingAdd آیا ادمین در حالت افزودن لینک است؟
-func (r *AdminCommand) HasPendingAdd(userID int64) bool {
-	return r.pendingAdd[userID]
+// HandleAIUsage command: گزارش مصرف توکن و بزرگ‌ترین مصرف‌کنندگان
+func (r *AdminCommand) HandleAIUsage(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	// فقط در چت خصوصی کار می‌کند
+	if update.Message.Chat.Type != "private" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در چت خصوصی با بات قابل استفاده است.")
+	}
+
+	// بررسی دسترسی ادمین
+	if !r.IsAdmin(userID) {
+		return tgbotapi.NewMessage(chatID, "❌ شما دسترسی ادمین ندارید.")
+	}
+
+	return tgbotapi.NewMessage(chatID, r.buildAIUsageReport())
+}
+
+// buildAIUsageReport مجموع مصرف امروز و این ماه به همراه ۱۰ کاربر و گروه پرمصرف این ماه
+func (r *AdminCommand) buildAIUsageReport() string {
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	today, err := r.storage.SumGlobalAITokens(dayStart)
+	if err != nil {
+		log.Printf("Error getting ai usage: %v", err)
+		return "❌ خطا در دریافت گزارش مصرف"
+	}
+	month, err := r.storage.SumGlobalAITokens(monthStart)
+	if err != nil {
+		log.Printf("Error getting ai usage: %v", err)
+		return "❌ خطا در دریافت گزارش مصرف"
+	}
+
+	var b strings.Builder
+	b.WriteString("🤖 گزارش مصرف هوش مصنوعی\n\n")
+	fmt.Fprintf(&b, "📅 امروز: %d توکن\n", today)
+	fmt.Fprintf(&b, "🗓️ این ماه: %d توکن\n", month)
+
+	hits, misses, entries := r.ai.CacheStats()
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = float64(hits) * 100 / float64(hits+misses)
+	}
+	if counts, err := r.storage.CountInlineChoices(monthStart); err != nil {
+		log.Printf("Error getting inline choices: %v", err)
+	} else if len(counts) > 0 {
+		b.WriteString("🔎 انتخاب‌های inline این ماه:")
+		for _, c := range counts {
+			fmt.Fprintf(&b, " %s: %d", c.Kind, c.Count)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "🗃️ کش پاسخ‌ها (از آخرین راه‌اندازی): %d hit | %d miss | %.0f%% | %d کلید\n", hits, misses, hitRate, entries)
+
+	users, err := r.storage.TopAIUsers(monthStart, 10)
+	if err != nil {
+		log.Printf("Error getting top ai users: %v", err)
+	}
+	b.WriteString("\n👤 پرمصرف‌ترین کاربران این ماه:\n")
+	if len(users) == 0 {
+		b.WriteString("—\n")
+	}
+	for i, u := range users {
+		fmt.Fprintf(&b, "%d. ID: %d | %d توکن | %d درخواست\n", i+1, u.ID, u.Tokens, u.Calls)
+	}
+
+	groups, err := r.storage.TopAIGroups(monthStart, 10)
+	if err != nil {
+		log.Printf("Error getting top ai groups: %v", err)
+	}
+	b.WriteString("\n🏢 پرمصرف‌ترین گروه‌های این ماه:\n")
+	if len(groups) == 0 {
+		b.WriteString("—\n")
+	}
+	for i, g := range groups {
+		fmt.Fprintf(&b, "%d. ID: %d | %d توکن | %d درخواست\n", i+1, g.ID, g.Tokens, g.Calls)
+	}
+	return b.String()
 }
 
-// HandlePrivateTextInput پردازش متن ارسالی خصوصی وقتی حالت افزودن فعال است
-func (r *AdminCommand) HandlePrivateTextInput(update tgbotapi.Update) tgbotapi.MessageConfig {
+// HandleAIFeedback command: گزارش رضایت کاربران از پاسخ‌های هوش مصنوعی
+func (r *AdminCommand) HandleAIFeedback(update tgbotapi.Update) tgbotapi.MessageConfig {
 	chatID := update.Message.Chat.ID
 	userID := update.Message.From.ID
-	text := strings.TrimSpace(update.Message.Text)
 
+	// فقط در چت خصوصی کار می‌کند
+	if update.Message.Chat.Type != "private" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در چت خصوصی با بات قابل استفاده است.")
+	}
+
+	// بررسی دسترسی ادمین
 	if !r.IsAdmin(userID) {
 		return tgbotapi.NewMessage(chatID, "❌ شما دسترسی ادمین ندارید.")
 	}
-	if !r.HasPendingAdd(userID) {
-		return tgbotapi.MessageConfig{}
+
+	return tgbotapi.NewMessage(chatID, r.buildAIFeedbackReport())
+}
+
+// buildAIFeedbackReport نرخ رضایت ۳۰ روز اخیر به تفکیک دستور و مدل، بدترین نسخه‌های قالب و بدترین پاسخ‌ها
+func (r *AdminCommand) buildAIFeedbackReport() string {
+	since := time.Now().AddDate(0, 0, -30)
+
+	stats, err := r.storage.AIFeedbackByCommand(since)
+	if err != nil {
+		log.Printf("Error getting ai feedback: %v", err)
+		return "❌ خطا در دریافت گزارش بازخورد"
+	}
+
+	var b strings.Builder
+	b.WriteString("👍 رضایت از پاسخ‌های هوش مصنوعی (۳۰ روز اخیر)\n\n")
+	if len(stats) == 0 {
+		b.WriteString("هنوز رأیی ثبت نشده است.")
+		return b.String()
+	}
+	for _, s := range stats {
+		model := s.Model
+		if model == "" {
+			model = "—"
+		}
+		fmt.Fprintf(&b, "• %s | %s: %s (👍 %d / 👎 %d)\n", s.Key, model, approvalRate(s.Up, s.Down), s.Up, s.Down)
+	}
+
+	versions, err := r.storage.WorstAIPromptVersions(since, 5, 5)
+	if err != nil {
+		log.Printf("Error getting worst prompt versions: %v", err)
+	}
+	if len(versions) > 0 {
+		b.WriteString("\n📝 کم‌رضایت‌ترین نسخه‌های قالب (حداقل ۵ رأی):\n")
+		for i, v := range versions {
+			fmt.Fprintf(&b, "%d. %s: %s (👍 %d / 👎 %d)\n", i+1, v.Key, approvalRate(v.Up, v.Down), v.Up, v.Down)
+		}
+	}
+
+	replies, err := r.storage.WorstAIReplies(since, 5)
+	if err != nil {
+		log.Printf("Error getting worst ai replies: %v", err)
+	}
+	if len(replies) > 0 {
+		b.WriteString("\n👎 بدترین پرامپت‌های کاربران:\n")
+		for i, reply := range replies {
+			input := []rune(reply.Input)
+			if len(input) > 60 {
+				input = append(input[:60], '…')
+			}
+			fmt.Fprintf(&b, "%d. [%s] «%s» (👍 %d / 👎 %d)\n", i+1, reply.Command, string(input), reply.Up, reply.Down)
+		}
+	}
+	return b.String()
+}
+
+// approvalRate درصد رأی‌های مثبت
+func approvalRate(up int64, down int64) string {
+	if up+down == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.0f%%", float64(up)*100/float64(up+down))
+}
+
+// handleChannelLinkStep پردازش لینک ارسالی ادمین در گفتگوی افزودن لینک
+func (r *AdminCommand) handleChannelLinkStep(update tgbotapi.Update, conv *Conversation) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+	text := strings.TrimSpace(update.Message.Text)
+
+	if !r.IsAdmin(userID) {
+		conv.Finish()
+		return tgbotapi.NewMessage(chatID, "❌ شما دسترسی ادمین ندارید.")
 	}
 
 	// پارس ورودی: "<link> | <title?>"
@@ -337,8 +525,8 @@ func (r *AdminCommand) HandlePrivateTextInput(update tgbotapi.Update) tgbotapi.M
 		link = strings.TrimSpace(text[:idx])
 		title = strings.TrimSpace(text[idx+1:])
 	}
-	if link == "" {
-		return tgbotapi.NewMessage(chatID, "❌ لینک نامعتبر است")
+	if link == "" || !strings.Contains(link, "t.me/") {
+		return tgbotapi.NewMessage(chatID, "❌ لینک نامعتبر است. یک لینک t.me بفرستید یا «لغو» را بزنید.")
 	}
 
 	// استخراج یوزرنیم کانال از لینک t.me
@@ -364,6 +552,117 @@ func (r *AdminCommand) HandlePrivateTextInput(update tgbotapi.Update) tgbotapi.M
 		return tgbotapi.NewMessage(chatID, "❌ خطا در ذخیره لینک")
 	}
 
-	delete(r.pendingAdd, userID)
+	conv.Finish()
 	return tgbotapi.NewMessage(chatID, "✅ لینک اضافه شد")
 }
+
+// HandlePrompts دستورات مدیریت قالب‌های پرامپت:
+// /prompts فهرست، /prompt <name> نمایش، /setprompt <name> [متن] جایگزینی، /resetprompt <name> بازگشت به فایل
+func (r *AdminCommand) HandlePrompts(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	// فقط در چت خصوصی کار می‌کند
+	if update.Message.Chat.Type != "private" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در چت خصوصی با بات قابل استفاده است.")
+	}
+
+	// بررسی دسترسی ادمین
+	if !r.IsAdmin(userID) {
+		return tgbotapi.NewMessage(chatID, "❌ شما دسترسی ادمین ندارید.")
+	}
+
+	prompts := r.ai.Prompts()
+	command := update.Message.Command()
+	args := strings.TrimSpace(update.Message.CommandArguments())
+	name, body, _ := strings.Cut(args, "\n")
+	name = strings.TrimSpace(name)
+	if fields := strings.Fields(name); len(fields) > 1 {
+		// «/setprompt name متن» در یک خط
+		name = fields[0]
+		body = strings.TrimSpace(strings.TrimPrefix(args, name))
+	}
+	body = strings.TrimSpace(body)
+
+	if command == "prompts" || name == "" {
+		var b strings.Builder
+		b.WriteString("📝 قالب‌های پرامپت:\n\n")
+		for _, p := range prompts.List() {
+			source := "📄"
+			if p.Overridden {
+				source = "✏️"
+			}
+			fmt.Fprintf(&b, "%s %s — %s\n", source, p.Name, p.Version)
+		}
+		b.WriteString("\n📄 فایل پیش‌فرض | ✏️ تنظیم‌شده توسط ادمین\n\n")
+		b.WriteString("• /prompt <name> - نمایش متن قالب\n")
+		b.WriteString("• /setprompt <name> - تغییر متن قالب (text/template)\n")
+		b.WriteString("• /resetprompt <name> - بازگشت به فایل پیش‌فرض")
+		return tgbotapi.NewMessage(chatID, b.String())
+	}
+
+	info, ok := prompts.Get(name)
+	if !ok {
+		return tgbotapi.NewMessage(chatID, "❌ قالبی با این نام وجود ندارد. فهرست: /prompts")
+	}
+
+	switch command {
+	case "setprompt":
+		if body != "" {
+			msg, _ := r.savePrompt(chatID, userID, name, body)
+			return msg
+		}
+		if err := r.conversations.Start(chatID, userID, adminPromptFlow, "body", map[string]string{"name": name}); err != nil {
+			log.Printf("Error starting prompt conversation: %v", err)
+			return tgbotapi.NewMessage(chatID, "❌ خطا")
+		}
+		msg := tgbotapi.NewMessage(chatID, "✏️ متن جدید قالب «"+name+"» را بفرستید.\n\nمتن فعلی:\n\n"+info.Body)
+		msg.ReplyMarkup = conversationCancelKeyboard()
+		return msg
+
+	case "resetprompt":
+		if !info.Overridden {
+			return tgbotapi.NewMessage(chatID, "ℹ️ این قالب از قبل از فایل پیش‌فرض خوانده می‌شود.")
+		}
+		if err := prompts.ResetOverride(name); err != nil {
+			log.Printf("Error resetting prompt override: %v", err)
+			return tgbotapi.NewMessage(chatID, "❌ خطا در بازگردانی قالب")
+		}
+		info, _ = prompts.Get(name)
+		return tgbotapi.NewMessage(chatID, "✅ قالب «"+name+"» به فایل پیش‌فرض برگشت.\nنسخه: "+info.Version)
+
+	default:
+		return tgbotapi.NewMessage(chatID, "📝 "+info.Name+" — "+info.Version+"\n\n"+info.Body)
+	}
+}
+
+// handlePromptBodyStep دریافت متن جدید قالب در گفتگوی /setprompt
+func (r *AdminCommand) handlePromptBodyStep(update tgbotapi.Update, conv *Conversation) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	if !r.IsAdmin(userID) {
+		conv.Finish()
+		return tgbotapi.NewMessage(chatID, "❌ شما دسترسی ادمین ندارید.")
+	}
+	body := strings.TrimSpace(update.Message.Text)
+	if body == "" {
+		return tgbotapi.NewMessage(chatID, "❌ متن قالب خالی است. متن را بفرستید یا «لغو» را بزنید.")
+	}
+
+	msg, saved := r.savePrompt(chatID, userID, conv.Data["name"], body)
+	if saved {
+		conv.Finish()
+	}
+	return msg
+}
+
+// savePrompt اعتبارسنجی و ذخیره متن سفارشی قالب؛ دوم: آیا ذخیره شد
+func (r *AdminCommand) savePrompt(chatID int64, userID int64, name string, body string) (tgbotapi.MessageConfig, bool) {
+	info, err := r.ai.Prompts().SetOverride(name, body, userID)
+	if err != nil {
+		log.Printf("Error saving prompt override: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ قالب نامعتبر است یا ذخیره نشد:\n"+err.Error()), false
+	}
+	return tgbotapi.NewMessage(chatID, "✅ قالب «"+name+"» ذخیره شد.\nنسخه جدید: "+info.Version), true
+}
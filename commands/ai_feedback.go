@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"redhat-bot/ai"
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// داده دکمه‌های بازخورد زیر پاسخ‌های هوش مصنوعی
+const (
+	feedbackUpData    = "fb_up"
+	feedbackDownData  = "fb_down"
+	feedbackRegenData = "fb_regen"
+)
+
+// AIAnswer پاسخ نهایی یک دستور هوش مصنوعی همراه با اطلاعات لازم برای ثبت و ارزیابی آن
+type AIAnswer struct {
+	Text      string
+	ParseMode string
+	// Rows دکمه‌های اختصاصی دستور؛ ردیف بازخورد خودکار به انتها اضافه می‌شود
+	Rows [][]tgbotapi.InlineKeyboardButton
+
+	UserID        int64
+	Command       string
+	Input         string       // ورودی خام کاربر (سوال، موضوع، حس و حال)
+	Question      string       // پرامپت ارسال‌شده؛ در ریپلای بعدی به‌عنوان تاریخچه استفاده می‌شود
+	Answer        string       // پاسخ خام مدل
+	History       []ai.Message // تاریخچه ارسال‌شده همراه سوال؛ برای ساخت دوباره پاسخ ذخیره می‌شود
+	PromptVersion string
+}
+
+// AIRegenerator ساخت دوباره پاسخ یک دستور از روی پاسخ ثبت‌شده قبلی؛ job صف‌شده است و باید با Run اجرا شود
+type AIRegenerator func(job *AIJob, prev *storage.AIReply) (AIAnswer, error)
+
+// RegisterRegenerator ثبت سازنده دوباره پاسخ برای دکمه «🔄 پاسخ دیگر» یک دستور
+func (s *AIService) RegisterRegenerator(command string, regenerator AIRegenerator) {
+	s.regenerators[command] = regenerator
+}
+
+// Deliver جایگزینی پیام پردازش با پاسخ نهایی و دکمه‌های بازخورد (یا ارسال پیام تازه) و ثبت پاسخ
+func (s *AIService) Deliver(job *AIJob, replyTo int, answer AIAnswer) {
+	_, canRegenerate := s.regenerators[answer.Command]
+	rows := append(answer.Rows, feedbackRow(0, 0, canRegenerate))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	messageID := 0
+	if job.MessageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(job.ChatID, job.MessageID, answer.Text, keyboard)
+		edit.ParseMode = answer.ParseMode
+		if _, err := s.bot.Send(edit); err == nil {
+			messageID = job.MessageID
+		} else {
+			log.Printf("خطا در ویرایش پیام: %v", err)
+		}
+	}
+	if messageID == 0 {
+		// اگر پیام پردازش ارسال یا ویرایش نشد، پیام جدید ارسال کن
+		msg := tgbotapi.NewMessage(job.ChatID, answer.Text)
+		msg.ParseMode = answer.ParseMode
+		msg.ReplyToMessageID = replyTo
+		msg.ReplyMarkup = keyboard
+		sent, err := s.bot.Send(msg)
+		if err != nil {
+			log.Printf("خطا در ارسال پاسخ: %v", err)
+			return
+		}
+		messageID = sent.MessageID
+	}
+
+	reply := &storage.AIReply{
+		ChatID:        job.ChatID,
+		MessageID:     messageID,
+		UserID:        answer.UserID,
+		Command:       answer.Command,
+		Input:         answer.Input,
+		Question:      answer.Question,
+		Answer:        answer.Answer,
+		Model:         job.Model,
+		PromptVersion: answer.PromptVersion,
+	}
+	if len(answer.History) > 0 {
+		if raw, err := json.Marshal(answer.History); err == nil {
+			reply.History = string(raw)
+		}
+	}
+	if err := s.storage.SaveAIReply(reply); err != nil {
+		log.Printf("Error saving ai reply: %v", err)
+	}
+}
+
+// replyHistory تاریخچه ذخیره‌شده یک پاسخ (nil اگر پاسخ بدون تاریخچه بوده است)
+func replyHistory(reply *storage.AIReply) []ai.Message {
+	if reply.History == "" {
+		return nil
+	}
+	var history []ai.Message
+	if err := json.Unmarshal([]byte(reply.History), &history); err != nil {
+		log.Printf("Error decoding ai reply history: %v", err)
+		return nil
+	}
+	return history
+}
+
+// HandleFeedbackCallback دکمه‌های 👍/👎 (ثبت یا پس گرفتن رأی) و «🔄 پاسخ دیگر» (فقط برای درخواست‌کننده)
+func (s *AIService) HandleFeedbackCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	if cq.Message == nil {
+		return tgbotapi.NewCallback(cq.ID, "")
+	}
+	chatID := cq.Message.Chat.ID
+
+	prev := s.FindReply(chatID, cq.Message.MessageID)
+	if prev == nil {
+		return tgbotapi.NewCallback(cq.ID, "این پاسخ دیگر قابل ارزیابی نیست")
+	}
+
+	if cq.Data == feedbackRegenData {
+		return s.regenerate(cq, prev)
+	}
+
+	vote := 1
+	if cq.Data == feedbackDownData {
+		vote = -1
+	}
+	current, err := s.storage.ToggleAIFeedback(prev, cq.From.ID, vote)
+	if err != nil {
+		log.Printf("Error saving ai feedback: %v", err)
+		return tgbotapi.NewCallback(cq.ID, "❌ خطا در ثبت رأی")
+	}
+
+	up, down, err := s.storage.CountAIFeedback(prev.ID)
+	if err != nil {
+		log.Printf("Error counting ai feedback: %v", err)
+	} else if cq.Message.ReplyMarkup != nil {
+		_, canRegenerate := s.regenerators[prev.Command]
+		markup := replaceFeedbackRow(*cq.Message.ReplyMarkup, feedbackRow(up, down, canRegenerate))
+		if _, err := s.bot.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, cq.Message.MessageID, markup)); err != nil {
+			log.Printf("Error updating feedback buttons: %v", err)
+		}
+	}
+
+	switch current {
+	case 1:
+		return tgbotapi.NewCallback(cq.ID, "👍 ممنون از بازخوردت!")
+	case -1:
+		return tgbotapi.NewCallback(cq.ID, "👎 ثبت شد؛ سعی می‌کنیم بهتر شویم")
+	default:
+		return tgbotapi.NewCallback(cq.ID, "رأی شما پس گرفته شد")
+	}
+}
+
+// regenerate ساخت پاسخ تازه برای همان ورودی، به‌صورت پیام جدید در ریپلای پاسخ قبلی
+func (s *AIService) regenerate(cq *tgbotapi.CallbackQuery, prev *storage.AIReply) tgbotapi.CallbackConfig {
+	regenerator, ok := s.regenerators[prev.Command]
+	if !ok {
+		return tgbotapi.NewCallback(cq.ID, "برای این پاسخ امکان ساخت دوباره وجود ندارد")
+	}
+	userID := cq.From.ID
+	if userID != prev.UserID {
+		return tgbotapi.NewCallback(cq.ID, "فقط درخواست‌کننده می‌تواند پاسخ دیگری بخواهد")
+	}
+	if allowed, message := s.rateLimiter.CheckRateLimit(userID); !allowed {
+		return tgbotapi.NewCallback(cq.ID, message)
+	}
+	if !s.Available() {
+		return tgbotapi.NewCallback(cq.ID, aiUnavailableText)
+	}
+
+	go func() {
+		chatID := prev.ChatID
+		job, err := s.Begin(chatID, userID, prev.MessageID)
+		if err != nil {
+			s.bot.Send(tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه ساخت پاسخ تازه ممکن نشد. لطفاً دوباره تلاش کنید.")))
+			return
+		}
+		answer, err := regenerator(job, prev)
+		if err != nil {
+			log.Printf("Error regenerating ai answer: %v", err)
+			if job.MessageID != 0 {
+				s.bot.Send(tgbotapi.NewDeleteMessage(chatID, job.MessageID))
+			}
+			s.bot.Send(tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه ساخت پاسخ تازه ممکن نشد. لطفاً دوباره تلاش کنید.")))
+			return
+		}
+		s.Deliver(job, prev.MessageID, answer)
+	}()
+	return tgbotapi.NewCallback(cq.ID, "🔄 در حال ساخت پاسخ تازه…")
+}
+
+// feedbackRow ردیف دکمه‌های 👍/👎 با تعداد رأی‌ها و در صورت امکان «🔄 پاسخ دیگر»
+func feedbackRow(up int64, down int64, canRegenerate bool) []tgbotapi.InlineKeyboardButton {
+	upText, downText := "👍", "👎"
+	if up > 0 {
+		upText += fmt.Sprintf(" %d", up)
+	}
+	if down > 0 {
+		downText += fmt.Sprintf(" %d", down)
+	}
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(upText, feedbackUpData),
+		tgbotapi.NewInlineKeyboardButtonData(downText, feedbackDownData),
+	)
+	if canRegenerate {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("🔄 پاسخ دیگر", feedbackRegenData))
+	}
+	return row
+}
+
+// replaceFeedbackRow جایگزینی ردیف بازخورد در کیبورد پیام بدون تغییر دکمه‌های دیگر
+func replaceFeedbackRow(markup tgbotapi.InlineKeyboardMarkup, row []tgbotapi.InlineKeyboardButton) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(markup.InlineKeyboard))
+	replaced := false
+	for _, r := range markup.InlineKeyboard {
+		if len(r) > 0 && r[0].CallbackData != nil && strings.HasPrefix(*r[0].CallbackData, "fb_") {
+			rows = append(rows, row)
+			replaced = true
+			continue
+		}
+		rows = append(rows, r)
+	}
+	if !replaced {
+		rows = append(rows, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -0,0 +1,369 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"redhat-bot/ai"
+	"redhat-bot/config"
+	"redhat-bot/limiter"
+	"redhat-bot/storage"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// کلیدهای تنظیمات هوش مصنوعی هر گروه در GroupSetting
+const (
+	settingAIPersona      = "ai_persona"
+	settingAICustomPrompt = "ai_custom_prompt"
+	settingAILanguage     = "ai_language"
+	settingAITriggerName  = "ai_trigger_name"
+)
+
+// featureAIChat قابلیت گفتگو با ربات بدون اسلش (منشن، نام یا ریپلای)
+const featureAIChat = "ai_chat"
+
+// defaultAITriggerName نامی که در صورت تنظیم نشدن، ربات با آن صدا زده می‌شود
+const defaultAITriggerName = "کوو"
+
+// AIService نقطه مشترک ارسال درخواست به هوش مصنوعی برای دستورات /covo، /cj و /music
+type AIService struct {
+	client      *ai.DeepSeekClient
+	storage     *storage.MySQLStorage
+	budget      *limiter.TokenBudget
+	rateLimiter *limiter.RateLimiter
+	queue       *ai.Queue
+	cache       *ai.Cache
+	prompts     *PromptLibrary
+	bot         *tgbotapi.BotAPI
+	// regenerators ساخت دوباره پاسخ هر دستور برای دکمه «🔄 پاسخ دیگر»
+	regenerators map[string]AIRegenerator
+}
+
+// AIJob یک درخواست صف‌شده همراه با پیام «درحال پردازش» آن
+type AIJob struct {
+	ChatID    int64
+	MessageID int    // ۰ اگر ارسال پیام پردازش ناموفق بود
+	Model     string // مدلی که پاسخ را ساخت (پس از Run)؛ cache برای پاسخ کش‌شده
+	ticket    *ai.Ticket
+}
+
+// AIRequest یک درخواست هوش مصنوعی از طرف یک کاربر در یک چت
+type AIRequest struct {
+	ChatID  int64
+	UserID  int64
+	Command string // covo, cj, music, ...
+	Prompt  string
+	History []ai.Message
+	// SessionID جلسه گفتگوی خصوصی؛ توکن‌های مصرفی به آن اضافه می‌شود (۰ = بدون جلسه)
+	SessionID uint
+	// System پیام سیستمی اختصاصی (مثلاً مترجم)؛ اگر خالی باشد شخصیت تنظیم‌شده چت استفاده می‌شود
+	System string
+	// PromptVersion نسخه قالب(های) پرامپت که همراه مصرف توکن ثبت می‌شود
+	PromptVersion string
+	// Fresh پاسخ کش‌شده برنگردان (مثلاً برای ساخت دوباره پاسخ)
+	Fresh bool
+	// CacheKey اگر خالی نباشد به‌جای Prompt کلید کش است (مثلاً فقط حس و حال، بدون فهرست آهنگ‌های هر کاربر)
+	CacheKey string
+	// AcceptCached اگر تنظیم شده باشد، پاسخ کش‌شده‌ای که رد کند کنار گذاشته و درخواست تازه ارسال می‌شود
+	AcceptCached func(cached string) bool
+}
+
+// budgetError وقتی بودجه توکن کاربر/گروه/ربات تمام شده باشد
+type budgetError struct {
+	message string
+}
+
+func (e *budgetError) Error() string {
+	return e.message
+}
+
+func NewAIService(client *ai.DeepSeekClient, storage *storage.MySQLStorage, budget *limiter.TokenBudget, rateLimiter *limiter.RateLimiter, queue *ai.Queue, cache *ai.Cache, prompts *PromptLibrary, bot *tgbotapi.BotAPI) *AIService {
+	return &AIService{
+		client:      client,
+		storage:     storage,
+		budget:      budget,
+		rateLimiter: rateLimiter,
+		queue:       queue,
+		cache:       cache,
+		prompts:     prompts,
+		bot:         bot,
+
+		regenerators: make(map[string]AIRegenerator),
+	}
+}
+
+// Prompt ساخت متن پرامپت از قالب name؛ دوم: نسخه قالب برای AIRequest.PromptVersion
+func (s *AIService) Prompt(name string, data map[string]any) (string, string, error) {
+	return s.prompts.Render(name, data)
+}
+
+// Prompts کتابخانه قالب‌های پرامپت (برای مدیریت توسط ادمین)
+func (s *AIService) Prompts() *PromptLibrary {
+	return s.prompts
+}
+
+// SystemPrompt پیام سیستمی تنظیم‌شده برای یک چت را برمی‌گرداند
+func (s *AIService) SystemPrompt(chatID int64) string {
+	persona, err := s.storage.GetGroupSetting(chatID, settingAIPersona)
+	if err != nil {
+		log.Printf("Error reading ai persona: %v", err)
+		return ""
+	}
+	customPrompt, err := s.storage.GetGroupSetting(chatID, settingAICustomPrompt)
+	if err != nil {
+		log.Printf("Error reading ai custom prompt: %v", err)
+	}
+	language, err := s.storage.GetGroupSetting(chatID, settingAILanguage)
+	if err != nil {
+		log.Printf("Error reading ai language: %v", err)
+	}
+	return ai.BuildSystemPrompt(persona, customPrompt, language)
+}
+
+// lookup پیام سیستمی درخواست و کلید کش آن؛ اگر پاسخ در کش باشد ok=true
+func (s *AIService) lookup(req AIRequest) (systemPrompt string, key string, cached string, ok bool) {
+	systemPrompt = req.System
+	if systemPrompt == "" {
+		systemPrompt = s.SystemPrompt(req.ChatID)
+	}
+	// درخواست‌های دارای تاریخچه (گفتگو) کش نمی‌شوند
+	if len(req.History) > 0 || req.SessionID != 0 || cacheTTL(req.Command) <= 0 {
+		return systemPrompt, "", "", false
+	}
+	input := req.Prompt
+	if req.CacheKey != "" {
+		input = req.CacheKey
+	}
+	sum := sha256.Sum256([]byte(systemPrompt + "\x00" + strings.ToLower(normalizePersian(input))))
+	key = req.Command + ":" + hex.EncodeToString(sum[:])
+	if req.Fresh {
+		return systemPrompt, key, "", false
+	}
+	cached, ok = s.cache.Get(key)
+	return systemPrompt, key, cached, ok
+}
+
+// complete فراخوانی واقعی سرویس؛ فقط همین مسیر در سهمیه درخواست کاربر شمرده می‌شود. دوم: مدل پاسخ‌دهنده
+func (s *AIService) complete(ctx context.Context, req AIRequest, systemPrompt string, key string) (string, string, error) {
+	groupID := int64(0)
+	if req.ChatID < 0 {
+		groupID = req.ChatID
+	}
+
+	plan := s.budget.Plan(req.UserID, groupID)
+	if !plan.Allowed {
+		return "", "", &budgetError{message: plan.Message}
+	}
+	opts := ai.Options{}
+	if plan.Degraded {
+		opts.Model = plan.Model
+		opts.MaxTokens = plan.MaxTokens
+	}
+
+	// درخواست‌های خودکار ربات (بدون کاربر) در سهمیه کسی شمرده نمی‌شوند
+	if req.UserID != 0 {
+		s.rateLimiter.IncrementUsage(req.UserID)
+	}
+	result, err := s.client.Complete(ctx, opts, systemPrompt, req.History, req.Prompt)
+	if err != nil {
+		return "", "", err
+	}
+	if key != "" {
+		s.cache.Add(key, result.Content, cacheTTL(req.Command))
+	}
+	s.budget.Record(req.UserID, groupID, req.Command, result.Model, result.Usage.PromptTokens, result.Usage.CompletionTokens, req.PromptVersion)
+	if req.SessionID != 0 {
+		if err := s.storage.AddAISessionTokens(req.SessionID, result.Usage.PromptTokens+result.Usage.CompletionTokens); err != nil {
+			log.Printf("Error saving session tokens: %v", err)
+		}
+	}
+	return result.Content, result.Model, nil
+}
+
+// Enqueue ثبت درخواست در صف بدون پیام «درحال پردازش» (مثلاً برای حالت inline)
+func (s *AIService) Enqueue(chatID int64, userID int64) (*AIJob, error) {
+	ticket, err := s.queue.Enqueue(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+	return &AIJob{ChatID: chatID, ticket: ticket}, nil
+}
+
+// Begin ثبت درخواست در صف و ارسال پیام «درحال پردازش» با جایگاه صف و دکمه لغو
+func (s *AIService) Begin(chatID int64, userID int64, replyTo int) (*AIJob, error) {
+	job, err := s.Enqueue(chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	ticket := job.ticket
+
+	position := s.queue.Position(ticket)
+	msg := tgbotapi.NewMessage(chatID, processingText(position))
+	msg.ReplyToMessageID = replyTo
+	msg.ReplyMarkup = cancelKeyboard(ticket.ID)
+	sent, err := s.bot.Send(msg)
+	if err != nil {
+		log.Printf("خطا در ارسال پیام پردازش: %v", err)
+		return job, nil
+	}
+	job.MessageID = sent.MessageID
+
+	if position > 0 {
+		go s.trackPosition(job, position)
+	}
+	return job, nil
+}
+
+// Run صبر تا رسیدن نوبت و سپس ارسال درخواست؛ در پایان جایگاه صف آزاد می‌شود
+func (s *AIService) Run(job *AIJob, req AIRequest) (string, error) {
+	defer s.queue.Done(job.ticket)
+
+	// پاسخ کش‌شده منتظر نوبت صف نمی‌ماند
+	systemPrompt, key, cached, ok := s.lookup(req)
+	if ok && (req.AcceptCached == nil || req.AcceptCached(cached)) {
+		job.Model = "cache"
+		return cached, nil
+	}
+	if err := s.queue.Wait(job.ticket); err != nil {
+		return "", err
+	}
+	content, model, err := s.complete(job.ticket.Context(), req, systemPrompt, key)
+	job.Model = model
+	return content, err
+}
+
+// CacheStats آمار کش پاسخ‌ها برای گزارش ادمین
+func (s *AIService) CacheStats() (hits uint64, misses uint64, entries int) {
+	return s.cache.Stats()
+}
+
+// cacheTTL مدت نگهداری پاسخ هر دستور در کش؛ ۰ یعنی کش نشود
+func cacheTTL(command string) time.Duration {
+	switch command {
+	case "cj":
+		return time.Duration(config.AppConfig.AICacheJokeTTLMinutes) * time.Minute
+	case "music":
+		return time.Duration(config.AppConfig.AICacheMusicTTLMinutes) * time.Minute
+	default:
+		return 0
+	}
+}
+
+// trackPosition به‌روزرسانی جایگاه صف در پیام پردازش تا زمان شروع یا لغو درخواست
+func (s *AIService) trackPosition(job *AIJob, shown int) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-job.ticket.Ready():
+			s.editProcessing(job, 0)
+			return
+		case <-job.ticket.Context().Done():
+			return
+		case <-ticker.C:
+			if position := s.queue.Position(job.ticket); position > 0 && position != shown {
+				shown = position
+				s.editProcessing(job, position)
+			}
+		}
+	}
+}
+
+func (s *AIService) editProcessing(job *AIJob, position int) {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(job.ChatID, job.MessageID, processingText(position), cancelKeyboard(job.ticket.ID))
+	if _, err := s.bot.Send(edit); err != nil {
+		log.Printf("خطا در به‌روزرسانی پیام صف: %v", err)
+	}
+}
+
+// HandleCancelCallback دکمه «لغو» (ai_cancel:<id>)؛ فقط درخواست‌کننده می‌تواند لغو کند
+func (s *AIService) HandleCancelCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	var id uint64
+	if _, err := fmt.Sscanf(cq.Data, "ai_cancel:%d", &id); err != nil {
+		return tgbotapi.NewCallback(cq.ID, "شناسه نامعتبر")
+	}
+	if !s.queue.Cancel(id, cq.From.ID) {
+		return tgbotapi.NewCallback(cq.ID, "فقط درخواست‌کننده می‌تواند درخواست در جریان را لغو کند")
+	}
+	return tgbotapi.NewCallback(cq.ID, "درخواست لغو شد")
+}
+
+// processingText متن پیام پردازش؛ position=0 یعنی درخواست در حال اجراست
+func processingText(position int) string {
+	if position == 0 {
+		return "درحال پردازش - کمی شکیبا باشید ✨"
+	}
+	return fmt.Sprintf("⏳ در صف پردازش — نوبت شما: %d\nکمی شکیبا باشید ✨", position)
+}
+
+func cancelKeyboard(id uint64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("لغو", fmt.Sprintf("ai_cancel:%d", id)),
+		),
+	)
+}
+
+// Available آیا سرویس هوش مصنوعی در دسترس است؟ اگر نه، باید فوراً پیام عدم دسترسی داد
+func (s *AIService) Available() bool {
+	return s.client.Available()
+}
+
+// FindReply پاسخ هوش مصنوعی ثبت‌شده برای یک پیام ربات (یا nil)
+func (s *AIService) FindReply(chatID int64, messageID int) *storage.AIReply {
+	reply, err := s.storage.GetAIReply(chatID, messageID)
+	if err != nil {
+		log.Printf("Error reading ai reply: %v", err)
+		return nil
+	}
+	return reply
+}
+
+// TriggerName نامی که اعضای گروه ربات را با آن صدا می‌زنند
+func (s *AIService) TriggerName(chatID int64) string {
+	name, err := s.storage.GetGroupSetting(chatID, settingAITriggerName)
+	if err != nil || name == "" {
+		return defaultAITriggerName
+	}
+	return name
+}
+
+// ChatEnabled آیا گفتگوی بدون اسلش در این گروه فعال است؟
+func (s *AIService) ChatEnabled(chatID int64) bool {
+	enabled, err := s.storage.IsFeatureEnabled(chatID, featureAIChat)
+	return err == nil && enabled
+}
+
+// aiUnavailableText پیام فوری وقتی مدارشکن باز است
+const aiUnavailableText = "⚠️ هوش مصنوعی موقتاً در دسترس نیست. لطفاً چند دقیقه دیگر دوباره تلاش کنید."
+
+// aiErrorText پیام مناسب کاربر بر اساس نوع خطای هوش مصنوعی؛ در غیر این صورت fallback
+func aiErrorText(err error, fallback string) string {
+	var budgetErr *budgetError
+	switch {
+	case errors.As(err, &budgetErr):
+		return budgetErr.message
+	case errors.Is(err, context.Canceled):
+		return "✖️ درخواست لغو شد."
+	case errors.Is(err, ai.ErrQueueFull):
+		return "⏳ درخواست قبلی شما هنوز در صف است. لطفاً تا پایان آن صبر کنید."
+	case errors.Is(err, ai.ErrUnavailable):
+		return aiUnavailableText
+	case errors.Is(err, ai.ErrQuota):
+		return "⏳ سقف درخواست‌های سرویس هوش مصنوعی فعلاً پر شده است. لطفاً کمی بعد دوباره تلاش کنید."
+	case errors.Is(err, ai.ErrTimeout):
+		return "⌛ پاسخ هوش مصنوعی بیش از حد طول کشید. لطفاً دوباره تلاش کنید یا سوال را کوتاه‌تر بپرسید."
+	case errors.Is(err, ai.ErrContent):
+		return "🚫 این درخواست توسط فیلتر محتوای سرویس هوش مصنوعی پذیرفته نشد. لطفاً آن را به شکل دیگری بیان کنید."
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,403 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// تنظیمات ضد اسپم در GroupSetting هر گروه
+const (
+	featureAntiFlood = "antiflood"
+
+	settingFloodLimit       = "flood_limit"        // حداکثر پیام در بازه
+	settingFloodWindow      = "flood_window"       // طول بازه به ثانیه
+	settingFloodRepeat      = "flood_repeat"       // تعداد پیام تکراری پشت‌سرهم؛ ۰ = خاموش
+	settingFloodAction      = "flood_action"       // floodActionDelete / Mute / Warn
+	settingFloodMuteMinutes = "flood_mute_minutes" // مدت سکوت در حالت floodActionMute
+)
+
+// برخورد با کاربری که از حد مجاز عبور کرده است
+const (
+	floodActionDelete = iota
+	floodActionMute
+	floodActionWarn
+)
+
+// floodOptions مقادیر قابل انتخاب هر تنظیم در پنل؛ اولین مقدار پیش‌فرض است
+var floodOptions = map[string][]int{
+	settingFloodLimit:       {8, 10, 15, 20, 5},
+	settingFloodWindow:      {10, 20, 30, 60, 5},
+	settingFloodRepeat:      {3, 4, 5, 0, 2},
+	settingFloodAction:      {floodActionDelete, floodActionMute, floodActionWarn},
+	settingFloodMuteMinutes: {10, 30, 60, 360, 1440},
+}
+
+var floodActionNames = map[int]string{
+	floodActionDelete: "فقط حذف",
+	floodActionMute:   "سکوت",
+	floodActionWarn:   "اخطار",
+}
+
+// floodKey کاربر در یک گروه
+type floodKey struct {
+	chatID int64
+	userID int64
+}
+
+// floodState پیام‌های اخیر یک کاربر در یک گروه
+type floodState struct {
+	times      []time.Time
+	messageIDs []int
+	lastText   string
+	lastAt     time.Time // زمان آخرین پیام یکسان؛ تکرارهای خارج از بازه شمرده نمی‌شوند
+	repeats    int
+	punishedAt time.Time // زمان آخرین برخورد؛ تا پایان همان بازه فقط پیام‌ها حذف می‌شوند
+}
+
+// recordRepeat ثبت محتوای پیام و تعداد پیام‌های یکسان پشت‌سرهم در بازه window
+func (s *floodState) recordRepeat(content string, now time.Time, window time.Duration) int {
+	if content != "" && content == s.lastText && now.Sub(s.lastAt) <= window {
+		s.repeats++
+	} else {
+		s.lastText = content
+		s.repeats = 1
+	}
+	s.lastAt = now
+	return s.repeats
+}
+
+// floodConfigTTL مدت اعتبار تنظیمات کش‌شده هر گروه؛ تغییر از پنل کش را فوراً باطل می‌کند
+const floodConfigTTL = 5 * time.Minute
+
+// floodConfig تنظیمات ضد اسپم یک گروه (کش‌شده تا برای هر پیام از پایگاه داده خوانده نشود)
+type floodConfig struct {
+	enabled     bool
+	limit       int
+	window      time.Duration
+	repeatLimit int
+	loadedAt    time.Time
+}
+
+// FloodGuard تشخیص ارسال پیام پشت‌سرهم و پیام تکراری و برخورد خودکار با آن (ادمین‌ها معاف هستند)
+type FloodGuard struct {
+	bot        *tgbotapi.BotAPI
+	storage    *storage.MySQLStorage
+	moderation *ModerationCommand
+
+	mu        sync.Mutex
+	members   map[floodKey]*floodState
+	configs   map[int64]floodConfig
+	lastSweep time.Time
+}
+
+func NewFloodGuard(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage, moderation *ModerationCommand) *FloodGuard {
+	return &FloodGuard{
+		bot:        bot,
+		storage:    storage,
+		moderation: moderation,
+		members:    make(map[floodKey]*floodState),
+		configs:    make(map[int64]floodConfig),
+		lastSweep:  time.Now(),
+	}
+}
+
+// config تنظیمات گروه از کش یا پایگاه داده
+func (f *FloodGuard) config(chatID int64, now time.Time) floodConfig {
+	f.mu.Lock()
+	cfg, ok := f.configs[chatID]
+	f.mu.Unlock()
+	if ok && now.Sub(cfg.loadedAt) < floodConfigTTL {
+		return cfg
+	}
+
+	enabled, err := f.storage.IsFeatureEnabled(chatID, featureAntiFlood)
+	if err != nil {
+		// خطای گذرا کش نمی‌شود
+		return floodConfig{}
+	}
+	cfg = floodConfig{enabled: enabled, loadedAt: now}
+	if enabled {
+		cfg.limit = groupIntSetting(f.storage, chatID, settingFloodLimit, floodOptions[settingFloodLimit])
+		cfg.window = time.Duration(groupIntSetting(f.storage, chatID, settingFloodWindow, floodOptions[settingFloodWindow])) * time.Second
+		cfg.repeatLimit = groupIntSetting(f.storage, chatID, settingFloodRepeat, floodOptions[settingFloodRepeat])
+	}
+	f.mu.Lock()
+	f.configs[chatID] = cfg
+	f.mu.Unlock()
+	return cfg
+}
+
+func (f *FloodGuard) invalidate(chatID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.configs, chatID)
+}
+
+// Check ثبت پیام در پنجره زمانی کاربر؛ true یعنی پیام به‌عنوان اسپم حذف شد و نباید پردازش شود
+func (f *FloodGuard) Check(message *tgbotapi.Message) bool {
+	if message.From == nil || message.From.IsBot {
+		return false
+	}
+	chatID := message.Chat.ID
+	now := time.Now()
+	cfg := f.config(chatID, now)
+	if !cfg.enabled {
+		return false
+	}
+	limit, window, repeatLimit := cfg.limit, cfg.window, cfg.repeatLimit
+
+	key := floodKey{chatID: chatID, userID: message.From.ID}
+
+	f.mu.Lock()
+	f.sweep(now)
+	state, ok := f.members[key]
+	if !ok {
+		state = &floodState{}
+		f.members[key] = state
+	}
+	// حذف پیام‌های خارج از بازه
+	keep := 0
+	for i, t := range state.times {
+		if now.Sub(t) <= window {
+			state.times[keep] = t
+			state.messageIDs[keep] = state.messageIDs[i]
+			keep++
+		}
+	}
+	state.times = append(state.times[:keep], now)
+	state.messageIDs = append(state.messageIDs[:keep], message.MessageID)
+
+	content := floodContent(message)
+	repeats := state.recordRepeat(content, now, window)
+
+	flooding := len(state.times) > limit
+	repeating := repeatLimit > 0 && content != "" && repeats >= repeatLimit
+	if !flooding && !repeating {
+		f.mu.Unlock()
+		return false
+	}
+	alreadyPunished := now.Sub(state.punishedAt) <= window
+	var recent []int
+	if !alreadyPunished {
+		state.punishedAt = now
+		recent = append(recent, state.messageIDs...)
+	}
+	f.mu.Unlock()
+
+	// ادمین‌ها معاف هستند؛ فقط هنگام عبور از حد بررسی می‌شود تا برای هر پیام درخواست اضافه ارسال نشود
+	if isAdmin, err := f.moderation.isUserAdmin(chatID, message.From.ID); err != nil || isAdmin {
+		if err != nil {
+			log.Printf("getChatMember error (antiflood): %v", err)
+		}
+		f.reset(key)
+		return false
+	}
+
+	if alreadyPunished {
+		_, _ = f.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: message.MessageID})
+		return true
+	}
+	// در اولین برخورد، پیام‌های همان بازه هم حذف می‌شوند
+	for _, id := range recent {
+		_, _ = f.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: id})
+	}
+
+	reason := "ارسال پیام پشت‌سرهم"
+	if repeating && !flooding {
+		reason = "ارسال پیام تکراری"
+	}
+	f.punish(message, reason, len(recent))
+	return true
+}
+
+// punish ثبت حذف پیام‌ها و اجرای برخورد تنظیم‌شده گروه
+func (f *FloodGuard) punish(message *tgbotapi.Message, reason string, deleted int) {
+	chatID, user := message.Chat.ID, message.From
+	action := ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: message.Chat.Title,
+		Target:    user,
+		Action:    modActionDelete,
+		Reason:    fmt.Sprintf("%s (%d پیام)", reason, deleted),
+		Excerpt:   messageExcerpt(message),
+		Source:    modSourceAntiFlood,
+	}
+	f.moderation.Record(action)
+
+	var text string
+	switch groupIntSetting(f.storage, chatID, settingFloodAction, floodOptions[settingFloodAction]) {
+	case floodActionMute:
+		minutes := groupIntSetting(f.storage, chatID, settingFloodMuteMinutes, floodOptions[settingFloodMuteMinutes])
+		until := time.Now().Add(time.Duration(minutes) * time.Minute)
+		if err := f.moderation.mute(chatID, user.ID, until.Unix()); err != nil {
+			log.Printf("restrictChatMember (antiflood) error: %v", err)
+			return
+		}
+		action.Action = modActionMute
+		action.Reason = reason
+		action.Duration = time.Duration(minutes) * time.Minute
+		f.moderation.Record(action)
+		text = fmt.Sprintf("🌊 %s به دلیل %s به مدت %s سکوت شد", warnUserName(user), reason, floodMinutesText(minutes))
+	case floodActionWarn:
+		action.Reason = reason
+		warnText, err := f.moderation.warn(action)
+		if err != nil {
+			log.Printf("Error adding flood warning: %v", err)
+			return
+		}
+		text = warnText
+	default:
+		return
+	}
+	if _, err := f.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("Error sending antiflood message: %v", err)
+	}
+}
+
+func (f *FloodGuard) reset(key floodKey) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.members, key)
+}
+
+// sweep حذف وضعیت کاربرانی که مدتی پیامی نداده‌اند (با قفل گرفته‌شده)
+func (f *FloodGuard) sweep(now time.Time) {
+	if now.Sub(f.lastSweep) < time.Minute {
+		return
+	}
+	f.lastSweep = now
+	for key, state := range f.members {
+		if len(state.times) == 0 || now.Sub(state.times[len(state.times)-1]) > 5*time.Minute {
+			delete(f.members, key)
+		}
+	}
+}
+
+// floodContent محتوای قابل مقایسه پیام برای تشخیص تکرار (متن، کپشن یا شناسه استیکر/گیف)
+func floodContent(message *tgbotapi.Message) string {
+	switch {
+	case message.Text != "":
+		return strings.TrimSpace(message.Text)
+	case message.Sticker != nil:
+		return "sticker:" + message.Sticker.FileUniqueID
+	case message.Animation != nil:
+		return "animation:" + message.Animation.FileUniqueID
+	case message.Caption != "":
+		return strings.TrimSpace(message.Caption)
+	}
+	return ""
+}
+
+func floodMinutesText(minutes int) string {
+	if minutes >= 60 && minutes%60 == 0 {
+		return fmt.Sprintf("%d ساعت", minutes/60)
+	}
+	return fmt.Sprintf("%d دقیقه", minutes)
+}
+
+// BuildMenu منوی «ضد اسپم» در پنل قفل‌ها
+func (f *FloodGuard) BuildMenu(chatID int64) tgbotapi.MessageConfig {
+	enabled, _ := f.storage.IsFeatureEnabled(chatID, featureAntiFlood)
+	value := func(key string) int {
+		return groupIntSetting(f.storage, chatID, key, floodOptions[key])
+	}
+	limit, window, repeat := value(settingFloodLimit), value(settingFloodWindow), value(settingFloodRepeat)
+	action, minutes := value(settingFloodAction), value(settingFloodMuteMinutes)
+
+	repeatText := "خاموش"
+	if repeat > 0 {
+		repeatText = fmt.Sprintf("%d بار", repeat)
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌊 ضد اسپم "+boolIcon(enabled), "flood_toggle"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📨 حداکثر %d پیام", limit), "flood_cycle:"+settingFloodLimit),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⏱ در %d ثانیه", window), "flood_cycle:"+settingFloodWindow),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁 پیام تکراری: "+repeatText, "flood_cycle:"+settingFloodRepeat),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚖️ برخورد: "+floodActionNames[action], "flood_cycle:"+settingFloodAction),
+		),
+	}
+	if action == floodActionMute {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔇 مدت سکوت: "+floodMinutesText(minutes), "flood_cycle:"+settingFloodMuteMinutes),
+		))
+	}
+
+	text := "🌊 ضد اسپم\n\n" +
+		fmt.Sprintf("اگر کاربری بیش از %d پیام در %d ثانیه بفرستد", limit, window)
+	if repeat > 0 {
+		text += fmt.Sprintf(" یا %d پیام یکسان پشت‌سرهم ارسال کند", repeat)
+	}
+	text += "، پیام‌هایش حذف می‌شود"
+	switch action {
+	case floodActionMute:
+		text += " و به مدت " + floodMinutesText(minutes) + " سکوت می‌شود."
+	case floodActionWarn:
+		text += " و طبق پلکان اخطار، اخطار می‌گیرد."
+	default:
+		text += "."
+	}
+	text += "\n\nادمین‌ها معاف هستند. با هر کلیک روی دکمه‌ها مقدار بعدی انتخاب می‌شود."
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return msg
+}
+
+// HandleCallback دکمه‌های منوی ضد اسپم (flood_*)؛ فقط ادمین‌های گروه
+func (f *FloodGuard) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	chatID := cq.Message.Chat.ID
+	data := cq.Data
+
+	if data != "flood_menu" {
+		if isAdmin, err := f.moderation.isUserAdmin(chatID, cq.From.ID); err != nil || !isAdmin {
+			return tgbotapi.NewCallback(cq.ID, "❌ فقط ادمین‌های گروه می‌توانند تنظیمات را تغییر دهند")
+		}
+	}
+
+	switch {
+	case data == "flood_menu":
+		// فقط نمایش منو
+
+	case data == "flood_toggle":
+		enabled, err := f.storage.IsFeatureEnabled(chatID, featureAntiFlood)
+		if err != nil {
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در بررسی وضعیت ضد اسپم")
+		}
+		if err := f.storage.SetFeatureEnabled(chatID, featureAntiFlood, !enabled); err != nil {
+			log.Printf("Error toggling antiflood: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در تغییر وضعیت ضد اسپم")
+		}
+		f.invalidate(chatID)
+
+	case strings.HasPrefix(data, "flood_cycle:"):
+		key := strings.TrimPrefix(data, "flood_cycle:")
+		options, ok := floodOptions[key]
+		if !ok {
+			return tgbotapi.NewCallback(cq.ID, "تنظیم نامعتبر")
+		}
+		if err := cycleGroupIntSetting(f.storage, chatID, key, options); err != nil {
+			log.Printf("Error saving antiflood setting: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در ذخیره تنظیمات")
+		}
+		f.invalidate(chatID)
+	}
+
+	f.bot.Send(f.BuildMenu(chatID))
+	return tgbotapi.NewCallback(cq.ID, "✅")
+}
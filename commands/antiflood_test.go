@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFloodStateRecordRepeat(t *testing.T) {
+	const window = 10 * time.Second
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		content string
+		after   time.Duration // فاصله از شروع
+		want    int
+	}{
+		{"مرسی", 0, 1},
+		{"مرسی", 2 * time.Second, 2},
+		{"مرسی", 4 * time.Second, 3},
+		{"آره", 5 * time.Second, 1},
+		{"آره", 2 * time.Minute, 1}, // تکرار دیرتر از بازه دوباره از یک شمرده می‌شود
+		{"آره", 2*time.Minute + 10*time.Second, 2},
+		{"آره", 5 * time.Minute, 1},
+		{"", 5*time.Minute + time.Second, 1},
+		{"", 5*time.Minute + 2*time.Second, 1}, // پیام بدون محتوای قابل مقایسه تکرار حساب نمی‌شود
+	}
+	var s floodState
+	for i, step := range steps {
+		if got := s.recordRepeat(step.content, start.Add(step.after), window); got != step.want {
+			t.Fatalf("step %d (%q at %v): repeats = %d, want %d", i, step.content, step.after, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// settingModLogChat شناسه کانال یا گروهی که اقدامات مدیریتی گروه در آن ارسال می‌شود
+const settingModLogChat = "mod_log_chat"
+
+// نوع اقدام مدیریتی در گزارش
+const (
+	modActionBan    = "ban"
+	modActionUnban  = "unban"
+	modActionMute   = "mute"
+	modActionUnmute = "unmute"
+	modActionDelete = "delete"
+	modActionPurge  = "purge"
+	modActionWarn   = "warn"
+	modActionUnwarn = "unwarn"
+	modActionKick   = "kick"
+)
+
+// منبع اقدام: دستی یا یکی از قفل‌ها و سازوکارهای خودکار
+const (
+	modSourceManual      = "manual"
+	modSourceLockLink    = "lock:link"
+	modSourceLockBadword = "lock:badword"
+	modSourceLockMedia   = "lock:media"
+	modSourceAntiFlood   = "antiflood"
+	modSourceCaptcha     = "captcha"
+	modSourceWarnLadder  = "warn_ladder"
+)
+
+var modActionNames = map[string]string{
+	modActionBan:    "بن",
+	modActionUnban:  "رفع بن",
+	modActionMute:   "سکوت",
+	modActionUnmute: "آزاد",
+	modActionDelete: "حذف",
+	modActionPurge:  "حذف دسته‌ای",
+	modActionWarn:   "اخطار",
+	modActionUnwarn: "حذف اخطار",
+	modActionKick:   "اخراج",
+}
+
+var modSourceNames = map[string]string{
+	modSourceManual:      "دستی",
+	modSourceLockLink:    "قفل لینک",
+	modSourceLockBadword: "قفل فحش",
+	modSourceLockMedia:   "قفل رسانه",
+	modSourceAntiFlood:   "ضد اسپم",
+	modSourceCaptcha:     "کپچای ورود",
+	modSourceWarnLadder:  "پلکان اخطار",
+}
+
+// ModerationAction یک اقدام مدیریتی برای ثبت در گزارش و ارسال به کانال گزارش
+type ModerationAction struct {
+	ChatID    int64
+	ChatTitle string
+	Actor     *tgbotapi.User // nil = اقدام خودکار ربات
+	Target    *tgbotapi.User
+	TargetID  int64 // وقتی کاربر هدف فقط با شناسه در دسترس است
+	Action    string
+	Reason    string
+	Duration  time.Duration
+	Excerpt   string
+	Source    string
+}
+
+// DeletedByLock ثبت حذف خودکار پیام توسط یکی از قفل‌ها (lock: link یا badword)
+func (m *ModerationCommand) DeletedByLock(message *tgbotapi.Message, lock string, reason string) {
+	m.Record(ModerationAction{
+		ChatID:    message.Chat.ID,
+		ChatTitle: message.Chat.Title,
+		Target:    message.From,
+		Action:    modActionDelete,
+		Reason:    reason,
+		Excerpt:   messageExcerpt(message),
+		Source:    "lock:" + lock,
+	})
+}
+
+// Record ذخیره اقدام در گزارش مدیریت و ارسال آن به کانال گزارش گروه (در صورت تنظیم)؛
+// اقدام دستی فوراً ارسال می‌شود و اقدام خودکار از صف runAutoLogs
+func (m *ModerationCommand) Record(a ModerationAction) {
+	entry := &storage.ModerationLog{
+		GroupID:  a.ChatID,
+		TargetID: a.TargetID,
+		Action:   a.Action,
+		Reason:   a.Reason,
+		Duration: int64(a.Duration / time.Second),
+		Excerpt:  a.Excerpt,
+		Source:   a.Source,
+	}
+	if a.Actor != nil {
+		entry.ActorID = a.Actor.ID
+		entry.ActorName = warnUserName(a.Actor)
+	}
+	if a.Target != nil {
+		entry.TargetID = a.Target.ID
+		entry.TargetName = warnUserName(a.Target)
+	}
+	if entry.Source == "" {
+		entry.Source = modSourceManual
+	}
+	if err := m.storage.SaveModerationLog(entry); err != nil {
+		log.Printf("Error saving moderation log: %v", err)
+	}
+
+	logChat, err := m.logChat(a.ChatID)
+	if err != nil || logChat == 0 {
+		return
+	}
+	title := a.ChatTitle
+	if title == "" {
+		title = strconv.FormatInt(a.ChatID, 10)
+	}
+	text := "🛡 " + modActionNames[entry.Action] + " | " + title + "\n" + formatModerationLog(*entry)
+	if entry.Source != modSourceManual {
+		// اقدام‌های خودکار ممکن است رگباری باشند؛ در صف می‌روند تا دسته‌ای و با فاصله ارسال شوند
+		select {
+		case m.autoLogs <- modLogEntry{logChat: logChat, text: text}:
+		default:
+			log.Printf("Moderation log queue full, dropping entry for %d", logChat)
+		}
+		return
+	}
+	m.sendModLog(logChat, text)
+}
+
+func (m *ModerationCommand) sendModLog(logChat int64, text string) {
+	if _, err := m.bot.Send(tgbotapi.NewMessage(logChat, text)); err != nil {
+		log.Printf("Error forwarding moderation log to %d: %v", logChat, err)
+	}
+}
+
+// modLogEntry گزارش یک اقدام خودکار در صف ارسال به کانال گزارش
+type modLogEntry struct {
+	logChat int64
+	text    string
+}
+
+const (
+	modLogQueueSize  = 256
+	modLogInterval   = 3 * time.Second // حداکثر یک پیام در هر بازه برای هر کانال گزارش
+	modLogBatchLimit = 4000            // کمتر از سقف ۴۰۹۶ نویسه پیام تلگرام
+)
+
+// runAutoLogs گزارش‌های خودکار صف را هر modLogInterval دسته‌ای به کانال‌های گزارش می‌فرستد
+func (m *ModerationCommand) runAutoLogs() {
+	pending := make(map[int64][]string)
+	ticker := time.NewTicker(modLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-m.autoLogs:
+			pending[e.logChat] = append(pending[e.logChat], e.text)
+		case <-ticker.C:
+			for logChat, texts := range pending {
+				batch, rest := takeModLogBatch(texts, modLogBatchLimit)
+				m.sendModLog(logChat, batch)
+				if len(rest) == 0 {
+					delete(pending, logChat)
+				} else {
+					pending[logChat] = rest
+				}
+			}
+		}
+	}
+}
+
+// takeModLogBatch تا جایی که طول پیام از limit بیشتر نشود گزارش‌ها را کنار هم می‌گذارد؛ دست‌کم یکی برداشته می‌شود
+func takeModLogBatch(texts []string, limit int) (string, []string) {
+	n, size := 1, len(texts[0])
+	for n < len(texts) && size+2+len(texts[n]) <= limit {
+		size += 2 + len(texts[n])
+		n++
+	}
+	return strings.Join(texts[:n], "\n\n"), texts[n:]
+}
+
+func (m *ModerationCommand) logChat(chatID int64) (int64, error) {
+	raw, err := m.storage.GetGroupSetting(chatID, settingModLogChat)
+	if err != nil || raw == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+// formatModerationLog جزئیات یک اقدام برای کانال گزارش و «گزارش مدیریت»
+func formatModerationLog(entry storage.ModerationLog) string {
+	var b strings.Builder
+	if entry.ActorID == 0 {
+		b.WriteString("🤖 خودکار (" + modSourceNames[entry.Source] + ")")
+	} else {
+		fmt.Fprintf(&b, "👮 توسط: %s (%d)", entry.ActorName, entry.ActorID)
+	}
+	if entry.TargetID != 0 {
+		fmt.Fprintf(&b, "\n👤 کاربر: %s (%d)", entry.TargetName, entry.TargetID)
+	}
+	if entry.Reason != "" {
+		b.WriteString("\n📝 دلیل: " + entry.Reason)
+	}
+	if entry.Duration > 0 {
+		b.WriteString("\n⏱ مدت: " + formatModerationDuration(time.Duration(entry.Duration)*time.Second))
+	} else if entry.Action == modActionMute {
+		b.WriteString("\n⏱ مدت: نامحدود")
+	}
+	if entry.Excerpt != "" {
+		b.WriteString("\n💬 پیام: «" + entry.Excerpt + "»")
+	}
+	return b.String()
+}
+
+func formatModerationDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d روز", d/(24*time.Hour))
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d ساعت", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d دقیقه", d/time.Minute)
+	}
+}
+
+// messageExcerpt خلاصه کوتاه متن یا کپشن پیام برای گزارش
+func messageExcerpt(message *tgbotapi.Message) string {
+	if message == nil {
+		return ""
+	}
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > 200 {
+		text = string(runes[:200]) + "…"
+	}
+	return text
+}
+
+// HandleLogChannel «کانال گزارش <@نام یا شناسه>» برای اتصال و «کانال گزارش حذف» برای قطع ارسال گزارش‌ها
+func (m *ModerationCommand) HandleLogChannel(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chat := update.Message.Chat
+	chatID := chat.ID
+	userID := update.Message.From.ID
+
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
+	}
+	isAdmin, err := m.isUserAdmin(chatID, userID)
+	if err != nil {
+		log.Printf("getChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !isAdmin {
+		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند کانال گزارش را تنظیم کنند")
+	}
+
+	arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(update.Message.Text), "کانال گزارش"))
+	switch arg {
+	case "":
+		current, _ := m.logChat(chatID)
+		text := "ℹ️ کانال گزارش تنظیم نشده است"
+		if current != 0 {
+			text = fmt.Sprintf("ℹ️ اقدامات مدیریتی به چت %d ارسال می‌شوند", current)
+		}
+		return tgbotapi.NewMessage(chatID, text+"\n\nاتصال: کانال گزارش @channel یا کانال گزارش -100…\nقطع: کانال گزارش حذف\n\nربات باید در آن کانال/گروه ادمین باشد.")
+	case "حذف":
+		if err := m.storage.DeleteGroupSetting(chatID, settingModLogChat); err != nil {
+			log.Printf("Error deleting log channel: %v", err)
+			return tgbotapi.NewMessage(chatID, "❌ خطا در حذف کانال گزارش")
+		}
+		return tgbotapi.NewMessage(chatID, "✅ ارسال گزارش به کانال قطع شد")
+	}
+
+	cfg := tgbotapi.ChatConfig{SuperGroupUsername: arg}
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		cfg = tgbotapi.ChatConfig{ChatID: id}
+	} else if !strings.HasPrefix(arg, "@") {
+		return tgbotapi.NewMessage(chatID, "❌ نام کانال باید با @ شروع شود یا شناسه عددی باشد")
+	}
+	target, err := m.bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: cfg})
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, "❌ کانال یا گروه پیدا نشد. ابتدا ربات را در آن ادمین کنید")
+	}
+	if target.ID == chatID {
+		return tgbotapi.NewMessage(chatID, "❌ کانال گزارش باید جدا از خود گروه باشد")
+	}
+	// فقط کسی که در مقصد هم ادمین است بتواند گزارش‌ها را به آنجا بفرستد
+	if ok, err := m.isUserAdmin(target.ID, userID); err != nil || !ok {
+		return tgbotapi.NewMessage(chatID, "❌ شما باید در کانال/گروه گزارش هم ادمین باشید")
+	}
+	test := tgbotapi.NewMessage(target.ID, "✅ این چت به‌عنوان کانال گزارش مدیریت «"+chat.Title+"» تنظیم شد")
+	if _, err := m.bot.Send(test); err != nil {
+		return tgbotapi.NewMessage(chatID, "❌ ربات نمی‌تواند در آن چت پیام بفرستد. دسترسی ارسال پیام را بررسی کنید")
+	}
+	if err := m.storage.SetGroupSetting(chatID, settingModLogChat, strconv.FormatInt(target.ID, 10)); err != nil {
+		log.Printf("Error saving log channel: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در ذخیره کانال گزارش")
+	}
+	return tgbotapi.NewMessage(chatID, "✅ از این پس اقدامات مدیریتی در «"+target.Title+"» ثبت می‌شود")
+}
+
+// HandleAuditReport «گزارش مدیریت [@نام | شناسه | نوع اقدام]» یا روی ریپلای: آخرین اقدامات مدیریتی گروه
+func (m *ModerationCommand) HandleAuditReport(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chat := update.Message.Chat
+	chatID := chat.ID
+
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
+	}
+	isAdmin, err := m.isUserAdmin(chatID, update.Message.From.ID)
+	if err != nil {
+		log.Printf("getChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !isAdmin {
+		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند گزارش مدیریت را ببینند")
+	}
+
+	filter := storage.ModerationLogFilter{Limit: 15}
+	if reply := update.Message.ReplyToMessage; reply != nil && reply.From != nil {
+		filter.TargetID = reply.From.ID
+	}
+	for _, arg := range strings.Fields(strings.TrimPrefix(strings.TrimSpace(update.Message.Text), "گزارش مدیریت")) {
+		if strings.HasPrefix(arg, "@") {
+			filter.TargetName = arg
+			continue
+		}
+		if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+			filter.TargetID = id
+			continue
+		}
+		if action, ok := modActionByName(arg); ok {
+			filter.Action = action
+			continue
+		}
+		return tgbotapi.NewMessage(chatID, "نحوه استفاده:\nگزارش مدیریت — آخرین اقدامات\nگزارش مدیریت @user یا شناسه — اقدامات روی یک کاربر (یا روی ریپلای)\nگزارش مدیریت بن — فقط یک نوع اقدام\n\nانواع: بن، سکوت، آزاد، حذف، اخطار، اخراج")
+	}
+
+	logs, err := m.storage.RecentModerationLogs(chatID, filter)
+	if err != nil {
+		log.Printf("Error loading moderation logs: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در دریافت گزارش مدیریت")
+	}
+	if len(logs) == 0 {
+		return tgbotapi.NewMessage(chatID, "ℹ️ اقدامی با این مشخصات ثبت نشده است")
+	}
+
+	var b strings.Builder
+	b.WriteString("🛡 گزارش مدیریت (جدیدترین اول)")
+	for _, entry := range logs {
+		item := fmt.Sprintf("\n\n▪️ %s — %s\n%s", modActionNames[entry.Action], entry.CreatedAt.Format("2006-01-02 15:04"), formatModerationLog(entry))
+		// سقف طول پیام تلگرام
+		if b.Len()+len(item) > 4000 {
+			break
+		}
+		b.WriteString(item)
+	}
+	return tgbotapi.NewMessage(chatID, b.String())
+}
+
+// modActionByName نوع اقدام از روی نام فارسی یا انگلیسی آن
+func modActionByName(name string) (string, bool) {
+	if name == "ازاد" {
+		name = "آزاد"
+	}
+	for action, persian := range modActionNames {
+		if name == persian || name == action {
+			return action, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTakeModLogBatch(t *testing.T) {
+	texts := []string{"aaaa", "bbbb", "cccc"}
+
+	batch, rest := takeModLogBatch(texts, 10)
+	if batch != "aaaa\n\nbbbb" || len(rest) != 1 || rest[0] != "cccc" {
+		t.Fatalf("takeModLogBatch(limit 10) = %q, %q", batch, rest)
+	}
+
+	batch, rest = takeModLogBatch(texts, 100)
+	if batch != "aaaa\n\nbbbb\n\ncccc" || len(rest) != 0 {
+		t.Fatalf("takeModLogBatch(limit 100) = %q, %q", batch, rest)
+	}
+
+	// گزارش بلندتر از سقف هم به‌تنهایی ارسال می‌شود تا صف گیر نکند
+	long := strings.Repeat("x", 20)
+	batch, rest = takeModLogBatch([]string{long, "y"}, 10)
+	if batch != long || len(rest) != 1 {
+		t.Fatalf("takeModLogBatch(oversized) = %q, %q", batch, rest)
+	}
+}
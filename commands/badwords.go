@@ -0,0 +1,446 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// کلمات کوتاه‌تر یا مساوی این طول فقط وقتی فحش حساب می‌شوند که کل یک کلمه باشند (مثلاً «عن» داخل «عنوان» نه)
+const badWordExactMaxLen = 3
+
+// badWordLeet تبدیل اعداد و نمادهایی که در فینگلیش به جای حروف نوشته می‌شوند
+var badWordLeet = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's',
+}
+
+type badWordsFile struct {
+	FarsiWords    []string `json:"farsiWords"`
+	FinglishWords []string `json:"finglishWords"`
+}
+
+// BadWordFilter تشخیص فحش با فهرست عمومی (badwords.json) به‌علاوه کلماتی که هر گروه اضافه یا مستثنی کرده است
+type BadWordFilter struct {
+	storage    *storage.MySQLStorage
+	moderation *ModerationCommand
+	global     []string // شکل نرمال‌شده کلمات فهرست عمومی
+	globalSet  map[string]bool
+
+	mu       sync.RWMutex
+	matchers map[int64]*badWordMatcher // بر اساس گروه؛ nil برای گروه‌های بدون تغییر
+	base     *badWordMatcher
+}
+
+// NewBadWordFilter بارگذاری فهرست عمومی از path
+func NewBadWordFilter(storage *storage.MySQLStorage, moderation *ModerationCommand, path string) *BadWordFilter {
+	f := &BadWordFilter{
+		storage:    storage,
+		moderation: moderation,
+		globalSet:  make(map[string]bool),
+		matchers:   make(map[int64]*badWordMatcher),
+	}
+
+	var bw badWordsFile
+	if file, err := os.Open(path); err != nil {
+		log.Printf("cannot open badwords file: %v", err)
+	} else {
+		if err := json.NewDecoder(file).Decode(&bw); err != nil {
+			log.Printf("cannot decode badwords file: %v", err)
+		}
+		file.Close()
+	}
+	for _, w := range append(bw.FarsiWords, bw.FinglishWords...) {
+		w = normalizeBadWordText(w)
+		if w == "" || f.globalSet[w] {
+			continue
+		}
+		f.globalSet[w] = true
+		f.global = append(f.global, w)
+	}
+	f.base = newBadWordMatcher(f.global)
+	return f
+}
+
+// Contains آیا متن شامل یکی از فحش‌های فعال در این گروه است؟
+func (f *BadWordFilter) Contains(chatID int64, text string) bool {
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	_, found := f.matcher(chatID).Match(normalizeBadWordText(text))
+	return found
+}
+
+// matcher اتوماتون فهرست نهایی گروه (با کش تا تغییر بعدی فهرست گروه)
+func (f *BadWordFilter) matcher(chatID int64) *badWordMatcher {
+	f.mu.RLock()
+	m, ok := f.matchers[chatID]
+	f.mu.RUnlock()
+	if ok {
+		if m == nil {
+			return f.base
+		}
+		return m
+	}
+
+	changes, err := f.storage.ListGroupBadWords(chatID)
+	if err != nil {
+		// بدون کش کردن، فعلاً با فهرست عمومی بررسی شود
+		log.Printf("Error loading group bad words: %v", err)
+		return f.base
+	}
+	if len(changes) > 0 {
+		excluded := make(map[string]bool)
+		var added []string
+		for _, c := range changes {
+			if c.Excluded {
+				excluded[c.Word] = true
+			} else {
+				added = append(added, c.Word)
+			}
+		}
+		words := make([]string, 0, len(f.global)+len(added))
+		for _, w := range f.global {
+			if !excluded[w] {
+				words = append(words, w)
+			}
+		}
+		m = newBadWordMatcher(append(words, added...))
+	}
+
+	f.mu.Lock()
+	f.matchers[chatID] = m
+	f.mu.Unlock()
+	if m == nil {
+		return f.base
+	}
+	return m
+}
+
+func (f *BadWordFilter) invalidate(chatID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.matchers, chatID)
+}
+
+// IsCommand آیا متن یکی از دستورات «افزودن فحش»، «حذف فحش» یا «لیست فحش» است؟
+func (f *BadWordFilter) IsCommand(text string) bool {
+	text = strings.TrimSpace(text)
+	return text == "لیست فحش" || strings.HasPrefix(text, "افزودن فحش") || strings.HasPrefix(text, "حذف فحش")
+}
+
+// IsAdminCommand دستور فهرست فحش از طرف ادمین گروه؛ این پیام‌ها خودشان کلمه را دارند و نباید با قفل فحش حذف شوند
+func (f *BadWordFilter) IsAdminCommand(message *tgbotapi.Message) bool {
+	if message.From == nil || !f.IsCommand(message.Text) {
+		return false
+	}
+	isAdmin, err := f.moderation.isUserAdmin(message.Chat.ID, message.From.ID)
+	return err == nil && isAdmin
+}
+
+// HandleCommand مدیریت فهرست فحش گروه توسط ادمین‌ها:
+// «افزودن فحش <کلمه>»، «حذف فحش <کلمه>» (برای کلمات فهرست عمومی یعنی مستثنی کردن در این گروه) و «لیست فحش»
+func (f *BadWordFilter) HandleCommand(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chat := update.Message.Chat
+	chatID := chat.ID
+
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
+	}
+	isAdmin, err := f.moderation.isUserAdmin(chatID, update.Message.From.ID)
+	if err != nil {
+		log.Printf("getChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !isAdmin {
+		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند فهرست فحش را تغییر دهند")
+	}
+
+	text := strings.TrimSpace(update.Message.Text)
+	if text == "لیست فحش" {
+		return f.list(chatID)
+	}
+
+	adding := strings.HasPrefix(text, "افزودن فحش")
+	raw := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(text, "افزودن فحش"), "حذف فحش"))
+	word := normalizeBadWordText(raw)
+	if word == "" {
+		return tgbotapi.NewMessage(chatID, "نحوه استفاده:\nافزودن فحش <کلمه>\nحذف فحش <کلمه>\nلیست فحش")
+	}
+	if len([]rune(word)) > 64 {
+		return tgbotapi.NewMessage(chatID, "❌ کلمه خیلی طولانی است (حداکثر ۶۴ کاراکتر)")
+	}
+
+	// پیام دستور (که خودش کلمه را دارد) پاک شود
+	_, _ = f.moderation.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
+
+	changes, err := f.storage.ListGroupBadWords(chatID)
+	if err != nil {
+		log.Printf("Error loading group bad words: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در دریافت فهرست فحش گروه")
+	}
+	var existing *storage.GroupBadWord
+	for i := range changes {
+		if changes[i].Word == word {
+			existing = &changes[i]
+			break
+		}
+	}
+
+	var reply string
+	switch {
+	case adding && existing != nil && existing.Excluded:
+		// کلمه فهرست عمومی که قبلاً مستثنی شده بود، دوباره فعال شود
+		err = f.storage.DeleteGroupBadWord(chatID, word)
+		reply = "✅ کلمه دوباره در فهرست فحش این گروه قرار گرفت"
+	case adding && (existing != nil || f.globalSet[word]):
+		return tgbotapi.NewMessage(chatID, "ℹ️ این کلمه از قبل در فهرست فحش است")
+	case adding:
+		err = f.storage.SetGroupBadWord(chatID, word, false, update.Message.From.ID)
+		reply = "✅ کلمه به فهرست فحش این گروه اضافه شد"
+	case existing != nil && !existing.Excluded:
+		err = f.storage.DeleteGroupBadWord(chatID, word)
+		reply = "✅ کلمه از فهرست فحش این گروه حذف شد"
+	case existing == nil && f.globalSet[word]:
+		err = f.storage.SetGroupBadWord(chatID, word, true, update.Message.From.ID)
+		reply = "✅ این کلمه از این به بعد در این گروه فحش حساب نمی‌شود"
+	default:
+		return tgbotapi.NewMessage(chatID, "ℹ️ این کلمه در فهرست فحش نیست")
+	}
+	if err != nil {
+		log.Printf("Error saving group bad word: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در ذخیره فهرست فحش")
+	}
+	f.invalidate(chatID)
+	return tgbotapi.NewMessage(chatID, reply)
+}
+
+// list نمایش کلمات اضافه‌شده و مستثنی‌شده گروه
+func (f *BadWordFilter) list(chatID int64) tgbotapi.MessageConfig {
+	changes, err := f.storage.ListGroupBadWords(chatID)
+	if err != nil {
+		log.Printf("Error loading group bad words: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در دریافت فهرست فحش گروه")
+	}
+	var added, excluded []string
+	for _, c := range changes {
+		if c.Excluded {
+			excluded = append(excluded, c.Word)
+		} else {
+			added = append(added, c.Word)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚫 فهرست فحش این گروه\n\nفهرست عمومی: %d کلمه", len(f.global))
+	b.WriteString("\n\n➕ اضافه‌شده در این گروه:")
+	if len(added) == 0 {
+		b.WriteString(" —")
+	}
+	for _, w := range added {
+		b.WriteString("\n• " + w)
+	}
+	b.WriteString("\n\n➖ مستثنی‌شده از فهرست عمومی:")
+	if len(excluded) == 0 {
+		b.WriteString(" —")
+	}
+	for _, w := range excluded {
+		b.WriteString("\n• " + w)
+	}
+	b.WriteString("\n\nافزودن فحش <کلمه> | حذف فحش <کلمه>")
+	return tgbotapi.NewMessage(chatID, b.String())
+}
+
+// normalizeBadWordText شکل یکسان متن برای مقایسه فحش‌ها: یکسان‌سازی حروف عربی/فارسی و نیم‌فاصله،
+// حذف اعراب، تبدیل اعداد/نمادهای فینگلیش به حرف (فقط در کلمه‌های لاتین)، کوتاه کردن حروف تکراری به
+// حداکثر دو حرف («کیییر» -> «کییر») و چسباندن حروف تکی پشت‌سرهم («ک ی ر»). کلمات با یک فاصله از هم جدا می‌شوند.
+func normalizeBadWordText(s string) string {
+	s = strings.ToLower(normalizePersian(s))
+
+	var b strings.Builder
+	for _, token := range strings.Fields(s) {
+		// «b4dw0rd» فینگلیش است ولی «۴۰۰» یا «1401» عدد می‌ماند
+		leet := strings.IndexFunc(token, func(r rune) bool { return r >= 'a' && r <= 'z' }) >= 0
+		var last rune
+		run := 0
+		for _, r := range token {
+			if folded, ok := badWordLeet[r]; ok && leet {
+				r = folded
+			}
+			switch {
+			case r == 'آ' || r == 'أ' || r == 'إ':
+				r = 'ا'
+			case unicode.Is(unicode.Mn, r):
+				// اعراب و تشدید
+				continue
+			case !unicode.IsLetter(r):
+				r = ' '
+			}
+			if r == last {
+				if run++; run > 2 || r == ' ' {
+					continue
+				}
+			} else {
+				last, run = r, 1
+			}
+			b.WriteRune(r)
+		}
+		b.WriteRune(' ')
+	}
+
+	tokens := strings.Fields(b.String())
+	merged := make([]string, 0, len(tokens))
+	for i := 0; i < len(tokens); {
+		j := i
+		for j < len(tokens) && len([]rune(tokens[j])) == 1 {
+			j++
+		}
+		if j-i >= 2 {
+			merged = append(merged, strings.Join(tokens[i:j], ""))
+			i = j
+			continue
+		}
+		merged = append(merged, tokens[i])
+		i++
+	}
+	return strings.Join(merged, " ")
+}
+
+// collapseRepeats جمع کردن هر حرف تکراری پشت‌سرهم به یک حرف («کییر» -> «کیر»)
+func collapseRepeats(s string) string {
+	var b strings.Builder
+	var last rune
+	for _, r := range s {
+		if r == last {
+			continue
+		}
+		b.WriteRune(r)
+		last = r
+	}
+	return b.String()
+}
+
+// badWordMatcher کلماتی که حرف دوتایی دارند («ممه») با متن نرمال‌شده و بقیه با متن کاملاً جمع‌شده مقایسه می‌شوند؛
+// این‌طور «مه» با «ممه» یکی نمی‌شود ولی «کیییر» هنوز «کیر» حساب می‌شود
+type badWordMatcher struct {
+	single *wordMatcher
+	double *wordMatcher
+}
+
+func newBadWordMatcher(words []string) *badWordMatcher {
+	var single, double []string
+	for _, w := range words {
+		if collapsed := collapseRepeats(w); collapsed != w {
+			double = append(double, w)
+		} else {
+			single = append(single, w)
+		}
+	}
+	return &badWordMatcher{single: newWordMatcher(single), double: newWordMatcher(double)}
+}
+
+// Match اولین فحش پیداشده در متن نرمال‌شده با normalizeBadWordText
+func (m *badWordMatcher) Match(text string) (string, bool) {
+	if word, ok := m.double.Match(text); ok {
+		return word, true
+	}
+	return m.single.Match(collapseRepeats(text))
+}
+
+// wordMatcher اتوماتون Aho-Corasick روی کلمات نرمال‌شده؛ هر کلمه باید از ابتدای یک کلمه متن شروع شود
+// و کلمات کوتاه باید با کل یک کلمه برابر باشند
+type wordMatcher struct {
+	nodes    []acNode
+	patterns [][]rune
+}
+
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	output []int // اندیس الگوهایی که در این گره (یا پیوندهای fail آن) تمام می‌شوند
+}
+
+func newWordMatcher(words []string) *wordMatcher {
+	sort.Strings(words)
+	m := &wordMatcher{nodes: []acNode{{next: make(map[rune]int)}}}
+	for _, w := range words {
+		pattern := []rune(w)
+		if len(pattern) == 0 {
+			continue
+		}
+		state := 0
+		for _, r := range pattern {
+			next, ok := m.nodes[state].next[r]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, acNode{next: make(map[rune]int)})
+				m.nodes[state].next[r] = next
+			}
+			state = next
+		}
+		m.nodes[state].output = append(m.nodes[state].output, len(m.patterns))
+		m.patterns = append(m.patterns, pattern)
+	}
+
+	// ساخت پیوندهای fail با پیمایش سطحی
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[state].next {
+			fail := m.nodes[state].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			}
+			m.nodes[child].output = append(m.nodes[child].output, m.nodes[m.nodes[child].fail].output...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// Match اولین فحش پیداشده در متن نرمال‌شده
+func (m *wordMatcher) Match(text string) (string, bool) {
+	runes := []rune(text)
+	state := 0
+	for i, r := range runes {
+		for state != 0 {
+			if _, ok := m.nodes[state].next[r]; ok {
+				break
+			}
+			state = m.nodes[state].fail
+		}
+		if next, ok := m.nodes[state].next[r]; ok {
+			state = next
+		}
+		for _, p := range m.nodes[state].output {
+			pattern := m.patterns[p]
+			start := i - len(pattern) + 1
+			if start > 0 && runes[start-1] != ' ' {
+				continue
+			}
+			if len(pattern) <= badWordExactMaxLen && i+1 < len(runes) && runes[i+1] != ' ' {
+				continue
+			}
+			return string(pattern), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,79 @@
+package commands
+
+import "testing"
+
+func TestNormalizeBadWordText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"spaces collapsed", "  سلام   دنیا ", "سلام دنیا"},
+		{"arabic letters", "كيف", "کیف"},
+		{"alef variants", "آب أو إلا", "اب او الا"},
+		{"diacritics", "کَتّاب", "کتاب"},
+		{"leetspeak", "b4dw0rd", "badword"},
+		{"leet symbols in latin word", "h@$h", "hash"},
+		{"symbols alone", "@$$", ""},
+		{"numbers are not letters", "سال 1401 ساعت 7", "سال ساعت"},
+		{"digits beside single letters", "5 4 7 b", "b"},
+		{"repeated letters", "خیییلیییی", "خییلیی"},
+		{"double letter kept", "ممه", "ممه"},
+		{"punctuation", "idiot!!!", "idiot"},
+		{"spaced out letters", "I D I O T", "idiot"},
+		{"spaced persian letters", "ک ث ا ف ت", "کثافت"},
+		{"dotted letters", "i.d.i.o.t", "idiot"},
+		{"single letter kept", "a test", "a test"},
+		{"zwnj", "می‌روم", "می روم"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeBadWordText(tt.in); got != tt.want {
+				t.Fatalf("normalizeBadWordText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordMatcherMatch(t *testing.T) {
+	words := []string{"عن", "کثافت", "idiot", "badword", "ممه", "sat"}
+	for i, w := range words {
+		words[i] = normalizeBadWordText(w)
+	}
+	m := newBadWordMatcher(words)
+
+	tests := []struct {
+		name  string
+		text  string
+		want  string
+		found bool
+	}{
+		{"clean text", "سلام به همه", "", false},
+		{"short word alone", "عن", "عن", true},
+		{"short word in sentence", "این عن است", "عن", true},
+		{"short word inside عنوان", "عنوان مقاله", "", false},
+		{"short word as prefix", "عنکبوت", "", false},
+		{"short word as suffix", "معن", "", false},
+		{"long word", "تو کثافتی", "کثافت", true},
+		{"long word mid-word", "xidiot", "", false},
+		{"long word as prefix", "idiots", "idiot", true},
+		{"leetspeak", "you 1d10t", "idiot", true},
+		{"spaced out letters", "i d i o t", "idiot", true},
+		{"repeated letters", "baaadwooord", "badword", true},
+		{"spaced persian letters", "ک ث ا ف ت", "کثافت", true},
+		{"double letter word", "ممه", "ممه", true},
+		{"double letter word stretched", "مممممه", "ممه", true},
+		{"fog is not a bad word", "امروز مه غلیظ است", "", false},
+		{"numbers", "کد 5 4 7 را وارد کن", "", false},
+		{"numbers in persian sentence", "ساعت 547", "", false},
+		{"leet only in latin words", "s4t", "sat", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := m.Match(normalizeBadWordText(tt.text))
+			if got != tt.want || found != tt.found {
+				t.Fatalf("Match(%q) = (%q, %v), want (%q, %v)", tt.text, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
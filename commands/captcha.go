@@ -0,0 +1,347 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// تنظیمات کپچای ورود
+const (
+	featureCaptcha        = "captcha"
+	settingCaptchaKind    = "captcha_kind"
+	settingCaptchaTimeout = "captcha_timeout" // دقیقه
+)
+
+// نوع چالش کپچا
+const (
+	captchaKindButton = "button"
+	captchaKindMath   = "math"
+	captchaKindEmoji  = "emoji"
+)
+
+// captchaKinds ترتیب انتخاب نوع چالش در پنل؛ اولی پیش‌فرض است
+var captchaKinds = []string{captchaKindButton, captchaKindMath, captchaKindEmoji}
+
+var captchaKindNames = map[string]string{
+	captchaKindButton: "دکمه",
+	captchaKindMath:   "جمع ساده",
+	captchaKindEmoji:  "انتخاب ایموجی",
+}
+
+var captchaTimeoutOptions = []int{3, 5, 10, 1}
+
+var captchaEmojis = []struct{ Emoji, Name string }{
+	{"🍎", "سیب"}, {"🐱", "گربه"}, {"🚗", "ماشین"}, {"⚽️", "توپ"}, {"🌙", "ماه"},
+	{"🌳", "درخت"}, {"🐟", "ماهی"}, {"☕️", "چای"}, {"🎈", "بادکنک"}, {"🔑", "کلید"},
+}
+
+// CaptchaCommand کپچای ورود اعضای جدید: محدود کردن هنگام ورود و اخراج در صورت پاسخ اشتباه یا پایان مهلت
+type CaptchaCommand struct {
+	bot        *tgbotapi.BotAPI
+	storage    *storage.MySQLStorage
+	moderation *ModerationCommand
+}
+
+func NewCaptchaCommand(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage, moderation *ModerationCommand) *CaptchaCommand {
+	return &CaptchaCommand{
+		bot:        bot,
+		storage:    storage,
+		moderation: moderation,
+	}
+}
+
+// HandleJoin ارسال کپچا برای اعضای جدید پیام ورود؛ true اگر برای دست‌کم یک نفر کپچا ارسال شد
+func (c *CaptchaCommand) HandleJoin(message *tgbotapi.Message) bool {
+	chatID := message.Chat.ID
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		return false
+	}
+	if enabled, err := c.storage.IsFeatureEnabled(chatID, featureCaptcha); err != nil || !enabled {
+		return false
+	}
+
+	// اعضایی که ادمین اضافه کرده است نیازی به کپچا ندارند
+	if message.From != nil {
+		addedByOther := false
+		for _, user := range message.NewChatMembers {
+			if user.ID != message.From.ID {
+				addedByOther = true
+			}
+		}
+		if addedByOther {
+			if isAdmin, err := c.moderation.isUserAdmin(chatID, message.From.ID); err == nil && isAdmin {
+				return false
+			}
+		}
+	}
+
+	kind := c.kind(chatID)
+	timeout := groupIntSetting(c.storage, chatID, settingCaptchaTimeout, captchaTimeoutOptions)
+	issued := false
+	for i := range message.NewChatMembers {
+		user := &message.NewChatMembers[i]
+		if user.IsBot {
+			continue
+		}
+		if err := c.moderation.mute(chatID, user.ID, 0); err != nil {
+			log.Printf("restrictChatMember (captcha) error: %v", err)
+			continue
+		}
+
+		question, answer, keyboard := newCaptchaChallenge(kind, user.ID)
+		text := fmt.Sprintf("👋 %s خوش آمدید!\n\n%s\n\n⏳ اگر ظرف %s دقیقه پاسخ ندهید یا پاسخ اشتباه بدهید، از گروه حذف می‌شوید.",
+			warnUserName(user), question, toPersianDigits(strconv.Itoa(timeout)))
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyToMessageID = message.MessageID
+		msg.ReplyMarkup = keyboard
+		sent, err := c.bot.Send(msg)
+		if err != nil {
+			log.Printf("Error sending captcha: %v", err)
+			// بدون پیام کپچا کاربر راهی برای رفع محدودیت ندارد
+			_ = c.moderation.unmute(chatID, user.ID)
+			continue
+		}
+
+		captcha := &storage.PendingCaptcha{
+			GroupID:          chatID,
+			UserID:           user.ID,
+			JoinMessageID:    message.MessageID,
+			CaptchaMessageID: sent.MessageID,
+			Kind:             kind,
+			Answer:           answer,
+			ExpiresAt:        time.Now().Add(time.Duration(timeout) * time.Minute),
+		}
+		if err := c.storage.SavePendingCaptcha(captcha); err != nil {
+			log.Printf("Error saving pending captcha: %v", err)
+			_ = c.moderation.unmute(chatID, user.ID)
+			_, _ = c.bot.Request(tgbotapi.NewDeleteMessage(chatID, sent.MessageID))
+			continue
+		}
+		issued = true
+	}
+	return issued
+}
+
+// newCaptchaChallenge ساخت متن چالش، پاسخ درست و دکمه‌ها
+func newCaptchaChallenge(kind string, userID int64) (string, string, tgbotapi.InlineKeyboardMarkup) {
+	data := func(value string) string {
+		return fmt.Sprintf("captcha_ans:%d:%s", userID, value)
+	}
+
+	switch kind {
+	case captchaKindMath:
+		a, b := rand.Intn(9)+1, rand.Intn(9)+1
+		answer := a + b
+		options := []int{answer}
+		for len(options) < 4 {
+			candidate := answer + rand.Intn(7) - 3
+			if candidate < 2 || containsInt(options, candidate) {
+				continue
+			}
+			options = append(options, candidate)
+		}
+		rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+		row := make([]tgbotapi.InlineKeyboardButton, 0, len(options))
+		for _, o := range options {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(toPersianDigits(strconv.Itoa(o)), data(strconv.Itoa(o))))
+		}
+		question := fmt.Sprintf("🧮 حاصل %s + %s چند می‌شود؟", toPersianDigits(strconv.Itoa(a)), toPersianDigits(strconv.Itoa(b)))
+		return question, strconv.Itoa(answer), tgbotapi.NewInlineKeyboardMarkup(row)
+
+	case captchaKindEmoji:
+		picks := rand.Perm(len(captchaEmojis))[:6]
+		target := picks[rand.Intn(len(picks))]
+		rows := make([][]tgbotapi.InlineKeyboardButton, 0, 2)
+		for i := 0; i < len(picks); i += 3 {
+			row := make([]tgbotapi.InlineKeyboardButton, 0, 3)
+			for _, p := range picks[i : i+3] {
+				row = append(row, tgbotapi.NewInlineKeyboardButtonData(captchaEmojis[p].Emoji, data(strconv.Itoa(p))))
+			}
+			rows = append(rows, row)
+		}
+		question := "🔎 روی «" + captchaEmojis[target].Name + "» بزنید."
+		return question, strconv.Itoa(target), tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+
+	question := "🤖 برای تأیید اینکه ربات نیستید، دکمه زیر را بزنید."
+	return question, "ok", tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("✅ ربات نیستم", data("ok"))),
+	)
+}
+
+// HandleCallback دکمه‌های کپچا (captcha_ans:*) و منوی تنظیمات آن (captcha_*)
+func (c *CaptchaCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	if strings.HasPrefix(cq.Data, "captcha_ans:") {
+		return c.handleAnswer(cq)
+	}
+
+	chatID := cq.Message.Chat.ID
+	if cq.Data != "captcha_menu" {
+		if isAdmin, err := c.moderation.isUserAdmin(chatID, cq.From.ID); err != nil || !isAdmin {
+			return tgbotapi.NewCallback(cq.ID, "❌ فقط ادمین‌های گروه می‌توانند تنظیمات را تغییر دهند")
+		}
+	}
+
+	var err error
+	switch cq.Data {
+	case "captcha_toggle":
+		var enabled bool
+		if enabled, err = c.storage.IsFeatureEnabled(chatID, featureCaptcha); err == nil {
+			err = c.storage.SetFeatureEnabled(chatID, featureCaptcha, !enabled)
+		}
+	case "captcha_kind":
+		current := c.kind(chatID)
+		next := captchaKinds[0]
+		for i, kind := range captchaKinds {
+			if kind == current {
+				next = captchaKinds[(i+1)%len(captchaKinds)]
+				break
+			}
+		}
+		err = c.storage.SetGroupSetting(chatID, settingCaptchaKind, next)
+	case "captcha_timeout":
+		err = cycleGroupIntSetting(c.storage, chatID, settingCaptchaTimeout, captchaTimeoutOptions)
+	}
+	if err != nil {
+		log.Printf("Error saving captcha setting: %v", err)
+		return tgbotapi.NewCallback(cq.ID, "❌ خطا در ذخیره تنظیمات")
+	}
+
+	c.bot.Send(c.BuildMenu(chatID))
+	return tgbotapi.NewCallback(cq.ID, "✅")
+}
+
+// handleAnswer بررسی پاسخ کاربر؛ فقط خود عضو جدید می‌تواند پاسخ دهد
+func (c *CaptchaCommand) handleAnswer(cq *tgbotapi.CallbackQuery) tgbotapi.CallbackConfig {
+	parts := strings.SplitN(strings.TrimPrefix(cq.Data, "captcha_ans:"), ":", 2)
+	if len(parts) != 2 {
+		return tgbotapi.NewCallback(cq.ID, "")
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return tgbotapi.NewCallback(cq.ID, "")
+	}
+	if cq.From.ID != userID {
+		return tgbotapi.NewCallback(cq.ID, "این کپچا مربوط به شما نیست")
+	}
+	chatID := cq.Message.Chat.ID
+
+	captcha, err := c.storage.GetPendingCaptcha(chatID, userID)
+	if err != nil {
+		log.Printf("Error loading pending captcha: %v", err)
+		return tgbotapi.NewCallback(cq.ID, "❌ خطا، دوباره تلاش کنید")
+	}
+	if captcha == nil || captcha.CaptchaMessageID != cq.Message.MessageID {
+		return tgbotapi.NewCallback(cq.ID, "این کپچا منقضی شده است")
+	}
+	// اگر هم‌زمان زمان‌بند آن را منقضی کرده باشد، دوباره رسیدگی نشود
+	if removed, err := c.storage.DeletePendingCaptcha(captcha.ID); err != nil || !removed {
+		return tgbotapi.NewCallback(cq.ID, "این کپچا منقضی شده است")
+	}
+
+	if parts[1] != captcha.Answer {
+		c.kick(*captcha, cq.From, "پاسخ اشتباه به کپچا")
+		return tgbotapi.NewCallback(cq.ID, "❌ پاسخ اشتباه بود")
+	}
+
+	if err := c.moderation.unmute(chatID, userID); err != nil {
+		log.Printf("restrictChatMember (captcha solved) error: %v", err)
+	}
+	edit := tgbotapi.NewEditMessageText(chatID, captcha.CaptchaMessageID, "✅ "+warnUserName(cq.From)+" تأیید شد. خوش آمدید!")
+	if _, err := c.bot.Send(edit); err != nil {
+		log.Printf("Error editing captcha message: %v", err)
+	}
+	return tgbotapi.NewCallback(cq.ID, "✅ خوش آمدید!")
+}
+
+// ExpireDue اخراج اعضایی که مهلت کپچایشان تمام شده است (اجرا با کران، پس از ری‌استارت هم)
+func (c *CaptchaCommand) ExpireDue() {
+	captchas, err := c.storage.ExpiredCaptchas(time.Now())
+	if err != nil {
+		log.Printf("Error loading expired captchas: %v", err)
+		return
+	}
+	for _, captcha := range captchas {
+		if removed, err := c.storage.DeletePendingCaptcha(captcha.ID); err != nil || !removed {
+			continue
+		}
+		c.kick(captcha, nil, "پایان مهلت کپچا")
+	}
+}
+
+// kick اخراج کاربر، ثبت در گزارش مدیریت و پاک کردن پیام ورود و پیام کپچا.
+// user ممکن است nil باشد (منقضی‌شده توسط زمان‌بند)؛ آن‌وقت فقط شناسه ثبت می‌شود.
+func (c *CaptchaCommand) kick(captcha storage.PendingCaptcha, user *tgbotapi.User, reason string) {
+	if err := c.moderation.kick(captcha.GroupID, captcha.UserID); err != nil {
+		log.Printf("kick (captcha) error: %v", err)
+	} else {
+		c.moderation.Record(ModerationAction{
+			ChatID:   captcha.GroupID,
+			Target:   user,
+			TargetID: captcha.UserID,
+			Action:   modActionKick,
+			Reason:   reason,
+			Source:   modSourceCaptcha,
+		})
+	}
+	_, _ = c.bot.Request(tgbotapi.NewDeleteMessage(captcha.GroupID, captcha.CaptchaMessageID))
+	_, _ = c.bot.Request(tgbotapi.NewDeleteMessage(captcha.GroupID, captcha.JoinMessageID))
+}
+
+func (c *CaptchaCommand) kind(chatID int64) string {
+	kind, _ := c.storage.GetGroupSetting(chatID, settingCaptchaKind)
+	if _, ok := captchaKindNames[kind]; !ok {
+		return captchaKinds[0]
+	}
+	return kind
+}
+
+// BuildMenu منوی تنظیمات کپچای ورود
+func (c *CaptchaCommand) BuildMenu(chatID int64) tgbotapi.MessageConfig {
+	enabled, _ := c.storage.IsFeatureEnabled(chatID, featureCaptcha)
+	timeout := groupIntSetting(c.storage, chatID, settingCaptchaTimeout, captchaTimeoutOptions)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🤖 کپچای ورود "+boolIcon(enabled), "captcha_toggle"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🧩 نوع چالش: "+captchaKindNames[c.kind(chatID)], "captcha_kind"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⏳ مهلت پاسخ: %d دقیقه", timeout), "captcha_timeout"),
+		),
+	)
+	text := "🤖 کپچای ورود\n\nعضو جدید تا حل کردن کپچا نمی‌تواند پیام بدهد. با پاسخ اشتباه یا پایان مهلت از گروه حذف می‌شود (و می‌تواند دوباره عضو شود)." +
+		"\n\nاعضایی که ادمین اضافه می‌کند کپچا نمی‌گیرند. ربات باید دسترسی محدود کردن و حذف اعضا را داشته باشد."
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	return msg
+}
+
+// toPersianDigits تبدیل ارقام لاتین به فارسی
+func toPersianDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return '۰' + (r - '0')
+		}
+		return r
+	}, s)
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
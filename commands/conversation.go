@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// conversationCancelData دکمه لغو گفتگوی چندمرحله‌ای
+const conversationCancelData = "conv_cancel"
+
+// Conversation گفتگوی چندمرحله‌ای در حال اجرا؛ هندلر مرحله با Next یا Finish وضعیت بعدی را تعیین می‌کند.
+// اگر هیچ‌کدام صدا زده نشود (مثلاً ورودی نامعتبر بود)، کاربر در همان مرحله می‌ماند.
+type Conversation struct {
+	ChatID int64
+	UserID int64
+	Flow   string
+	Step   string
+	Data   map[string]string
+
+	next     string
+	finished bool
+}
+
+// Next رفتن به مرحله بعد
+func (c *Conversation) Next(step string) {
+	c.next = step
+}
+
+// Finish پایان گفتگو
+func (c *Conversation) Finish() {
+	c.finished = true
+}
+
+// ConversationHandler پردازش پیام کاربر در یک مرحله از گفتگو
+type ConversationHandler func(update tgbotapi.Update, conv *Conversation) tgbotapi.MessageConfig
+
+type conversationFlow struct {
+	handler ConversationHandler
+	ttl     time.Duration
+}
+
+// conversationKey کاربر در یک چت
+type conversationKey struct {
+	chatID int64
+	userID int64
+}
+
+// ConversationManager ماشین حالت گفتگوهای چندمرحله‌ای (ویزاردها) که در MySQL ذخیره می‌شود.
+// فهرست گفتگوهای فعال در حافظه هم نگه داشته می‌شود تا برای هر پیام گروه به دیتابیس مراجعه نشود.
+type ConversationManager struct {
+	storage *storage.MySQLStorage
+	mu      sync.RWMutex
+	flows   map[string]conversationFlow
+	active  map[conversationKey]time.Time // زمان انقضای گفتگوی فعال هر کاربر
+}
+
+func NewConversationManager(storage *storage.MySQLStorage) *ConversationManager {
+	m := &ConversationManager{
+		storage: storage,
+		flows:   make(map[string]conversationFlow),
+		active:  make(map[conversationKey]time.Time),
+	}
+	// گفتگوهای نیمه‌تمام پیش از راه‌اندازی دوباره ربات
+	states, err := storage.ListActiveConversationStates()
+	if err != nil {
+		log.Printf("Error loading conversation states: %v", err)
+	}
+	for _, state := range states {
+		m.active[conversationKey{chatID: state.ChatID, userID: state.UserID}] = state.ExpiresAt
+	}
+	return m
+}
+
+// isActive آیا کاربر گفتگوی منقضی‌نشده‌ای دارد (بدون مراجعه به دیتابیس)
+func (m *ConversationManager) isActive(key conversationKey, now time.Time) bool {
+	m.mu.RLock()
+	expires, ok := m.active[key]
+	m.mu.RUnlock()
+	if ok && !now.Before(expires) {
+		m.forget(key)
+		return false
+	}
+	return ok
+}
+
+func (m *ConversationManager) forget(key conversationKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.active, key)
+}
+
+// end حذف گفتگو از حافظه و دیتابیس
+func (m *ConversationManager) end(chatID int64, userID int64) error {
+	m.forget(conversationKey{chatID: chatID, userID: userID})
+	return m.storage.DeleteConversationState(chatID, userID)
+}
+
+// Register ثبت یک گفتگو؛ ttl مهلت پاسخ کاربر در هر مرحله است
+func (m *ConversationManager) Register(flow string, ttl time.Duration, handler ConversationHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flows[flow] = conversationFlow{handler: handler, ttl: ttl}
+}
+
+// Start شروع گفتگو برای کاربر در این چت (گفتگوی قبلی او جایگزین می‌شود)
+func (m *ConversationManager) Start(chatID int64, userID int64, flow string, step string, data map[string]string) error {
+	return m.save(&Conversation{ChatID: chatID, UserID: userID, Flow: flow, Step: step, Data: data})
+}
+
+// Cancel لغو گفتگوی فعال؛ false یعنی گفتگویی در جریان نبود
+func (m *ConversationManager) Cancel(chatID int64, userID int64) bool {
+	if !m.isActive(conversationKey{chatID: chatID, userID: userID}, time.Now()) {
+		return false
+	}
+	if err := m.end(chatID, userID); err != nil {
+		log.Printf("Error deleting conversation state: %v", err)
+		return false
+	}
+	return true
+}
+
+// Handle اگر کاربر در این چت گفتگوی فعالی دارد، پیام را به مرحله فعلی می‌دهد؛ دوم: آیا پیام مصرف شد
+func (m *ConversationManager) Handle(update tgbotapi.Update) (tgbotapi.MessageConfig, bool) {
+	message := update.Message
+	if message == nil || message.From == nil {
+		return tgbotapi.MessageConfig{}, false
+	}
+	chatID := message.Chat.ID
+	userID := message.From.ID
+	if !m.isActive(conversationKey{chatID: chatID, userID: userID}, time.Now()) {
+		return tgbotapi.MessageConfig{}, false
+	}
+	text := strings.TrimSpace(message.Text)
+
+	// عکس، استیکر و پیام‌های غیرمتنی دیگر در میانه گفتگو نادیده گرفته می‌شوند؛
+	// دستورات اسلشی هم کار می‌کنند (به جز /cancel)
+	if text == "" || (strings.HasPrefix(text, "/") && !strings.HasPrefix(text, "/cancel")) {
+		return tgbotapi.MessageConfig{}, false
+	}
+
+	state, err := m.storage.GetConversationState(chatID, userID)
+	if err != nil {
+		log.Printf("Error reading conversation state: %v", err)
+		return tgbotapi.MessageConfig{}, false
+	}
+	if state == nil {
+		m.forget(conversationKey{chatID: chatID, userID: userID})
+		return tgbotapi.MessageConfig{}, false
+	}
+
+	if text == "لغو" || strings.HasPrefix(text, "/cancel") {
+		if err := m.end(chatID, userID); err != nil {
+			log.Printf("Error deleting conversation state: %v", err)
+		}
+		return tgbotapi.NewMessage(chatID, "✖️ لغو شد."), true
+	}
+
+	m.mu.RLock()
+	flow, ok := m.flows[state.Flow]
+	m.mu.RUnlock()
+	if !ok {
+		// گفتگوی ناشناخته (مثلاً حذف‌شده در نسخه جدید)
+		_ = m.end(chatID, userID)
+		return tgbotapi.MessageConfig{}, false
+	}
+
+	conv := &Conversation{ChatID: chatID, UserID: userID, Flow: state.Flow, Step: state.Step, Data: map[string]string{}}
+	if state.Data != "" {
+		if err := json.Unmarshal([]byte(state.Data), &conv.Data); err != nil {
+			log.Printf("Error decoding conversation data: %v", err)
+		}
+	}
+
+	response := flow.handler(update, conv)
+
+	switch {
+	case conv.finished:
+		if err := m.end(chatID, userID); err != nil {
+			log.Printf("Error deleting conversation state: %v", err)
+		}
+	case conv.next != "":
+		conv.Step = conv.next
+		if err := m.save(conv); err != nil {
+			log.Printf("Error saving conversation state: %v", err)
+		}
+	}
+	return response, true
+}
+
+// HandleCancelCallback دکمه «لغو» زیر پیام‌های گفتگو
+func (m *ConversationManager) HandleCancelCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	if !m.Cancel(cq.Message.Chat.ID, cq.From.ID) {
+		return tgbotapi.NewCallback(cq.ID, "گفتگوی فعالی ندارید")
+	}
+	return tgbotapi.NewCallback(cq.ID, "✖️ لغو شد")
+}
+
+// CleanupExpired حذف گفتگوهای منقضی‌شده از دیتابیس
+func (m *ConversationManager) CleanupExpired() {
+	now := time.Now()
+	m.mu.Lock()
+	for key, expires := range m.active {
+		if !now.Before(expires) {
+			delete(m.active, key)
+		}
+	}
+	m.mu.Unlock()
+	if err := m.storage.DeleteExpiredConversationStates(); err != nil {
+		log.Printf("Error cleaning up conversation states: %v", err)
+	}
+}
+
+func (m *ConversationManager) save(conv *Conversation) error {
+	m.mu.RLock()
+	flow, ok := m.flows[conv.Flow]
+	m.mu.RUnlock()
+	ttl := 10 * time.Minute
+	if ok && flow.ttl > 0 {
+		ttl = flow.ttl
+	}
+
+	data := ""
+	if len(conv.Data) > 0 {
+		encoded, err := json.Marshal(conv.Data)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	expires := time.Now().Add(ttl)
+	err := m.storage.SaveConversationState(&storage.ConversationState{
+		ChatID:    conv.ChatID,
+		UserID:    conv.UserID,
+		Flow:      conv.Flow,
+		Step:      conv.Step,
+		Data:      data,
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.active[conversationKey{chatID: conv.ChatID, userID: conv.UserID}] = expires
+	m.mu.Unlock()
+	return nil
+}
+
+// conversationCancelKeyboard دکمه لغو برای پیام‌هایی که منتظر ورودی کاربر هستند
+func conversationCancelKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✖️ لغو", conversationCancelData),
+		),
+	)
+}
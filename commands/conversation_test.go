@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// پیام‌هایی که نباید به دیتابیس برسند؛ storage در این تست nil است
+func TestConversationHandleSkipsWithoutLookup(t *testing.T) {
+	active := conversationKey{chatID: -100, userID: 1}
+	expired := conversationKey{chatID: -100, userID: 2}
+	m := &ConversationManager{
+		flows: make(map[string]conversationFlow),
+		active: map[conversationKey]time.Time{
+			active:  time.Now().Add(time.Minute),
+			expired: time.Now().Add(-time.Minute),
+		},
+	}
+	message := func(userID int64, text string) *tgbotapi.Message {
+		return &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -100}, From: &tgbotapi.User{ID: userID}, Text: text}
+	}
+	photo := message(1, "")
+	photo.Photo = []tgbotapi.PhotoSize{{FileID: "p"}}
+	sticker := message(1, "")
+	sticker.Sticker = &tgbotapi.Sticker{FileID: "s"}
+
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+	}{
+		{"no active flow", message(3, "سلام")},
+		{"expired flow", message(2, "سلام")},
+		{"photo during flow", photo},
+		{"sticker during flow", sticker},
+		{"slash command during flow", message(1, "/help")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, consumed := m.Handle(tgbotapi.Update{Message: tt.message}); consumed {
+				t.Fatal("message must not be consumed by the conversation")
+			}
+		})
+	}
+	if _, ok := m.active[expired]; ok {
+		t.Fatal("expired flow should be forgotten")
+	}
+}
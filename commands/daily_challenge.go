@@ -10,19 +10,47 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
+	"redhat-bot/limiter"
 	"redhat-bot/storage"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type DailyChallengeCommand struct {
-	storage *storage.MySQLStorage
-	bot     *tgbotapi.BotAPI
+	storage     *storage.MySQLStorage
+	bot         *tgbotapi.BotAPI
+	ai          *AIService
+	rateLimiter *limiter.RateLimiter
 }
 
-func NewDailyChallengeCommand(storage *storage.MySQLStorage, bot *tgbotapi.BotAPI) *DailyChallengeCommand {
-	return &DailyChallengeCommand{storage: storage, bot: bot}
+func NewDailyChallengeCommand(storage *storage.MySQLStorage, bot *tgbotapi.BotAPI, aiService *AIService, rateLimiter *limiter.RateLimiter) *DailyChallengeCommand {
+	return &DailyChallengeCommand{storage: storage, bot: bot, ai: aiService, rateLimiter: rateLimiter}
+}
+
+// قابلیت‌های اختیاری چلنج روزانه در FeatureSetting
+const (
+	featureChallengeAI      = "challenge_ai"       // ساخت معما با هوش مصنوعی
+	featureChallengeAIJudge = "challenge_ai_judge" // داوری «به‌اندازه کافی نزدیک» با هوش مصنوعی
+)
+
+// دسته‌بندی‌های معما؛ ضرب‌المثل دسته فهرست ثابت zarb.json است
+var challengeCategories = map[string]string{
+	"proverb": "ضرب‌المثل",
+	"movie":   "فیلم",
+	"song":    "آهنگ",
+	"city":    "شهر",
+}
+
+// aiChallengeCategories دسته‌هایی که هوش مصنوعی از بینشان انتخاب می‌کند
+var aiChallengeCategories = []string{"proverb", "movie", "song", "city"}
+
+func challengeCategoryTitle(category string) string {
+	if title, ok := challengeCategories[category]; ok {
+		return title
+	}
+	return challengeCategories["proverb"]
 }
 
 // ---------- Load proverbs (zarb.json) ----------
@@ -74,13 +102,23 @@ func getRandomZarb() (emojis string, proverb string, ok bool) {
 // ---------- Posting daily challenge ----------
 
 func (d *DailyChallengeCommand) PostDailyChallenge(groupID int64) {
-	emojis, proverb, ok := getRandomZarb()
+	category := "proverb"
+	emojis, proverb, ok := "", "", false
+	if enabled, err := d.storage.IsFeatureEnabled(groupID, featureChallengeAI); err == nil && enabled {
+		category, emojis, proverb, ok = d.generateChallenge(groupID)
+	}
+	if !ok {
+		// حالت کلاسیک یا شکست ساخت معما -> فهرست ثابت ضرب‌المثل‌ها
+		category = "proverb"
+		emojis, proverb, ok = getRandomZarb()
+	}
 	if !ok {
 		log.Printf("daily challenge: zarb list is empty")
 		return
 	}
 
-	text := fmt.Sprintf("🧩 چلنج روزانه\n\n%s\n\nاز روی ایموجی ضرب‌المثل را حدس بزنید و روی همین پیام ریپلای کنید.\nاولین پاسخ صحیح لقب «باهوش‌ترین فرد گروه» را می‌گیرد!", emojis)
+	title := challengeCategoryTitle(category)
+	text := fmt.Sprintf("🧩 چلنج روزانه — %s\n\n%s\n\nاز روی ایموجی %s را حدس بزنید و روی همین پیام ریپلای کنید.\nاولین پاسخ صحیح لقب «باهوش‌ترین فرد گروه» را می‌گیرد!", title, emojis, title)
 	msg := tgbotapi.NewMessage(groupID, text)
 	sent, err := d.bot.Send(msg)
 	if err != nil {
@@ -88,11 +126,90 @@ func (d *DailyChallengeCommand) PostDailyChallenge(groupID int64) {
 		return
 	}
 
-	if err := d.storage.CreateDailyChallenge(groupID, sent.MessageID, proverb, emojis); err != nil {
+	if err := d.storage.CreateDailyChallenge(groupID, sent.MessageID, category, proverb, emojis); err != nil {
 		log.Printf("daily challenge: save state error: %v", err)
 	}
 }
 
+// aiChallenge خروجی JSON هوش مصنوعی برای یک معمای ایموجی
+type aiChallenge struct {
+	Emojis string `json:"emojis"`
+	Answer string `json:"answer"`
+}
+
+// generateChallenge ساخت معمای تازه با هوش مصنوعی؛ پاسخ‌های تکراری (در تاریخچه همه گروه‌ها) رد می‌شوند
+func (d *DailyChallengeCommand) generateChallenge(groupID int64) (category string, emojis string, answer string, ok bool) {
+	if !d.ai.Available() {
+		return "", "", "", false
+	}
+
+	recent, err := d.storage.RecentChallengeAnswers(200)
+	if err != nil {
+		log.Printf("daily challenge: cannot read history: %v", err)
+	}
+	used := make(map[string]bool, len(recent))
+	for _, a := range recent {
+		used[normalizeAnswer(a)] = true
+	}
+	avoid := recent
+	if len(avoid) > 40 {
+		avoid = avoid[:40]
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		category = aiChallengeCategories[rand.Intn(len(aiChallengeCategories))]
+		prompt, version, err := d.ai.Prompt("challenge", map[string]any{
+			"Category": challengeCategoryTitle(category),
+			"Avoid":    avoid,
+		})
+		if err != nil {
+			log.Printf("daily challenge: prompt error: %v", err)
+			return "", "", "", false
+		}
+
+		// درخواست خودکار ربات هم از صف مشترک می‌گذرد تا سقف درخواست‌های هم‌زمان رعایت شود
+		job, err := d.ai.Enqueue(groupID, 0)
+		if err != nil {
+			log.Printf("daily challenge: ai queue error: %v", err)
+			return "", "", "", false
+		}
+		response, err := d.ai.Run(job, AIRequest{
+			ChatID:        groupID,
+			Command:       "challenge",
+			Prompt:        prompt,
+			PromptVersion: version,
+		})
+		if err != nil {
+			log.Printf("daily challenge: ai generation error: %v", err)
+			return "", "", "", false
+		}
+
+		start := strings.Index(response, "{")
+		end := strings.LastIndex(response, "}")
+		if start < 0 || end <= start {
+			log.Printf("daily challenge: ai response is not JSON: %q", response)
+			continue
+		}
+		var c aiChallenge
+		if err := json.Unmarshal([]byte(response[start:end+1]), &c); err != nil {
+			log.Printf("daily challenge: invalid ai JSON: %v", err)
+			continue
+		}
+		c.Emojis = strings.TrimSpace(c.Emojis)
+		c.Answer = strings.TrimSpace(c.Answer)
+		key := normalizeAnswer(c.Answer)
+		if c.Emojis == "" || key == "" || len([]rune(c.Answer)) > 80 || strings.Contains(normalizeAnswer(c.Emojis), key) {
+			continue
+		}
+		if used[key] {
+			log.Printf("daily challenge: duplicate ai answer %q", c.Answer)
+			continue
+		}
+		return category, c.Emojis, c.Answer, true
+	}
+	return "", "", "", false
+}
+
 // RunDailyForEnabledGroups posts the daily challenge to all enabled groups
 func (d *DailyChallengeCommand) RunDailyForEnabledGroups() {
 	groups, err := d.storage.GetEnabledGroupsForFeature("daily_challenge")
@@ -144,18 +261,17 @@ func (d *DailyChallengeCommand) HandleAnswer(update tgbotapi.Update) tgbotapi.Me
 		return empty
 	}
 
-	answer := normalizePersian(update.Message.Text)
-	correct := normalizePersian(challenge.Proverb)
-	if answer == "" {
+	answer := normalizeAnswer(update.Message.Text)
+	correct := normalizeAnswer(challenge.Proverb)
+	if answer == "" || correct == "" {
 		return empty
 	}
 
-	// accept exact or containing match
-	if !(answer == correct || strings.Contains(answer, correct)) {
+	user := update.Message.From
+	if !d.isCorrectAnswer(chatID, user.ID, answer, correct, challenge.Category) {
 		return empty
 	}
 
-	user := update.Message.From
 	winnerName := user.FirstName
 	if user.LastName != "" {
 		winnerName = strings.TrimSpace(winnerName + " " + user.LastName)
@@ -167,7 +283,105 @@ func (d *DailyChallengeCommand) HandleAnswer(update tgbotapi.Update) tgbotapi.Me
 		return empty
 	}
 
-	text := fmt.Sprintf("🎉 %s اولین نفر بود که ضرب‌المثل را درست حدس زد!\n\n✅ پاسخ صحیح: «%s»\n🧠 لقب امروز: باهوش‌ترین فرد گروه 👑", winnerName, challenge.Proverb)
+	text := fmt.Sprintf("🎉 %s اولین نفر بود که %s را درست حدس زد!\n\n✅ پاسخ صحیح: «%s»\n🧠 لقب امروز: باهوش‌ترین فرد گروه 👑", winnerName, challengeCategoryTitle(challenge.Category), challenge.Proverb)
 	msg := tgbotapi.NewMessage(chatID, text)
 	return msg
 }
+
+// challengeShortAnswer پاسخ‌های کوتاه‌تر از این (بر حسب حرف) فقط با تطبیق دقیق پذیرفته می‌شوند؛
+// در کلمه‌های دو تا چهار حرفی یک حرف اختلاف کلمه دیگری می‌سازد
+const challengeShortAnswer = 5
+
+// isCorrectAnswer تطبیق دقیق/شامل، سپس فاصله ویرایشی کم (حدود ۲۰٪ طول پاسخ)،
+// و فقط وقتی این بررسی‌ها قطعی نیستند و داور فعال است، پرسیدن «به‌اندازه کافی نزدیک» از هوش مصنوعی
+func (d *DailyChallengeCommand) isCorrectAnswer(chatID int64, userID int64, answer string, correct string, category string) bool {
+	length := len([]rune(correct))
+	if length < challengeShortAnswer {
+		// پاسخ کوتاه باید به‌صورت یک کلمه کامل در پیام آمده باشد
+		return strings.Contains(" "+answer+" ", " "+correct+" ")
+	}
+	if answer == correct || strings.Contains(answer, correct) {
+		return true
+	}
+
+	distance := levenshtein(answer, correct)
+	if distance <= length/5 {
+		return true
+	}
+	// داور هوش مصنوعی فقط برای پاسخ‌هایی که حداقل نیمی از آن درست است
+	if distance > length/2 {
+		return false
+	}
+	return d.judgeAnswer(chatID, userID, answer, correct, category)
+}
+
+// judgeAnswer پرسیدن «به‌اندازه کافی نزدیک» از هوش مصنوعی از طریق صف مشترک، به حساب کاربر پاسخ‌دهنده.
+// اگر سهمیه درخواست کاربر تمام شده باشد فقط تطبیق دقیق (که پیش‌تر رد شده) معتبر است؛ بودجه توکن در Run بررسی می‌شود
+func (d *DailyChallengeCommand) judgeAnswer(chatID int64, userID int64, answer string, correct string, category string) bool {
+	if enabled, err := d.storage.IsFeatureEnabled(chatID, featureChallengeAIJudge); err != nil || !enabled || !d.ai.Available() {
+		return false
+	}
+	if allowed, _ := d.rateLimiter.CheckRateLimit(userID); !allowed {
+		return false
+	}
+	prompt, version, err := d.ai.Prompt("challenge_judge", map[string]any{
+		"Category": challengeCategoryTitle(category),
+		"Correct":  correct,
+		"Answer":   answer,
+	})
+	if err != nil {
+		log.Printf("daily challenge: judge prompt error: %v", err)
+		return false
+	}
+	job, err := d.ai.Enqueue(chatID, userID)
+	if err != nil {
+		// کاربری که صفش پر است با پاسخ‌های پشت‌سرهم داور را صدا نمی‌زند
+		return false
+	}
+	verdict, err := d.ai.Run(job, AIRequest{
+		ChatID:        chatID,
+		UserID:        userID,
+		Command:       "challenge_judge",
+		Prompt:        prompt,
+		PromptVersion: version,
+	})
+	if err != nil {
+		log.Printf("daily challenge: ai judge error: %v", err)
+		return false
+	}
+	verdict = strings.ToLower(strings.TrimSpace(verdict))
+	return strings.HasPrefix(verdict, "yes") || strings.HasPrefix(verdict, "بله")
+}
+
+// normalizeAnswer نرمال‌سازی پاسخ برای مقایسه: حروف عربی/فارسی، فاصله‌ها و علائم نگارشی
+func normalizeAnswer(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.ToLower(normalizePersian(s))
+}
+
+// levenshtein فاصله ویرایشی دو رشته بر حسب حرف
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
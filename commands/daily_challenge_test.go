@@ -0,0 +1,31 @@
+package commands
+
+import "testing"
+
+// فقط حالت‌های قطعی بررسی می‌شوند؛ حالت‌های نیازمند داور به storage و هوش مصنوعی نیاز دارند
+func TestIsCorrectAnswerConclusive(t *testing.T) {
+	d := &DailyChallengeCommand{}
+	tests := []struct {
+		name    string
+		answer  string
+		correct string
+		want    bool
+	}{
+		{"short exact", "قم", "قم", true},
+		{"short as whole word", "فکر کنم قم باشه", "قم", true},
+		{"short one letter off", "قسم", "قم", false},
+		{"short substring of another word", "مقاومت", "قم", false},
+		{"short with extra letter", "یزدی", "یزد", false},
+		{"long exact", normalizeAnswer("آب که از سر گذشت"), normalizeAnswer("آب که از سر گذشت"), true},
+		{"long contained", normalizeAnswer("جوابش: آب که از سر گذشت"), normalizeAnswer("آب که از سر گذشت"), true},
+		{"long small typo", normalizeAnswer("آب که از سر گذشته"), normalizeAnswer("آب که از سر گذشت"), true},
+		{"long unrelated", normalizeAnswer("نمی‌دونم والا"), normalizeAnswer("آب که از سر گذشت"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.isCorrectAnswer(0, 0, tt.answer, tt.correct, "proverb"); got != tt.want {
+				t.Fatalf("isCorrectAnswer(%q, %q) = %v, want %v", tt.answer, tt.correct, got, tt.want)
+			}
+		})
+	}
+}
@@ -39,6 +39,10 @@ func (r *GapCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🧩 چلنج روزانه", "daily_challenge_menu"),
 		),
+		// شخصیت هوش مصنوعی
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🤖 شخصیت هوش مصنوعی", "persona_menu"),
+		),
 		// ردیف چهارم - قفل‌ها
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔒 قفل", "locks"),
@@ -116,24 +120,30 @@ func (r *GapCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackCon
 					}
 				}(), "toggle_daily_challenge"),
 			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🌐 ابزارهای متن", "text_tools_menu"),
+			),
 		)
 		msg.Text = "🎛️ تنظیمات قابلیت‌ها:\n\nبا دکمه‌های زیر می‌توانید قابلیت‌ها را فعال/غیرفعال کنید."
 		msg.ReplyMarkup = featuresKeyboard
 
 	case "daily_challenge_menu":
-		// وضعیت فعلی را از storage بخوانیم
-		enabled, _ := r.storage.IsFeatureEnabled(chatID, "daily_challenge")
-		icon := "❌"
-		if enabled {
-			icon = "✅"
+		msg.Text = "🧩 تنظیمات چلنج روزانه:\n\nبا فعال بودن، هر روز ساعت ۱۰ به وقت ایران یک ایموجی ارسال می‌شود تا ضرب‌المثل را حدس بزنید.\n\n🤖 با ساخت هوش مصنوعی، معماهای تازه از ضرب‌المثل، فیلم، آهنگ و شهر ساخته می‌شود.\n⚖️ با داور هوش مصنوعی، پاسخ‌های نزدیک (مثلاً با غلط املایی) هم بررسی می‌شوند."
+		msg.ReplyMarkup = r.dailyChallengeKeyboard(chatID)
+
+	case "toggle_challenge_ai", "toggle_challenge_ai_judge":
+		feature := strings.TrimPrefix(data, "toggle_")
+		enabled, err := r.storage.IsFeatureEnabled(chatID, feature)
+		if err != nil {
+			msg.Text = "❌ خطا در بررسی وضعیت چلنج"
+			break
 		}
-		kb := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("🧩 فعال‌سازی خودکار روزانه "+icon, "toggle_daily_challenge"),
-			),
-		)
-		msg.Text = "🧩 تنظیمات چلنج روزانه:\n\nبا فعال بودن، هر روز ساعت ۱۰ به وقت ایران یک ایموجی ارسال می‌شود تا ضرب‌المثل را حدس بزنید."
-		msg.ReplyMarkup = kb
+		if err := r.storage.SetFeatureEnabled(chatID, feature, !enabled); err != nil {
+			msg.Text = "❌ خطا در تغییر وضعیت چلنج"
+			break
+		}
+		msg.Text = "وضعیت چلنج روزانه به‌روزرسانی شد."
+		msg.ReplyMarkup = r.dailyChallengeKeyboard(chatID)
 
 	case "toggle_daily_challenge":
 		enabled, err := r.storage.IsFeatureEnabled(chatID, "daily_challenge")
@@ -146,18 +156,26 @@ func (r *GapCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackCon
 			break
 		}
 		// بازسازی منو
-		newEnabled, _ := r.storage.IsFeatureEnabled(chatID, "daily_challenge")
-		icon := "❌"
-		if newEnabled {
-			icon = "✅"
-		}
-		kb := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("🧩 فعال‌سازی خودکار روزانه "+icon, "toggle_daily_challenge"),
-			),
-		)
 		msg.Text = "وضعیت چلنج روزانه به‌روزرسانی شد."
-		msg.ReplyMarkup = kb
+		msg.ReplyMarkup = r.dailyChallengeKeyboard(chatID)
+
+	case "text_tools_menu":
+		msg.Text = "🌐 ابزارهای متن:\n\nبا ریپلای روی یک پیام:\n• «ترجمه [زبان]» یا /tr — ترجمه پیام\n• «فارسی کن» — تبدیل فینگلیش به فارسی\n• «رسمی کن» — بازنویسی رسمی"
+		msg.ReplyMarkup = r.textToolsKeyboard(chatID)
+
+	case "toggle_translate", "toggle_finglish", "toggle_formal":
+		feature := strings.TrimPrefix(data, "toggle_")
+		enabled, err := r.storage.IsFeatureEnabled(chatID, feature)
+		if err != nil {
+			msg.Text = "❌ خطا در بررسی وضعیت قابلیت"
+			break
+		}
+		if err := r.storage.SetFeatureEnabled(chatID, feature, !enabled); err != nil {
+			msg.Text = "❌ خطا در تغییر وضعیت قابلیت"
+			break
+		}
+		msg.Text = "وضعیت ابزارهای متن به‌روزرسانی شد."
+		msg.ReplyMarkup = r.textToolsKeyboard(chatID)
 
 	case "status":
 		// نمایش وضعیت ربات
@@ -429,6 +447,17 @@ func (r *GapCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackCon
 					}
 				}(), "toggle_badword"),
 			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔗 تنظیمات لینک", "link_menu"),
+				tgbotapi.NewInlineKeyboardButtonData("🤖 کپچای ورود", "captcha_menu"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("⚠️ اخطار و پلکان", "warn_menu"),
+				tgbotapi.NewInlineKeyboardButtonData("🌊 ضد اسپم", "flood_menu"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🖼 قفل رسانه و فوروارد", "media_menu"),
+			),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("🔇 راهنمای سکوت", "mute_help"),
 			),
@@ -440,16 +469,22 @@ func (r *GapCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackCon
 		msg.Text = `🔇 راهنمای سکوت کاربر
 
 برای سکوت کردن یک کاربر:
-1) روی پیام او ریپلای کنید
-2) بدون اسلش بنویسید: سکوت [ساعت]
+1) روی پیام او ریپلای کنید (یا او را با @نام یا شناسه مشخص کنید)
+2) بدون اسلش بنویسید: سکوت [مدت]
 
 نمونه‌ها:
 - سکوت 1  (سکوت یک‌ساعته)
+- سکوت 30m یا سکوت ۲ ساعت یا سکوت ۱ روز
+- سکوت @user 2h
 - سکوت    (سکوت نامحدود)
 
-برای خارج کردن از سکوت:
-1) روی پیام او ریپلای کنید
-2) بنویسید: آزاد`
+برای خارج کردن از سکوت: آزاد (روی ریپلای یا آزاد @user)
+برای بن: بن [مدت با واحد مثل 3d] — برای برداشتن بن: رفع بن @user
+
+برای اخطار (با سکوت/بن خودکار طبق پلکان):
+- اخطار [دلیل]
+- اخطارها
+- حذف اخطار [همه]`
 
 	case "toggle_clown":
 		// تغییر وضعیت دلقک
@@ -603,3 +638,39 @@ func (r *GapCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackCon
 	// تایید دریافت callback
 	return tgbotapi.NewCallback(update.CallbackQuery.ID, "✅")
 }
+
+// dailyChallengeKeyboard منوی تنظیمات چلنج روزانه با وضعیت فعلی هر گزینه
+func (r *GapCommand) dailyChallengeKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	enabled, _ := r.storage.IsFeatureEnabled(chatID, "daily_challenge")
+	aiEnabled, _ := r.storage.IsFeatureEnabled(chatID, featureChallengeAI)
+	judgeEnabled, _ := r.storage.IsFeatureEnabled(chatID, featureChallengeAIJudge)
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🧩 فعال‌سازی خودکار روزانه "+boolIcon(enabled), "toggle_daily_challenge"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🤖 ساخت معما با هوش مصنوعی "+boolIcon(aiEnabled), "toggle_challenge_ai"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚖️ داور هوش مصنوعی "+boolIcon(judgeEnabled), "toggle_challenge_ai_judge"),
+		),
+	)
+}
+
+// textToolsKeyboard منوی ابزارهای متن (ترجمه، فینگلیش، رسمی‌نویسی) با وضعیت فعلی هر گزینه
+func (r *GapCommand) textToolsKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	translateEnabled, _ := r.storage.IsFeatureEnabled(chatID, featureTranslate)
+	finglishEnabled, _ := r.storage.IsFeatureEnabled(chatID, featureFinglish)
+	formalEnabled, _ := r.storage.IsFeatureEnabled(chatID, featureFormal)
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌐 ترجمه "+boolIcon(translateEnabled), "toggle_translate"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔤 فارسی کن "+boolIcon(finglishEnabled), "toggle_finglish"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👔 رسمی کن "+boolIcon(formalEnabled), "toggle_formal"),
+		),
+	)
+}
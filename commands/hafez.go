@@ -8,12 +8,25 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+
+	"redhat-bot/limiter"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// hafezIntentFlow گفتگوی پرسیدن نیت برای «فال با نیت»
+const hafezIntentFlow = "hafez_intent"
+
+// hafezIntentTrigger تریگر بدون اسلش فال با نیت
+const hafezIntentTrigger = "فال با نیت"
+
 type HafezCommand struct {
-	bot *tgbotapi.BotAPI
+	bot           *tgbotapi.BotAPI
+	ai            *AIService
+	rateLimiter   *limiter.RateLimiter
+	conversations *ConversationManager
 }
 
 type FalResponse struct {
@@ -22,47 +35,61 @@ type FalResponse struct {
 	Interpreter string `json:"interpreter"`
 }
 
-func NewHafezCommand(bot *tgbotapi.BotAPI) *HafezCommand {
-	return &HafezCommand{
-		bot: bot,
+func NewHafezCommand(bot *tgbotapi.BotAPI, aiService *AIService, rateLimiter *limiter.RateLimiter, conversations *ConversationManager) *HafezCommand {
+	r := &HafezCommand{
+		bot:           bot,
+		ai:            aiService,
+		rateLimiter:   rateLimiter,
+		conversations: conversations,
 	}
+	conversations.Register(hafezIntentFlow, 10*time.Minute, r.handleIntentStep)
+	return r
 }
 
 func (r *HafezCommand) getHafezFal() (string, error) {
+	fal, err := r.randomFal()
+	if err != nil || fal == nil {
+		return "", err
+	}
+	return formatFal(fal), nil
+}
+
+// randomFal انتخاب یک غزل تصادفی از fal.json؛ nil یعنی فایل خالی است
+func (r *HafezCommand) randomFal() (*FalResponse, error) {
 	// خواندن فایل JSON
 	jsonFile, err := os.Open(filepath.Join("jsonfile", "fal.json"))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer jsonFile.Close()
 
 	// خواندن محتوای فایل
 	byteValue, err := io.ReadAll(jsonFile)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	var fals []FalResponse
 	if err := json.Unmarshal(byteValue, &fals); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// اگر آرایه خالی باشد
 	if len(fals) == 0 {
-		return "", nil
+		return nil, nil
 	}
 
 	// انتخاب یک فال رندوم
-	randomIndex := rand.Intn(len(fals))
-	fal := fals[randomIndex]
+	fal := fals[rand.Intn(len(fals))]
+	return &fal, nil
+}
 
-	// ساختن متن فال
-	result := "🎭 *فال حافظ*\n\n" +
+// formatFal متن فال با تفسیر آماده (Markdown)
+func formatFal(fal *FalResponse) string {
+	return "🎭 *فال حافظ*\n\n" +
 		"📜 *عنوان فال:* " + fal.Title + "\n" +
 		"🔢 *شماره فال:* " + strconv.Itoa(fal.Id) + "\n\n" +
 		"📝 *تفسیر فال:*\n" + fal.Interpreter
-
-	return result, nil
 }
 
 func (r *HafezCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
@@ -97,6 +124,133 @@ func (r *HafezCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
 	return msg
 }
 
+// IsIntentTrigger آیا متن دستور «فال با نیت» است؟
+func (r *HafezCommand) IsIntentTrigger(text string) bool {
+	text = strings.TrimSpace(text)
+	return text == hafezIntentTrigger || strings.HasPrefix(text, hafezIntentTrigger+" ") || strings.HasPrefix(text, hafezIntentTrigger+"\n")
+}
+
+// HandleIntent «فال با نیت [نیت]»؛ نیت از متن دستور، پیام ریپلای‌شده یا در مرحله بعد گرفته می‌شود
+func (r *HafezCommand) HandleIntent(update tgbotapi.Update) tgbotapi.MessageConfig {
+	message := update.Message
+	chatID := message.Chat.ID
+	userID := message.From.ID
+
+	intent := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(message.Text), hafezIntentTrigger))
+	if intent == "" && message.ReplyToMessage != nil {
+		intent = strings.TrimSpace(message.ReplyToMessage.Text)
+	}
+	if intent != "" {
+		return r.interpret(chatID, userID, message.MessageID, intent)
+	}
+
+	if err := r.conversations.Start(chatID, userID, hafezIntentFlow, "intent", nil); err != nil {
+		log.Printf("Error starting hafez conversation: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ متأسفانه در دریافت فال خطایی رخ داد. لطفاً دوباره تلاش کنید.")
+	}
+	msg := tgbotapi.NewMessage(chatID, "🙏 نیت کن و نیتت رو همین‌جا بنویس تا برات فال بگیرم.")
+	msg.ReplyToMessageID = message.MessageID
+	msg.ReplyMarkup = conversationCancelKeyboard()
+	return msg
+}
+
+// handleIntentStep دریافت نیت کاربر در گفتگوی فال با نیت
+func (r *HafezCommand) handleIntentStep(update tgbotapi.Update, conv *Conversation) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	intent := strings.TrimSpace(update.Message.Text)
+	if intent == "" {
+		return tgbotapi.NewMessage(chatID, "🙏 لطفاً نیتت رو به صورت متن بنویس (یا «لغو»).")
+	}
+	conv.Finish()
+	return r.interpret(chatID, update.Message.From.ID, update.Message.MessageID, intent)
+}
+
+// interpret انتخاب غزل و نوشتن تفسیر شخصی با هوش مصنوعی بر پایه عنوان و تفسیر آماده همان غزل.
+// اگر هوش مصنوعی در دسترس نباشد یا خطا بدهد، همان تفسیر آماده فرستاده می‌شود.
+func (r *HafezCommand) interpret(chatID int64, userID int64, replyTo int, intent string) tgbotapi.MessageConfig {
+	if len([]rune(intent)) > 300 {
+		return tgbotapi.NewMessage(chatID, "🙏 نیتت رو کوتاه‌تر بنویس لطفاً (حداکثر ۳۰۰ کاراکتر).")
+	}
+
+	// بررسی محدودیت درخواست
+	if allowed, message := r.rateLimiter.CheckRateLimit(userID); !allowed {
+		return tgbotapi.NewMessage(chatID, message)
+	}
+
+	fal, err := r.randomFal()
+	if err != nil || fal == nil {
+		log.Printf("Error getting hafez: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ متأسفانه در دریافت فال خطایی رخ داد. لطفاً دوباره تلاش کنید.")
+	}
+
+	if !r.ai.Available() {
+		return r.cannedFal(chatID, replyTo, fal)
+	}
+
+	system, systemVersion, err := r.ai.Prompt("hafez_system", nil)
+	if err != nil {
+		log.Printf("Error rendering hafez prompt: %v", err)
+		return r.cannedFal(chatID, replyTo, fal)
+	}
+	prompt, version, err := r.ai.Prompt("hafez", map[string]any{
+		"Intent":         intent,
+		"Title":          fal.Title,
+		"Number":         fal.Id,
+		"Interpretation": fal.Interpreter,
+	})
+	if err != nil {
+		log.Printf("Error rendering hafez prompt: %v", err)
+		return r.cannedFal(chatID, replyTo, fal)
+	}
+
+	// ثبت در صف و ارسال پیام "در حال پردازش"
+	job, err := r.ai.Begin(chatID, userID, replyTo)
+	if err != nil {
+		log.Printf("Error queueing hafez interpretation: %v", err)
+		return r.cannedFal(chatID, replyTo, fal)
+	}
+
+	result, err := r.ai.Run(job, AIRequest{
+		ChatID:        chatID,
+		UserID:        userID,
+		Command:       "hafez",
+		Prompt:        prompt,
+		System:        system,
+		PromptVersion: systemVersion + "," + version,
+	})
+	if err != nil {
+		log.Printf("Error interpreting hafez: %v", err)
+		if job.MessageID != 0 {
+			r.bot.Send(tgbotapi.NewDeleteMessage(chatID, job.MessageID))
+		}
+		return r.cannedFal(chatID, replyTo, fal)
+	}
+
+	text := "🎭 فال حافظ با نیت\n\n" +
+		"🙏 نیت: " + intent + "\n" +
+		"📜 عنوان فال: " + fal.Title + "\n" +
+		"🔢 شماره فال: " + strconv.Itoa(fal.Id) + "\n\n" +
+		"🔮 تفسیر برای نیت شما:\n" + strings.TrimSpace(result)
+	if job.MessageID != 0 {
+		_, err = r.bot.Send(tgbotapi.NewEditMessageText(chatID, job.MessageID, text))
+		if err == nil {
+			return tgbotapi.MessageConfig{}
+		}
+		log.Printf("Error editing hafez message: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyToMessageID = replyTo
+	return msg
+}
+
+// cannedFal تفسیر آماده فال وقتی تفسیر هوشمند ممکن نیست
+func (r *HafezCommand) cannedFal(chatID int64, replyTo int, fal *FalResponse) tgbotapi.MessageConfig {
+	msg := tgbotapi.NewMessage(chatID, "⚠️ تفسیر شخصی در حال حاضر در دسترس نیست؛ تفسیر سنتی همین غزل:\n\n"+formatFal(fal))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyToMessageID = replyTo
+	return msg
+}
+
 // HandleCallback handles the callback queries from inline keyboard
 func (r *HafezCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
 	if update.CallbackQuery.Data == "new_hafez" {
@@ -117,6 +271,7 @@ func (r *HafezCommand) HandleStart() string {
 
 📜 *دستورات:*
 • /فال - گرفتن فال حافظ
+• فال با نیت <نیت> - تفسیر شخصی غزل برای نیت شما
 • دکمه "فال جدید" - گرفتن فال جدید
 
 💫 *ویژگی‌ها:*
@@ -0,0 +1,217 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"redhat-bot/ai"
+	"redhat-bot/config"
+	"redhat-bot/limiter"
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// انواع نتیجه inline؛ پیشوند شناسه نتیجه هم هستند
+const (
+	inlineKindFal  = "fal"
+	inlineKindJoke = "joke"
+	inlineKindAsk  = "ask"
+)
+
+// InlineCommand حالت inline: «@bot فال»، «@bot جوک <موضوع>» و «@bot <سوال>» در هر چتی.
+// پاسخ هوش مصنوعی فقط برای نتیجه‌ای ساخته می‌شود که کاربر انتخاب کند (ChosenInlineResult)؛
+// برای دریافت آن باید inline feedback ربات در BotFather فعال باشد.
+type InlineCommand struct {
+	ai          *AIService
+	hafez       *HafezCommand
+	rateLimiter *limiter.RateLimiter
+	storage     *storage.MySQLStorage
+	bot         *tgbotapi.BotAPI
+	cache       *ai.Cache // پاسخ‌های آماده به ازای هر کوئری
+}
+
+func NewInlineCommand(aiService *AIService, hafez *HafezCommand, rateLimiter *limiter.RateLimiter, storage *storage.MySQLStorage, bot *tgbotapi.BotAPI) *InlineCommand {
+	return &InlineCommand{
+		ai:          aiService,
+		hafez:       hafez,
+		rateLimiter: rateLimiter,
+		storage:     storage,
+		bot:         bot,
+		cache:       ai.NewCache(1),
+	}
+}
+
+// HandleQuery پاسخ به InlineQuery
+func (r *InlineCommand) HandleQuery(update tgbotapi.Update) {
+	query := update.InlineQuery
+	text := strings.TrimSpace(query.Query)
+
+	var results []interface{}
+	cacheTime := 0
+	switch {
+	case text == "":
+		results = r.helpResults()
+		cacheTime = 3600
+
+	case text == "فال":
+		fal, err := r.hafez.getHafezFal()
+		if err != nil || fal == "" {
+			log.Printf("Error getting hafez for inline: %v", err)
+			return
+		}
+		article := tgbotapi.NewInlineQueryResultArticleMarkdown(r.resultID(inlineKindFal, fmt.Sprint(time.Now().UnixNano())), "🎭 فال حافظ", fal)
+		article.Description = "یک فال تصادفی از دیوان حافظ"
+		results = append(results, article)
+
+	default:
+		kind, prompt, title := inlineKindAsk, text, "🤖 پاسخ هوش مصنوعی"
+		// «جوکر» و مانند آن سؤال عادی است، نه درخواست جوک
+		if CommandMatches(text, "جوک") {
+			topic := strings.TrimSpace(strings.TrimPrefix(text, "جوک"))
+			if topic == "" {
+				results = r.helpResults()
+				break
+			}
+			kind, prompt, title = inlineKindJoke, topic, "😄 جوک درباره «"+topic+"»"
+		}
+		results = append(results, r.aiResult(query.From.ID, kind, prompt, title))
+	}
+
+	if len(results) == 0 {
+		return
+	}
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     cacheTime,
+		IsPersonal:    true,
+	}
+	if _, err := r.bot.Request(answer); err != nil {
+		log.Printf("Error answering inline query: %v", err)
+	}
+}
+
+// aiResult نتیجه آماده از کش، یا نتیجه «در حال آماده‌سازی» که پس از انتخاب ویرایش می‌شود
+func (r *InlineCommand) aiResult(userID int64, kind string, prompt string, title string) tgbotapi.InlineQueryResultArticle {
+	id := r.resultID(kind, prompt)
+
+	if answer, ok := r.cache.Get(r.cacheKey(kind, prompt)); ok {
+		article := tgbotapi.NewInlineQueryResultArticle(id, title, answer)
+		article.Description = "پاسخ آماده"
+		return article
+	}
+
+	if allowed, message := r.rateLimiter.CheckRateLimit(userID); !allowed {
+		article := tgbotapi.NewInlineQueryResultArticle(id, "⚠️ محدودیت درخواست", message)
+		article.Description = message
+		return article
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticle(id, title, "⏳ در حال آماده‌سازی پاسخ…\n\n❓ "+prompt)
+	article.Description = "برای ارسال و دریافت پاسخ انتخاب کنید"
+	// بدون دکمه، تلگرام شناسه پیام inline را برای ویرایش بعدی نمی‌فرستد
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("🤖 کوو", "https://t.me/"+r.bot.Self.UserName),
+		),
+	)
+	article.ReplyMarkup = &keyboard
+	return article
+}
+
+// HandleChosen ثبت آمار انتخاب و ساخت پاسخ هوش مصنوعی برای نتیجه در حال آماده‌سازی
+func (r *InlineCommand) HandleChosen(update tgbotapi.Update) {
+	chosen := update.ChosenInlineResult
+	userID := chosen.From.ID
+	kind, _, _ := strings.Cut(chosen.ResultID, ":")
+	text := strings.TrimSpace(chosen.Query)
+
+	if err := r.storage.SaveInlineChoice(userID, kind, text); err != nil {
+		log.Printf("Error saving inline choice: %v", err)
+	}
+
+	// نتیجه‌های آماده (فال یا پاسخ از کش) دکمه و شناسه پیام ندارند
+	if chosen.InlineMessageID == "" || (kind != inlineKindJoke && kind != inlineKindAsk) {
+		return
+	}
+
+	command, data := "covo", map[string]any{"Question": text}
+	if kind == inlineKindJoke {
+		topic := strings.TrimSpace(strings.TrimPrefix(text, "جوک"))
+		command, data = "cj", map[string]any{"Topic": topic}
+		text = topic
+	}
+
+	// بررسی محدودیت درخواست
+	if allowed, message := r.rateLimiter.CheckRateLimit(userID); !allowed {
+		r.editInline(chosen.InlineMessageID, message)
+		return
+	}
+
+	answer, err := r.generate(userID, command, data)
+	if err != nil {
+		log.Printf("Error generating inline answer: %v", err)
+		r.editInline(chosen.InlineMessageID, aiErrorText(err, "❌ متأسفانه در آماده‌سازی پاسخ مشکلی پیش آمد. لطفاً دوباره تلاش کنید."))
+		return
+	}
+	r.cache.Add(r.cacheKey(kind, text), answer, time.Duration(config.AppConfig.AIInlineCacheMinutes)*time.Minute)
+	r.editInline(chosen.InlineMessageID, answer)
+}
+
+func (r *InlineCommand) generate(userID int64, command string, data map[string]any) (string, error) {
+	if !r.ai.Available() {
+		return "", ai.ErrUnavailable
+	}
+	prompt, version, err := r.ai.Prompt(command, data)
+	if err != nil {
+		return "", err
+	}
+	job, err := r.ai.Enqueue(userID, userID)
+	if err != nil {
+		return "", err
+	}
+	return r.ai.Run(job, AIRequest{
+		ChatID:        userID,
+		UserID:        userID,
+		Command:       command,
+		Prompt:        prompt,
+		PromptVersion: version,
+	})
+}
+
+func (r *InlineCommand) editInline(inlineMessageID string, text string) {
+	edit := tgbotapi.EditMessageTextConfig{
+		BaseEdit: tgbotapi.BaseEdit{InlineMessageID: inlineMessageID},
+		Text:     text,
+	}
+	if _, err := r.bot.Request(edit); err != nil {
+		log.Printf("Error editing inline message: %v", err)
+	}
+}
+
+// helpResults راهنمای استفاده وقتی کوئری خالی یا ناقص است
+func (r *InlineCommand) helpResults() []interface{} {
+	username := "@" + r.bot.Self.UserName
+	fal := tgbotapi.NewInlineQueryResultArticle("help:fal", "🎭 فال حافظ", "برای فال حافظ بنویسید: "+username+" فال")
+	fal.Description = username + " فال"
+	joke := tgbotapi.NewInlineQueryResultArticle("help:joke", "😄 جوک", "برای جوک بنویسید: "+username+" جوک <موضوع>")
+	joke.Description = username + " جوک <موضوع>"
+	ask := tgbotapi.NewInlineQueryResultArticle("help:ask", "🤖 سوال از هوش مصنوعی", "برای پرسیدن سوال بنویسید: "+username+" <سوال>")
+	ask.Description = username + " <سوال>"
+	return []interface{}{fal, joke, ask}
+}
+
+// resultID شناسه نتیجه (حداکثر ۶۴ بایت): نوع نتیجه + هش کوئری
+func (r *InlineCommand) resultID(kind string, query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return kind + ":" + hex.EncodeToString(sum[:12])
+}
+
+func (r *InlineCommand) cacheKey(kind string, query string) string {
+	return kind + ":" + strings.ToLower(normalizePersian(query))
+}
@@ -0,0 +1,418 @@
+package commands
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// زیرتنظیمات قفل لینک در FeatureSetting؛ پیش‌فرض (غیرفعال) یعنی آن نوع لینک حذف می‌شود
+const (
+	featureLinkAllowInvite   = "link_allow_invite"   // لینک دعوت تلگرام (t.me/+… و joinchat)
+	featureLinkAllowExternal = "link_allow_external" // لینک سایت‌ها و t.me/نام
+	featureLinkAllowMention  = "link_allow_mention"  // منشن @کانال یا @گروه
+)
+
+// نوع لینک پیداشده در پیام
+const (
+	linkKindInvite = iota
+	linkKindExternal
+	linkKindMention
+)
+
+var linkKindFeatures = map[int]string{
+	linkKindInvite:   featureLinkAllowInvite,
+	linkKindExternal: featureLinkAllowExternal,
+	linkKindMention:  featureLinkAllowMention,
+}
+
+var linkKindNames = map[int]string{
+	linkKindInvite:   "لینک دعوت",
+	linkKindExternal: "ارسال لینک",
+	linkKindMention:  "تبلیغ کانال/گروه",
+}
+
+var telegramLinkHosts = map[string]bool{
+	"t.me":         true,
+	"telegram.me":  true,
+	"telegram.dog": true,
+}
+
+// linkTarget لینک یا منشن پیداشده در پیام
+type linkTarget struct {
+	kind     int
+	host     string // برای لینک خارجی
+	username string // برای منشن و t.me/نام، با @ و حروف کوچک
+}
+
+// mentionChat نتیجه کش‌شده بررسی اینکه @نام متعلق به کانال یا گروه است
+type mentionChat struct {
+	isChat    bool
+	checkedAt time.Time
+}
+
+// LinkFilter تشخیص لینک با entityهای تلگرام (url، text_link، mention) و فهرست مجاز دامنه‌ها و @نام‌های هر گروه
+type LinkFilter struct {
+	bot        *tgbotapi.BotAPI
+	storage    *storage.MySQLStorage
+	moderation *ModerationCommand
+
+	mu       sync.Mutex
+	mentions map[string]mentionChat
+}
+
+func NewLinkFilter(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage, moderation *ModerationCommand) *LinkFilter {
+	return &LinkFilter{
+		bot:        bot,
+		storage:    storage,
+		moderation: moderation,
+		mentions:   make(map[string]mentionChat),
+	}
+}
+
+// Violation اولین لینک غیرمجاز پیام (متن یا کپشن)؛ خروجی اول دلیل برای اخطار است
+func (f *LinkFilter) Violation(message *tgbotapi.Message) (string, bool) {
+	targets := messageLinks(message.Text, message.Entities)
+	targets = append(targets, messageLinks(message.Caption, message.CaptionEntities)...)
+	if len(targets) == 0 {
+		return "", false
+	}
+
+	chatID := message.Chat.ID
+	allowed := make(map[int]bool)
+	for kind, feature := range linkKindFeatures {
+		enabled, _ := f.storage.IsFeatureEnabled(chatID, feature)
+		allowed[kind] = enabled
+	}
+	allowlist, err := f.storage.ListLinkAllows(chatID)
+	if err != nil {
+		log.Printf("Error loading link allowlist: %v", err)
+	}
+
+	for _, t := range targets {
+		if allowed[t.kind] || linkAllowlisted(t, allowlist) {
+			continue
+		}
+		// منشن فقط وقتی تبلیغ است که @نام متعلق به کانال یا گروه عمومی باشد، نه کاربر
+		if t.kind == linkKindMention && !f.isPublicChat(t.username) {
+			continue
+		}
+		return linkKindNames[t.kind], true
+	}
+	return "", false
+}
+
+// isPublicChat آیا @نام متعلق به کانال یا گروه است؟ (با کش چندساعته)
+func (f *LinkFilter) isPublicChat(username string) bool {
+	f.mu.Lock()
+	cached, ok := f.mentions[username]
+	f.mu.Unlock()
+	if ok && time.Since(cached.checkedAt) < 6*time.Hour {
+		return cached.isChat
+	}
+
+	chat, err := f.bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{SuperGroupUsername: username}})
+	// برای کاربران عادی getChat خطا می‌دهد
+	isChat := err == nil && (chat.IsChannel() || chat.IsGroup() || chat.IsSuperGroup())
+	if !definiteChatLookup(err) {
+		// خطای شبکه یا سرور تلگرام کش نمی‌شود تا دفعه بعد دوباره بررسی شود
+		log.Printf("getChat error for %s: %v", username, err)
+		return false
+	}
+
+	f.mu.Lock()
+	f.mentions[username] = mentionChat{isChat: isChat, checkedAt: time.Now()}
+	f.mu.Unlock()
+	return isChat
+}
+
+// definiteChatLookup آیا نتیجه getChat قطعی است و می‌شود کشش کرد؟
+// پاسخ موفق یا خطای 4xx تلگرام (مثل chat not found) قطعی است؛ خطای شبکه، 429 و 5xx موقتی‌اند
+func definiteChatLookup(err error) bool {
+	if err == nil {
+		return true
+	}
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code >= 400 && apiErr.Code < 500 && apiErr.Code != 429
+}
+
+// messageLinks لینک‌ها و منشن‌های متن بر اساس entityها؛ اگر entity لینکی نبود، کلمات شبیه لینک بررسی می‌شوند
+func messageLinks(text string, entities []tgbotapi.MessageEntity) []linkTarget {
+	if text == "" && len(entities) == 0 {
+		return nil
+	}
+	var targets []linkTarget
+	hasURLEntity := false
+	for _, e := range entities {
+		switch {
+		case e.IsURL():
+			hasURLEntity = true
+			if t, ok := classifyLink(entityText(text, e)); ok {
+				targets = append(targets, t)
+			}
+		case e.IsTextLink():
+			hasURLEntity = true
+			if t, ok := classifyLink(e.URL); ok {
+				targets = append(targets, t)
+			}
+		case e.IsMention():
+			if username := strings.ToLower(entityText(text, e)); len(username) > 1 {
+				targets = append(targets, linkTarget{kind: linkKindMention, username: username})
+			}
+		}
+	}
+	if hasURLEntity {
+		return targets
+	}
+
+	// برای پیام‌هایی که entity ندارند (مثلاً ارسال‌شده توسط برخی کلاینت‌ها)، فقط کلماتی که صریحاً لینک هستند
+	for _, word := range strings.Fields(text) {
+		lower := strings.ToLower(word)
+		if strings.Contains(lower, "://") || strings.HasPrefix(lower, "www.") ||
+			strings.Contains(lower, "t.me/") || strings.Contains(lower, "telegram.me/") {
+			if t, ok := classifyLink(word); ok {
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets
+}
+
+// classifyLink تجزیه یک URL و تعیین نوع آن
+func classifyLink(raw string) (linkTarget, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return linkTarget{}, false
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return linkTarget{}, false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if host == "" {
+		return linkTarget{}, false
+	}
+	// بدون دامنه معتبر لینک حساب نمی‌شود، به‌جز لینک‌های tg:// که به چت تلگرامی می‌روند
+	if !strings.Contains(host, ".") && u.Scheme != "tg" {
+		return linkTarget{}, false
+	}
+
+	if telegramLinkHosts[host] {
+		path := strings.Trim(u.Path, "/")
+		switch {
+		case strings.HasPrefix(path, "+") || strings.HasPrefix(path, "joinchat"):
+			return linkTarget{kind: linkKindInvite, host: host}, true
+		case path != "":
+			name, _, _ := strings.Cut(path, "/")
+			return linkTarget{kind: linkKindExternal, host: host, username: "@" + strings.ToLower(name)}, true
+		}
+	}
+	if u.Scheme == "tg" {
+		return linkTarget{kind: linkKindInvite, host: "t.me"}, true
+	}
+	return linkTarget{kind: linkKindExternal, host: host}, true
+}
+
+// linkAllowlisted آیا لینک در فهرست مجاز گروه است؟ دامنه‌ها زیردامنه‌ها را هم شامل می‌شوند
+func linkAllowlisted(t linkTarget, allowlist []string) bool {
+	for _, value := range allowlist {
+		if strings.HasPrefix(value, "@") {
+			if t.username == value {
+				return true
+			}
+			continue
+		}
+		if t.kind == linkKindExternal && (t.host == value || strings.HasSuffix(t.host, "."+value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// entityText متن یک entity؛ offset و length تلگرام بر حسب واحدهای UTF-16 است
+func entityText(text string, e tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+}
+
+// normalizeLinkAllow تبدیل ورودی ادمین (دامنه، URL، @نام یا t.me/نام) به شکل ذخیره‌شده
+func normalizeLinkAllow(input string) (string, bool) {
+	input = strings.TrimSpace(input)
+	if strings.HasPrefix(input, "@") {
+		name := strings.ToLower(input)
+		if len(name) < 2 || strings.ContainsAny(name[1:], " /@") {
+			return "", false
+		}
+		return name, true
+	}
+	t, ok := classifyLink(input)
+	if !ok || t.kind == linkKindInvite {
+		return "", false
+	}
+	if t.username != "" {
+		return t.username, true
+	}
+	return t.host, true
+}
+
+// IsCommand آیا متن یکی از دستورات «لینک مجاز»، «حذف لینک مجاز» یا «لیست لینک مجاز» است؟
+func (f *LinkFilter) IsCommand(text string) bool {
+	text = strings.TrimSpace(text)
+	return text == "لیست لینک مجاز" || strings.HasPrefix(text, "لینک مجاز") || strings.HasPrefix(text, "حذف لینک مجاز")
+}
+
+// IsAdminCommand دستور فهرست لینک مجاز از طرف ادمین گروه؛ این پیام‌ها خودشان لینک دارند و نباید با قفل لینک حذف شوند
+func (f *LinkFilter) IsAdminCommand(message *tgbotapi.Message) bool {
+	if message.From == nil || !f.IsCommand(message.Text) {
+		return false
+	}
+	isAdmin, err := f.moderation.isUserAdmin(message.Chat.ID, message.From.ID)
+	return err == nil && isAdmin
+}
+
+// HandleCommand مدیریت فهرست مجاز لینک گروه توسط ادمین‌ها
+func (f *LinkFilter) HandleCommand(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chat := update.Message.Chat
+	chatID := chat.ID
+
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
+	}
+	isAdmin, err := f.moderation.isUserAdmin(chatID, update.Message.From.ID)
+	if err != nil {
+		log.Printf("getChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !isAdmin {
+		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند فهرست لینک مجاز را تغییر دهند")
+	}
+
+	text := strings.TrimSpace(update.Message.Text)
+	if text == "لیست لینک مجاز" {
+		values, err := f.storage.ListLinkAllows(chatID)
+		if err != nil {
+			log.Printf("Error loading link allowlist: %v", err)
+			return tgbotapi.NewMessage(chatID, "❌ خطا در دریافت فهرست")
+		}
+		if len(values) == 0 {
+			return tgbotapi.NewMessage(chatID, "ℹ️ فهرست لینک مجاز این گروه خالی است\n\nافزودن: لینک مجاز <دامنه یا @نام>")
+		}
+		return tgbotapi.NewMessage(chatID, "✅ لینک‌های مجاز این گروه:\n\n• "+strings.Join(values, "\n• ")+"\n\nحذف: حذف لینک مجاز <دامنه یا @نام>")
+	}
+
+	removing := strings.HasPrefix(text, "حذف لینک مجاز")
+	arg := strings.TrimPrefix(strings.TrimPrefix(text, "حذف لینک مجاز"), "لینک مجاز")
+	value, ok := normalizeLinkAllow(arg)
+	if !ok {
+		return tgbotapi.NewMessage(chatID, "نحوه استفاده:\nلینک مجاز example.com\nلینک مجاز @channel\nحذف لینک مجاز <دامنه یا @نام>\nلیست لینک مجاز")
+	}
+
+	if removing {
+		removed, err := f.storage.DeleteLinkAllow(chatID, value)
+		if err != nil {
+			log.Printf("Error deleting link allow: %v", err)
+			return tgbotapi.NewMessage(chatID, "❌ خطا در حذف از فهرست")
+		}
+		if !removed {
+			return tgbotapi.NewMessage(chatID, "ℹ️ "+value+" در فهرست مجاز نیست")
+		}
+		return tgbotapi.NewMessage(chatID, "✅ "+value+" از فهرست مجاز حذف شد")
+	}
+
+	added, err := f.storage.AddLinkAllow(chatID, value, update.Message.From.ID)
+	if err != nil {
+		log.Printf("Error adding link allow: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در افزودن به فهرست")
+	}
+	if !added {
+		return tgbotapi.NewMessage(chatID, "ℹ️ "+value+" از قبل در فهرست مجاز است")
+	}
+	return tgbotapi.NewMessage(chatID, "✅ "+value+" به فهرست مجاز اضافه شد")
+}
+
+// BuildMenu منوی تنظیمات قفل لینک
+func (f *LinkFilter) BuildMenu(chatID int64) tgbotapi.MessageConfig {
+	enabled, _ := f.storage.IsFeatureEnabled(chatID, "link")
+	blocked := func(feature string) string {
+		allowed, _ := f.storage.IsFeatureEnabled(chatID, feature)
+		if allowed {
+			return "مجاز ✅"
+		}
+		return "حذف 🚫"
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔗 قفل لینک "+boolIcon(enabled), "link_toggle"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📨 لینک دعوت تلگرام: "+blocked(featureLinkAllowInvite), "link_allow:"+featureLinkAllowInvite),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌐 لینک خارجی: "+blocked(featureLinkAllowExternal), "link_allow:"+featureLinkAllowExternal),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📣 منشن کانال/گروه: "+blocked(featureLinkAllowMention), "link_allow:"+featureLinkAllowMention),
+		),
+	)
+	text := "🔗 تنظیمات قفل لینک\n\nبا فعال بودن قفل، لینک‌ها بر اساس نوع حذف می‌شوند." +
+		"\n\nدامنه‌ها و @نام‌های مجاز (زیردامنه‌ها هم مجازند):\n• لینک مجاز example.com\n• لینک مجاز @channel\n• حذف لینک مجاز <دامنه یا @نام>\n• لیست لینک مجاز"
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	return msg
+}
+
+// HandleCallback دکمه‌های منوی قفل لینک (link_*)؛ فقط ادمین‌های گروه
+func (f *LinkFilter) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	chatID := cq.Message.Chat.ID
+	data := cq.Data
+
+	if data != "link_menu" {
+		if isAdmin, err := f.moderation.isUserAdmin(chatID, cq.From.ID); err != nil || !isAdmin {
+			return tgbotapi.NewCallback(cq.ID, "❌ فقط ادمین‌های گروه می‌توانند تنظیمات را تغییر دهند")
+		}
+	}
+
+	feature := ""
+	switch {
+	case data == "link_menu":
+		// فقط نمایش منو
+	case data == "link_toggle":
+		feature = "link"
+	case strings.HasPrefix(data, "link_allow:"):
+		feature = strings.TrimPrefix(data, "link_allow:")
+		if feature != featureLinkAllowInvite && feature != featureLinkAllowExternal && feature != featureLinkAllowMention {
+			return tgbotapi.NewCallback(cq.ID, "تنظیم نامعتبر")
+		}
+	}
+	if feature != "" {
+		enabled, err := f.storage.IsFeatureEnabled(chatID, feature)
+		if err != nil {
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در بررسی وضعیت")
+		}
+		if err := f.storage.SetFeatureEnabled(chatID, feature, !enabled); err != nil {
+			log.Printf("Error toggling %s: %v", feature, err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در تغییر وضعیت")
+		}
+	}
+
+	f.bot.Send(f.BuildMenu(chatID))
+	return tgbotapi.NewCallback(cq.ID, "✅")
+}
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestDefiniteChatLookup(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"success", nil, true},
+		{"chat not found", &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, true},
+		{"wrapped 400", fmt.Errorf("getChat: %w", &tgbotapi.Error{Code: 400}), true},
+		{"rate limited", &tgbotapi.Error{Code: 429, Message: "Too Many Requests"}, false},
+		{"server error", &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}, false},
+		{"network", errors.New("dial tcp: i/o timeout"), false},
+	}
+	for _, c := range cases {
+		if got := definiteChatLookup(c.err); got != c.want {
+			t.Errorf("%s: definiteChatLookup = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// انواع محتوای قابل قفل؛ هر قفل در FeatureSetting با کلید lock_<نوع> و برخوردش در GroupSetting با lock_<نوع>_action ذخیره می‌شود
+const (
+	mediaSticker    = "sticker"
+	mediaAnimation  = "animation"
+	mediaPhoto      = "photo"
+	mediaVideo      = "video"
+	mediaVoice      = "voice" // ویس و ویدیو مسیج
+	mediaAudio      = "audio"
+	mediaDocument   = "document"
+	mediaPoll       = "poll"
+	mediaForward    = "forward"
+	mediaContact    = "contact" // مخاطب و موقعیت مکانی
+	mediaSenderChat = "sender_chat"
+
+	settingMediaMuteMinutes = "media_mute_minutes"
+)
+
+// mediaKinds ترتیب نمایش قفل‌ها در منو
+var mediaKinds = []string{
+	mediaSticker, mediaAnimation, mediaPhoto, mediaVideo, mediaVoice, mediaAudio,
+	mediaDocument, mediaPoll, mediaForward, mediaContact, mediaSenderChat,
+}
+
+var mediaKindNames = map[string]string{
+	mediaSticker:    "استیکر",
+	mediaAnimation:  "گیف",
+	mediaPhoto:      "عکس",
+	mediaVideo:      "فیلم",
+	mediaVoice:      "ویس و ویدیو مسیج",
+	mediaAudio:      "موسیقی",
+	mediaDocument:   "فایل",
+	mediaPoll:       "نظرسنجی",
+	mediaForward:    "فوروارد",
+	mediaContact:    "مخاطب و موقعیت",
+	mediaSenderChat: "ارسال با نام کانال",
+}
+
+var mediaKindIcons = map[string]string{
+	mediaSticker:    "🎭",
+	mediaAnimation:  "🎞",
+	mediaPhoto:      "🖼",
+	mediaVideo:      "🎬",
+	mediaVoice:      "🎙",
+	mediaAudio:      "🎵",
+	mediaDocument:   "📎",
+	mediaPoll:       "📊",
+	mediaForward:    "↪️",
+	mediaContact:    "📍",
+	mediaSenderChat: "📣",
+}
+
+// برخورد با پیام قفل‌شده؛ پیام در هر حالت حذف می‌شود
+const (
+	mediaActionDelete = iota
+	mediaActionWarn
+	mediaActionMute
+)
+
+var mediaActionOptions = []int{mediaActionDelete, mediaActionWarn, mediaActionMute}
+
+var mediaActionNames = map[int]string{
+	mediaActionDelete: "حذف",
+	mediaActionWarn:   "اخطار",
+	mediaActionMute:   "سکوت",
+}
+
+var mediaMuteMinuteOptions = []int{60, 10, 360, 1440}
+
+// MediaLocks قفل انواع محتوا (استیکر، گیف، عکس، فوروارد، ارسال با نام کانال و ...) با برخورد جداگانه برای هر قفل
+type MediaLocks struct {
+	bot        *tgbotapi.BotAPI
+	storage    *storage.MySQLStorage
+	moderation *ModerationCommand
+}
+
+func NewMediaLocks(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage, moderation *ModerationCommand) *MediaLocks {
+	return &MediaLocks{bot: bot, storage: storage, moderation: moderation}
+}
+
+func mediaFeature(kind string) string {
+	return "lock_" + kind
+}
+
+func mediaActionSetting(kind string) string {
+	return "lock_" + kind + "_action"
+}
+
+// messageMediaKinds انواع محتوای پیام؛ یک پیام می‌تواند چند نوع داشته باشد (مثلاً عکس فورواردشده)
+func messageMediaKinds(message *tgbotapi.Message) []string {
+	var kinds []string
+	// پیام‌های خودکار کانال متصل و ادمین‌های ناشناس (ارسال با نام خود گروه) قفل نمی‌شوند
+	if message.SenderChat != nil && message.SenderChat.ID != message.Chat.ID && !message.IsAutomaticForward {
+		kinds = append(kinds, mediaSenderChat)
+	}
+	if message.ForwardDate != 0 && !message.IsAutomaticForward {
+		kinds = append(kinds, mediaForward)
+	}
+	switch {
+	case message.Sticker != nil:
+		kinds = append(kinds, mediaSticker)
+	case message.Animation != nil:
+		// گیف‌ها در Document هم آمده‌اند؛ فقط به‌عنوان گیف حساب می‌شوند
+		kinds = append(kinds, mediaAnimation)
+	case len(message.Photo) > 0:
+		kinds = append(kinds, mediaPhoto)
+	case message.Video != nil:
+		kinds = append(kinds, mediaVideo)
+	case message.Voice != nil || message.VideoNote != nil:
+		kinds = append(kinds, mediaVoice)
+	case message.Audio != nil:
+		kinds = append(kinds, mediaAudio)
+	case message.Document != nil:
+		kinds = append(kinds, mediaDocument)
+	case message.Poll != nil:
+		kinds = append(kinds, mediaPoll)
+	case message.Contact != nil || message.Location != nil || message.Venue != nil:
+		kinds = append(kinds, mediaContact)
+	}
+	return kinds
+}
+
+// MessageContentType نوع محتوای پیام برای ثبت پیام‌ها: text یا یکی از انواع رسانه (sticker، photo و ...)
+func MessageContentType(message *tgbotapi.Message) string {
+	if message.Text != "" {
+		return "text"
+	}
+	for _, kind := range messageMediaKinds(message) {
+		if kind != mediaForward && kind != mediaSenderChat {
+			return kind
+		}
+	}
+	return "other"
+}
+
+// Check حذف پیام در صورت قفل بودن نوع آن و اجرای برخورد تنظیم‌شده؛ true یعنی پیام حذف شد
+func (l *MediaLocks) Check(message *tgbotapi.Message) bool {
+	// پست‌های کانال متصل که تلگرام خودکار به گروه گفتگو می‌فرستد هیچ قفلی ندارند؛ حذفشان نظرات کانال را از کار می‌اندازد
+	if message.From == nil || message.IsAutomaticForward {
+		return false
+	}
+	chatID := message.Chat.ID
+
+	locked := ""
+	for _, kind := range messageMediaKinds(message) {
+		if enabled, err := l.storage.IsFeatureEnabled(chatID, mediaFeature(kind)); err == nil && enabled {
+			locked = kind
+			break
+		}
+	}
+	if locked == "" {
+		return false
+	}
+	// ادمین‌ها معاف هستند؛ پیام ارسال‌شده با نام کانال از طرف کاربر سیستمی تلگرام است و بررسی نمی‌شود
+	isChannel := message.SenderChat != nil && message.SenderChat.ID != chatID
+	if !isChannel {
+		if isAdmin, err := l.moderation.isUserAdmin(chatID, message.From.ID); err != nil || isAdmin {
+			if err != nil {
+				log.Printf("getChatMember error (media lock): %v", err)
+			}
+			return false
+		}
+	}
+
+	if _, err := l.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: message.MessageID}); err != nil {
+		log.Printf("Error deleting locked message: %v", err)
+		return false
+	}
+	reason := "قفل " + mediaKindNames[locked]
+	l.moderation.DeletedByLock(message, "media", reason)
+
+	// کانال‌ها را نمی‌توان اخطار داد یا سکوت کرد؛ فقط پیامشان حذف می‌شود
+	if isChannel {
+		return true
+	}
+	user := message.From
+	var text string
+	switch groupIntSetting(l.storage, chatID, mediaActionSetting(locked), mediaActionOptions) {
+	case mediaActionWarn:
+		warnText, err := l.moderation.warn(ModerationAction{
+			ChatID:    chatID,
+			ChatTitle: message.Chat.Title,
+			Target:    user,
+			Reason:    reason,
+			Excerpt:   messageExcerpt(message),
+			Source:    modSourceLockMedia,
+		})
+		if err != nil {
+			log.Printf("Error adding media lock warning: %v", err)
+			return true
+		}
+		text = warnText
+	case mediaActionMute:
+		minutes := groupIntSetting(l.storage, chatID, settingMediaMuteMinutes, mediaMuteMinuteOptions)
+		duration := time.Duration(minutes) * time.Minute
+		if err := l.moderation.mute(chatID, user.ID, time.Now().Add(duration).Unix()); err != nil {
+			log.Printf("restrictChatMember (media lock) error: %v", err)
+			return true
+		}
+		l.moderation.Record(ModerationAction{
+			ChatID:    chatID,
+			ChatTitle: message.Chat.Title,
+			Target:    user,
+			Action:    modActionMute,
+			Reason:    reason,
+			Duration:  duration,
+			Source:    modSourceLockMedia,
+		})
+		text = fmt.Sprintf("🔇 %s به دلیل %s به مدت %s سکوت شد", warnUserName(user), reason, floodMinutesText(minutes))
+	default:
+		return true
+	}
+	if _, err := l.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("Error sending media lock message: %v", err)
+	}
+	return true
+}
+
+// BuildMenu منوی «قفل رسانه» در پنل قفل‌ها
+func (l *MediaLocks) BuildMenu(chatID int64) tgbotapi.MessageConfig {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	needsMute := false
+	for _, kind := range mediaKinds {
+		enabled, _ := l.storage.IsFeatureEnabled(chatID, mediaFeature(kind))
+		action := groupIntSetting(l.storage, chatID, mediaActionSetting(kind), mediaActionOptions)
+		if enabled && action == mediaActionMute {
+			needsMute = true
+		}
+		actionText := mediaActionNames[action]
+		if kind == mediaSenderChat {
+			actionText = mediaActionNames[mediaActionDelete]
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mediaKindIcons[kind]+" "+mediaKindNames[kind]+" "+boolIcon(enabled), "media_toggle:"+kind),
+			tgbotapi.NewInlineKeyboardButtonData("⚖️ "+actionText, "media_action:"+kind),
+		))
+	}
+	if needsMute {
+		minutes := groupIntSetting(l.storage, chatID, settingMediaMuteMinutes, mediaMuteMinuteOptions)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔇 مدت سکوت: "+floodMinutesText(minutes), "media_mute"),
+		))
+	}
+
+	text := "🖼 قفل رسانه\n\nبا فعال بودن هر قفل، آن نوع پیام حذف می‌شود و برخورد انتخاب‌شده (حذف، اخطار یا سکوت) اعمال می‌شود." +
+		"\nپیام‌های ارسال‌شده با نام کانال فقط حذف می‌شوند. ادمین‌ها معاف هستند."
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return msg
+}
+
+// HandleCallback دکمه‌های منوی قفل رسانه (media_*)؛ فقط ادمین‌های گروه
+func (l *MediaLocks) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	chatID := cq.Message.Chat.ID
+	data := cq.Data
+
+	if data != "media_menu" {
+		if isAdmin, err := l.moderation.isUserAdmin(chatID, cq.From.ID); err != nil || !isAdmin {
+			return tgbotapi.NewCallback(cq.ID, "❌ فقط ادمین‌های گروه می‌توانند تنظیمات را تغییر دهند")
+		}
+	}
+
+	switch {
+	case data == "media_menu":
+		// فقط نمایش منو
+
+	case strings.HasPrefix(data, "media_toggle:"):
+		kind := strings.TrimPrefix(data, "media_toggle:")
+		if _, ok := mediaKindNames[kind]; !ok {
+			return tgbotapi.NewCallback(cq.ID, "تنظیم نامعتبر")
+		}
+		enabled, err := l.storage.IsFeatureEnabled(chatID, mediaFeature(kind))
+		if err != nil {
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در بررسی وضعیت قفل")
+		}
+		if err := l.storage.SetFeatureEnabled(chatID, mediaFeature(kind), !enabled); err != nil {
+			log.Printf("Error toggling media lock %s: %v", kind, err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در تغییر وضعیت قفل")
+		}
+
+	case strings.HasPrefix(data, "media_action:"):
+		kind := strings.TrimPrefix(data, "media_action:")
+		if _, ok := mediaKindNames[kind]; !ok {
+			return tgbotapi.NewCallback(cq.ID, "تنظیم نامعتبر")
+		}
+		if kind == mediaSenderChat {
+			return tgbotapi.NewCallback(cq.ID, "برای کانال‌ها فقط حذف پیام ممکن است")
+		}
+		if err := cycleGroupIntSetting(l.storage, chatID, mediaActionSetting(kind), mediaActionOptions); err != nil {
+			log.Printf("Error saving media lock action: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در ذخیره تنظیمات")
+		}
+
+	case data == "media_mute":
+		if err := cycleGroupIntSetting(l.storage, chatID, settingMediaMuteMinutes, mediaMuteMinuteOptions); err != nil {
+			log.Printf("Error saving media lock setting: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در ذخیره تنظیمات")
+		}
+	}
+
+	l.bot.Send(l.BuildMenu(chatID))
+	return tgbotapi.NewCallback(cq.ID, "✅")
+}
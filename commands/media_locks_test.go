@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// پست خودکار کانال متصل پیش از هر بررسی قفل (و دسترسی به storage) رد می‌شود
+func TestMediaLocksCheckSkipsAutomaticForward(t *testing.T) {
+	l := &MediaLocks{}
+	chat := &tgbotapi.Chat{ID: -1001, Type: "supergroup"}
+	channel := &tgbotapi.Chat{ID: -1002, Type: "channel"}
+	message := &tgbotapi.Message{
+		MessageID:          10,
+		From:               &tgbotapi.User{ID: 777000, FirstName: "Telegram"},
+		Chat:               chat,
+		SenderChat:         channel,
+		ForwardFromChat:    channel,
+		ForwardDate:        1,
+		IsAutomaticForward: true,
+		Photo:              []tgbotapi.PhotoSize{{FileID: "p"}},
+	}
+	if l.Check(message) {
+		t.Fatal("automatic forward from the linked channel must not be deleted")
+	}
+}
+
+func TestMessageMediaKinds(t *testing.T) {
+	chat := &tgbotapi.Chat{ID: -1001}
+	channel := &tgbotapi.Chat{ID: -1002}
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+		want    []string
+	}{
+		{"text", &tgbotapi.Message{Chat: chat, Text: "سلام"}, nil},
+		{"photo", &tgbotapi.Message{Chat: chat, Photo: []tgbotapi.PhotoSize{{}}}, []string{mediaPhoto}},
+		{"forwarded sticker", &tgbotapi.Message{Chat: chat, ForwardDate: 1, Sticker: &tgbotapi.Sticker{}}, []string{mediaForward, mediaSticker}},
+		{"sent as channel", &tgbotapi.Message{Chat: chat, SenderChat: channel}, []string{mediaSenderChat}},
+		{"anonymous admin", &tgbotapi.Message{Chat: chat, SenderChat: chat}, nil},
+		{"gif is not a document", &tgbotapi.Message{Chat: chat, Animation: &tgbotapi.Animation{}, Document: &tgbotapi.Document{}}, []string{mediaAnimation}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := messageMediaKinds(tt.message)
+			if len(got) != len(tt.want) {
+				t.Fatalf("messageMediaKinds = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("messageMediaKinds = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,20 +1,27 @@
 package commands
 
 import (
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
 	"time"
 
+	"redhat-bot/storage"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type ModerationCommand struct {
-	bot *tgbotapi.BotAPI
+	bot      *tgbotapi.BotAPI
+	storage  *storage.MySQLStorage
+	autoLogs chan modLogEntry // queued reports of automatic actions for the log channel
 }
 
-func NewModerationCommand(bot *tgbotapi.BotAPI) *ModerationCommand {
-	return &ModerationCommand{bot: bot}
+func NewModerationCommand(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage) *ModerationCommand {
+	m := &ModerationCommand{bot: bot, storage: storage, autoLogs: make(chan modLogEntry, modLogQueueSize)}
+	go m.runAutoLogs()
+	return m
 }
 
 // HandleDelete deletes a replied message if the requester is a group admin
@@ -48,6 +55,14 @@ func (m *ModerationCommand) HandleDelete(update tgbotapi.Update) tgbotapi.Messag
 		log.Printf("deleteMessage target error: %v", err)
 		return tgbotapi.NewMessage(chatID, "❌ حذف پیام انجام نشد. مطمئن شوید ربات دسترسی حذف دارد و پیام خیلی قدیمی نیست")
 	}
+	m.Record(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: chat.Title,
+		Actor:     update.Message.From,
+		Target:    update.Message.ReplyToMessage.From,
+		Action:    modActionDelete,
+		Excerpt:   messageExcerpt(update.Message.ReplyToMessage),
+	})
 
 	// Try to delete the command message for cleanliness (ignore error)
 	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
@@ -90,6 +105,13 @@ func (m *ModerationCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfi
 	if count > 0 {
 		// Bulk delete previous N messages
 		m.bulkDeletePrev(chatID, update.Message.MessageID, count)
+		m.Record(ModerationAction{
+			ChatID:    chatID,
+			ChatTitle: chat.Title,
+			Actor:     update.Message.From,
+			Action:    modActionPurge,
+			Reason:    fmt.Sprintf("%d پیام قبلی", count),
+		})
 		// Try to delete command message too
 		_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
 		return tgbotapi.MessageConfig{}
@@ -104,14 +126,12 @@ func (m *ModerationCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfi
 }
 
 func (m *ModerationCommand) bulkDeletePrev(chatID int64, fromMessageID int, count int) {
-	// Delete up to count previous message IDs; ignore individual errors
-	for i := 1; i <= count; i++ {
-		target := fromMessageID - i
-		if target <= 0 {
-			break
-		}
-		_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: target})
+	// Delete up to count previous message IDs in batches; missing messages are skipped
+	ids := make([]int, 0, count)
+	for i := 1; i <= count && fromMessageID-i > 0; i++ {
+		ids = append(ids, fromMessageID-i)
 	}
+	m.deleteMessages(chatID, ids, nil)
 }
 
 func (m *ModerationCommand) isUserAdmin(chatID int64, userID int64) (bool, error) {
@@ -123,113 +143,224 @@ func (m *ModerationCommand) isUserAdmin(chatID int64, userID int64) (bool, error
 	return member.IsAdministrator() || member.IsCreator(), nil
 }
 
-// HandleBanOnReply bans the replied user permanently, only if requester is admin and target is not admin
-func (m *ModerationCommand) HandleBanOnReply(update tgbotapi.Update) tgbotapi.MessageConfig {
-	chat := update.Message.Chat
-	chatID := chat.ID
+// moderation commands that accept a target (reply, @username, numeric ID or text_mention)
+const (
+	cmdBan    = "بن"
+	cmdUnban  = "رفع بن"
+	cmdMute   = "سکوت"
+	cmdUnmute = "آزاد"
+)
 
-	// Only in groups
-	if chat.Type != "group" && chat.Type != "supergroup" {
-		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
-	}
+// targetCommands ban/mute commands with the default unit of a bare-number duration
+// (0 -> a unit is required, -1 -> the command takes no duration)
+var targetCommands = []struct {
+	name        string
+	defaultUnit time.Duration
+}{
+	{cmdBan, 0},
+	{cmdUnban, -1},
+	{cmdMute, time.Hour}, // «سکوت 2» از قبل به معنی دو ساعت بوده است
+	{cmdUnmute, -1},
+	{"ازاد", -1},
+}
 
-	// Require reply to a user's message
-	if update.Message.ReplyToMessage == nil || update.Message.ReplyToMessage.From == nil {
-		return tgbotapi.NewMessage(chatID, "لطفاً روی پیام کاربری که می‌خواهید بن شود ریپلای کنید و بنویسید: بن")
+// IsTargetCommand reports whether the message is one of the ban/mute commands ("ازاد" is accepted for "آزاد").
+// Text after the command must start with a target (@name, numeric ID or text_mention) or a valid duration,
+// so chat like «سکوت لطفاً» or «بن شدی؟» is not taken as a command.
+func (m *ModerationCommand) IsTargetCommand(message *tgbotapi.Message) bool {
+	text := strings.TrimSpace(message.Text)
+	for _, command := range targetCommands {
+		if text == command.name {
+			return true
+		}
+		if !CommandMatches(text, command.name) {
+			continue
+		}
+		for _, e := range message.Entities {
+			if e.Type == "text_mention" && e.User != nil {
+				return true
+			}
+		}
+		return startsWithTarget(commandArgs(message, command.name), command.defaultUnit)
 	}
+	return false
+}
 
-	// Only group admins can use
-	isAdmin, err := m.isUserAdmin(chatID, update.Message.From.ID)
-	if err != nil {
-		log.Printf("getChatMember error (requester): %v", err)
-		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
-	}
-	if !isAdmin {
-		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند کاربر را بن کنند")
+// HandleTargetCommand dispatches «بن»، «رفع بن»، «سکوت» and «آزاد»
+func (m *ModerationCommand) HandleTargetCommand(update tgbotapi.Update) tgbotapi.MessageConfig {
+	text := strings.TrimSpace(update.Message.Text)
+	switch {
+	case CommandMatches(text, cmdUnban):
+		return m.HandleUnban(update)
+	case CommandMatches(text, cmdBan):
+		return m.HandleBan(update)
+	case CommandMatches(text, cmdMute):
+		return m.HandleMute(update)
+	default:
+		return m.HandleUnmute(update)
 	}
+}
 
-	// Target user
-	targetUserID := update.Message.ReplyToMessage.From.ID
-
-	// Prevent banning admins
-	isTargetAdmin, err := m.isUserAdmin(chatID, targetUserID)
-	if err != nil {
-		log.Printf("getChatMember error (target): %v", err)
-		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی نقش کاربر هدف")
-	}
-	if isTargetAdmin {
-		return tgbotapi.NewMessage(chatID, "❌ امکان بن کردن ادمین یا صاحب گروه وجود ندارد")
+// HandleBan bans the target: "بن [@user|id] [duration] [reason]". The duration needs a unit; without one the ban is permanent.
+func (m *ModerationCommand) HandleBan(update tgbotapi.Update) tgbotapi.MessageConfig {
+	target, args, errMsg := m.moderationTarget(update, cmdBan, "بن شود", "بن [@نام یا شناسه] [مدت با واحد مثل 3d یا ۲ ساعت] [دلیل]")
+	if target == nil {
+		return errMsg
 	}
+	chatID := update.Message.Chat.ID
 
-	// Perform ban (permanent)
-	banCfg := tgbotapi.BanChatMemberConfig{
-		ChatMemberConfig: tgbotapi.ChatMemberConfig{
-			ChatID: chatID,
-			UserID: targetUserID,
-		},
-		UntilDate: 0,
+	duration, args, ok := parseModDuration(args, 0)
+	if !ok {
+		return tgbotapi.NewMessage(chatID, "❌ مدت بن نامعتبر است؛ واحد را هم بنویسید. نمونه: 30m، 2h، 3d، ۲ ساعت، ۱ روز (حداکثر ۳۶۶ روز)")
+	}
+	var until int64
+	if duration > 0 {
+		until = time.Now().Add(duration).Unix()
 	}
 
-	if _, err := m.bot.Request(banCfg); err != nil {
+	if err := m.ban(chatID, target.ID, until); err != nil {
 		log.Printf("banChatMember error: %v", err)
 		return tgbotapi.NewMessage(chatID, "❌ بن انجام نشد. مطمئن شوید ربات دسترسی بن دارد")
 	}
+	m.Record(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: update.Message.Chat.Title,
+		Actor:     update.Message.From,
+		Target:    target,
+		Action:    modActionBan,
+		Reason:    strings.Join(args, " "),
+		Duration:  duration,
+		Excerpt:   messageExcerpt(update.Message.ReplyToMessage),
+	})
 
 	// Try to delete the command message for cleanliness (ignore error)
 	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
 
-	// Confirmation message
-	return tgbotapi.NewMessage(chatID, "✅ کاربر موردنظر بن شد")
+	if duration == 0 {
+		return tgbotapi.NewMessage(chatID, "✅ "+warnUserName(target)+" بن شد")
+	}
+	return tgbotapi.NewMessage(chatID, "✅ "+warnUserName(target)+" به مدت "+formatModerationDuration(duration)+" بن شد")
 }
 
-// HandleMute mutes a replied user. Supports optional hours: "سکوت [n]" where n is hours. Without n -> indefinite.
-func (m *ModerationCommand) HandleMute(update tgbotapi.Update) tgbotapi.MessageConfig {
-	chat := update.Message.Chat
-	chatID := chat.ID
+// HandleUnban lifts a ban: "رفع بن [@user|id]" or on reply. The user can join again.
+func (m *ModerationCommand) HandleUnban(update tgbotapi.Update) tgbotapi.MessageConfig {
+	target, _, errMsg := m.moderationTarget(update, cmdUnban, "رفع بن شود", "رفع بن [@نام یا شناسه]")
+	if target == nil {
+		return errMsg
+	}
+	chatID := update.Message.Chat.ID
 
-	if chat.Type != "group" && chat.Type != "supergroup" {
-		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
+	unbanCfg := tgbotapi.UnbanChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: target.ID},
+		OnlyIfBanned:     true,
+	}
+	if _, err := m.bot.Request(unbanCfg); err != nil {
+		log.Printf("unbanChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ رفع بن انجام نشد. مطمئن شوید ربات دسترسی بن دارد")
 	}
+	m.Record(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: update.Message.Chat.Title,
+		Actor:     update.Message.From,
+		Target:    target,
+		Action:    modActionUnban,
+	})
 
-	// Only group admins can use
-	isAdmin, err := m.isUserAdmin(chatID, update.Message.From.ID)
-	if err != nil {
-		log.Printf("getChatMember error (requester): %v", err)
-		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
+	return tgbotapi.NewMessage(chatID, "✅ بن "+warnUserName(target)+" برداشته شد")
+}
+
+// HandleMute mutes the target: "سکوت [@user|id] [duration] [reason]". A bare number means hours; without a duration -> indefinite.
+func (m *ModerationCommand) HandleMute(update tgbotapi.Update) tgbotapi.MessageConfig {
+	target, args, errMsg := m.moderationTarget(update, cmdMute, "سکوت شود", "سکوت [@نام یا شناسه] [مدت مثل 30m، 2h یا ۱ روز؛ عدد تنها = ساعت] [دلیل]")
+	if target == nil {
+		return errMsg
 	}
-	if !isAdmin {
-		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند کاربر را سکوت کنند")
+	chatID := update.Message.Chat.ID
+
+	duration, args, ok := parseModDuration(args, time.Hour)
+	if !ok {
+		return tgbotapi.NewMessage(chatID, "❌ مدت نامعتبر است. نمونه: 30m، 2h، 3d، ۲ ساعت، ۱ روز (حداکثر ۳۶۶ روز)")
+	}
+	var until int64
+	if duration > 0 {
+		until = time.Now().Add(duration).Unix()
 	}
 
-	if update.Message.ReplyToMessage == nil || update.Message.ReplyToMessage.From == nil {
-		return tgbotapi.NewMessage(chatID, "لطفاً روی پیام کاربری که می‌خواهید سکوت شود ریپلای کنید و بنویسید: سکوت [ساعت]")
+	if err := m.mute(chatID, target.ID, until); err != nil {
+		log.Printf("restrictChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ سکوت انجام نشد. مطمئن شوید ربات دسترسی مناسب دارد")
 	}
+	m.Record(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: update.Message.Chat.Title,
+		Actor:     update.Message.From,
+		Target:    target,
+		Action:    modActionMute,
+		Reason:    strings.Join(args, " "),
+		Duration:  duration,
+		Excerpt:   messageExcerpt(update.Message.ReplyToMessage),
+	})
 
-	targetUserID := update.Message.ReplyToMessage.From.ID
+	// Try to delete the command message for cleanliness (ignore error)
+	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
 
-	// Prevent muting admins
-	isTargetAdmin, err := m.isUserAdmin(chatID, targetUserID)
-	if err != nil {
-		log.Printf("getChatMember error (target): %v", err)
-		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی نقش کاربر هدف")
+	if duration == 0 {
+		return tgbotapi.NewMessage(chatID, "✅ "+warnUserName(target)+" به‌صورت نامحدود سکوت شد")
+	}
+	return tgbotapi.NewMessage(chatID, "✅ "+warnUserName(target)+" به مدت "+formatModerationDuration(duration)+" سکوت شد")
+}
+
+// HandleUnmute lifts mute restrictions from the target: "آزاد [@user|id]" or on reply.
+func (m *ModerationCommand) HandleUnmute(update tgbotapi.Update) tgbotapi.MessageConfig {
+	command := cmdUnmute
+	if strings.HasPrefix(strings.TrimSpace(update.Message.Text), "ازاد") {
+		command = "ازاد"
 	}
-	if isTargetAdmin {
-		return tgbotapi.NewMessage(chatID, "❌ امکان سکوت کردن ادمین یا صاحب گروه وجود ندارد")
+	target, _, errMsg := m.moderationTarget(update, command, "از سکوت خارج شود", "آزاد [@نام یا شناسه]")
+	if target == nil {
+		return errMsg
 	}
+	chatID := update.Message.Chat.ID
 
-	// Parse optional hours
-	var until int64 = 0
-	fields := strings.Fields(strings.TrimSpace(update.Message.Text))
-	if len(fields) > 1 {
-		if hours, err := strconv.Atoi(fields[1]); err == nil && hours > 0 {
-			until = time.Now().Add(time.Duration(hours) * time.Hour).Unix()
-		}
+	// Lift restrictions by allowing messaging-related permissions
+	if err := m.unmute(chatID, target.ID); err != nil {
+		log.Printf("restrictChatMember (unmute) error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ آزاد کردن انجام نشد. مطمئن شوید ربات دسترسی مناسب دارد")
 	}
+	m.Record(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: update.Message.Chat.Title,
+		Actor:     update.Message.From,
+		Target:    target,
+		Action:    modActionUnmute,
+	})
 
+	// Try to delete the command message (ignore error)
+	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
+
+	return tgbotapi.NewMessage(chatID, "✅ "+warnUserName(target)+" از سکوت خارج شد")
+}
+
+// ban bans a user until the given unix time (0 -> permanent)
+func (m *ModerationCommand) ban(chatID int64, userID int64, until int64) error {
+	banCfg := tgbotapi.BanChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		},
+		UntilDate: until,
+	}
+	_, err := m.bot.Request(banCfg)
+	return err
+}
+
+// mute removes all sending permissions until the given unix time (0 -> indefinite)
+func (m *ModerationCommand) mute(chatID int64, userID int64, until int64) error {
 	restrictCfg := tgbotapi.RestrictChatMemberConfig{
 		ChatMemberConfig: tgbotapi.ChatMemberConfig{
 			ChatID: chatID,
-			UserID: targetUserID,
+			UserID: userID,
 		},
 		Permissions: &tgbotapi.ChatPermissions{
 			CanSendMessages:       false,
@@ -243,51 +374,16 @@ func (m *ModerationCommand) HandleMute(update tgbotapi.Update) tgbotapi.MessageC
 		},
 		UntilDate: until,
 	}
-
-	if _, err := m.bot.Request(restrictCfg); err != nil {
-		log.Printf("restrictChatMember error: %v", err)
-		return tgbotapi.NewMessage(chatID, "❌ سکوت انجام نشد. مطمئن شوید ربات دسترسی مناسب دارد")
-	}
-
-	// Try to delete the command message for cleanliness (ignore error)
-	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
-
-	if until == 0 {
-		return tgbotapi.NewMessage(chatID, "✅ کاربر موردنظر به‌صورت نامحدود سکوت شد")
-	}
-	return tgbotapi.NewMessage(chatID, "✅ کاربر موردنظر سکوت شد")
+	_, err := m.bot.Request(restrictCfg)
+	return err
 }
 
-// HandleUnmute lifts mute restrictions from a replied user: "آزاد" on reply.
-func (m *ModerationCommand) HandleUnmute(update tgbotapi.Update) tgbotapi.MessageConfig {
-	chat := update.Message.Chat
-	chatID := chat.ID
-
-	if chat.Type != "group" && chat.Type != "supergroup" {
-		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
-	}
-
-	// Only group admins can use
-	isAdmin, err := m.isUserAdmin(chatID, update.Message.From.ID)
-	if err != nil {
-		log.Printf("getChatMember error (requester): %v", err)
-		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
-	}
-	if !isAdmin {
-		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند کاربر را از سکوت خارج کنند")
-	}
-
-	if update.Message.ReplyToMessage == nil || update.Message.ReplyToMessage.From == nil {
-		return tgbotapi.NewMessage(chatID, "لطفاً روی پیام کاربری که می‌خواهید از سکوت خارج شود ریپلای کنید و بنویسید: آزاد")
-	}
-
-	targetUserID := update.Message.ReplyToMessage.From.ID
-
-	// Lift restrictions by allowing messaging-related permissions
+// unmute restores messaging-related permissions
+func (m *ModerationCommand) unmute(chatID int64, userID int64) error {
 	unrestrictCfg := tgbotapi.RestrictChatMemberConfig{
 		ChatMemberConfig: tgbotapi.ChatMemberConfig{
 			ChatID: chatID,
-			UserID: targetUserID,
+			UserID: userID,
 		},
 		Permissions: &tgbotapi.ChatPermissions{
 			CanSendMessages:       true,
@@ -301,14 +397,18 @@ func (m *ModerationCommand) HandleUnmute(update tgbotapi.Update) tgbotapi.Messag
 		},
 		UntilDate: 0,
 	}
+	_, err := m.bot.Request(unrestrictCfg)
+	return err
+}
 
-	if _, err := m.bot.Request(unrestrictCfg); err != nil {
-		log.Printf("restrictChatMember (unmute) error: %v", err)
-		return tgbotapi.NewMessage(chatID, "❌ آزاد کردن انجام نشد. مطمئن شوید ربات دسترسی مناسب دارد")
+// kick removes a user from the group without a permanent ban (ban + unban), so they can join again
+func (m *ModerationCommand) kick(chatID int64, userID int64) error {
+	if err := m.ban(chatID, userID, 0); err != nil {
+		return err
 	}
-
-	// Try to delete the command message (ignore error)
-	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
-
-	return tgbotapi.NewMessage(chatID, "✅ کاربر از سکوت خارج شد")
+	_, err := m.bot.Request(tgbotapi.UnbanChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: userID},
+		OnlyIfBanned:     true,
+	})
+	return err
 }
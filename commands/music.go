@@ -1,26 +1,41 @@
 package commands
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"redhat-bot/ai"
+	"net/url"
 	"redhat-bot/limiter"
+	"redhat-bot/storage"
+	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// musicFlow گفتگوی پرسیدن حس و حال کاربر
+const musicFlow = "music"
+
 type MusicCommand struct {
-	aiClient    *ai.DeepSeekClient
-	rateLimiter *limiter.RateLimiter
-	bot         *tgbotapi.BotAPI
+	ai            *AIService
+	storage       *storage.MySQLStorage
+	rateLimiter   *limiter.RateLimiter
+	bot           *tgbotapi.BotAPI
+	conversations *ConversationManager
 }
 
-func NewMusicCommand(aiClient *ai.DeepSeekClient, rateLimiter *limiter.RateLimiter, bot *tgbotapi.BotAPI) *MusicCommand {
-	return &MusicCommand{
-		aiClient:    aiClient,
-		rateLimiter: rateLimiter,
-		bot:         bot,
+func NewMusicCommand(aiService *AIService, storage *storage.MySQLStorage, rateLimiter *limiter.RateLimiter, bot *tgbotapi.BotAPI, conversations *ConversationManager) *MusicCommand {
+	r := &MusicCommand{
+		ai:            aiService,
+		storage:       storage,
+		rateLimiter:   rateLimiter,
+		bot:           bot,
+		conversations: conversations,
 	}
+	conversations.Register(musicFlow, 10*time.Minute, r.handleMoodStep)
+	aiService.RegisterRegenerator("music", r.regenerate)
+	return r
 }
 
 func (r *MusicCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
@@ -32,9 +47,9 @@ func (r *MusicCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
 		return tgbotapi.NewMessage(chatID, message)
 	}
 
-	// اگر پیام ریپلای است، آن را پردازش کن
-	if update.Message.ReplyToMessage != nil {
-		return r.handleReply(update)
+	if err := r.conversations.Start(chatID, userID, musicFlow, "mood", nil); err != nil {
+		log.Printf("Error starting music conversation: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ متأسفانه نتوانستم پیشنهاد موسیقی ارائه دهم. لطفاً دوباره تلاش کنید.")
 	}
 
 	// ارسال پیام اولیه برای درخواست موسیقی
@@ -42,62 +57,244 @@ func (r *MusicCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
 
 چه نوع آهنگی دوست داری؟ حس و حال الانت چیه؟ (غمگین، شاد، آروم، انگیزشی...)
 
-لطفاً جواب رو ریپلای کن به همین پیام.`
+جوابت رو همین‌جا بفرست.`
 
 	msg := tgbotapi.NewMessage(chatID, response)
 	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyToMessageID = update.Message.MessageID
+	msg.ReplyMarkup = conversationCancelKeyboard()
 	return msg
 }
 
+// handleMoodStep دریافت حس و حال کاربر در گفتگوی موسیقی
+func (r *MusicCommand) handleMoodStep(update tgbotapi.Update, conv *Conversation) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	mood := strings.TrimSpace(update.Message.Text)
+	if mood == "" {
+		return tgbotapi.NewMessage(chatID, "🎵 لطفاً حس و حالت رو به صورت متن بنویس (یا «لغو»).")
+	}
+	if len([]rune(mood)) > 200 {
+		return tgbotapi.NewMessage(chatID, "🎵 کوتاه‌تر بنویس لطفاً (حداکثر ۲۰۰ کاراکتر).")
+	}
+	conv.Finish()
+
+	// بررسی محدودیت درخواست
+	if allowed, message := r.rateLimiter.CheckRateLimit(update.Message.From.ID); !allowed {
+		return tgbotapi.NewMessage(chatID, message)
+	}
+	return r.handleReply(update)
+}
+
+// musicTrack یک آهنگ پیشنهادی در خروجی JSON هوش مصنوعی
+type musicTrack struct {
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	Year   int    `json:"year"`
+	Mood   string `json:"mood"`
+	Reason string `json:"reason"`
+}
+
+// errNoNewTracks همه آهنگ‌های پیشنهادی مدل قبلاً به کاربر پیشنهاد شده بودند
+var errNoNewTracks = errors.New("no new music tracks")
+
 func (r *MusicCommand) handleReply(update tgbotapi.Update) tgbotapi.MessageConfig {
 	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
 	userPreference := update.Message.Text
 
-	// ارسال پیام "در حال پردازش"
-	processingMsg := tgbotapi.NewMessage(chatID, "درحال پردازش - کمی شکیبا باشید ✨")
-	sentMsg, err := r.bot.Send(processingMsg)
-	if err != nil {
-		log.Printf("خطا در ارسال پیام پردازش: %v", err)
+	// اگر مدارشکن باز است، بدون انتظار پیام عدم دسترسی بده
+	if !r.ai.Available() {
+		return tgbotapi.NewMessage(chatID, aiUnavailableText)
 	}
 
-	// ساخت درخواست برای هوش مصنوعی
-	prompt := fmt.Sprintf(`"%s" این آهنگ با این حس و حال لینک بفرس
-
-توضیحات خیلی کوتاه باشه و لینک یوتیوب و اسپاتیفای درجا بده.`, userPreference)
+	// ثبت در صف و ارسال پیام "در حال پردازش"
+	job, err := r.ai.Begin(chatID, userID, 0)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه نتوانستم پیشنهاد موسیقی ارائه دهم. لطفاً دوباره تلاش کنید."))
+	}
 
-	// دریافت پاسخ از هوش مصنوعی
-	response, err := r.aiClient.AskQuestion(prompt)
+	answer, err := r.generate(job, userID, userPreference, false)
 	if err != nil {
-		log.Printf("خطا در دریافت پیشنهاد موسیقی: %v", err)
 		// حذف پیام پردازش و ارسال پیام خطا
-		if sentMsg.MessageID != 0 {
-			deleteMsg := tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID)
+		if job.MessageID != 0 {
+			deleteMsg := tgbotapi.NewDeleteMessage(chatID, job.MessageID)
 			r.bot.Send(deleteMsg)
 		}
-		return tgbotapi.NewMessage(chatID, "❌ متأسفانه نتوانستم پیشنهاد موسیقی ارائه دهم. لطفاً دوباره تلاش کنید.")
+		if errors.Is(err, errNoNewTracks) {
+			return tgbotapi.NewMessage(chatID, "🎵 پیشنهاد تازه‌ای برای این حس پیدا نکردم. لطفاً حس و حال دیگری بنویس.")
+		}
+		log.Printf("خطا در دریافت پیشنهاد موسیقی: %v", err)
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه نتوانستم پیشنهاد موسیقی ارائه دهم. لطفاً دوباره تلاش کنید."))
 	}
 
-	// فرمت‌بندی پاسخ
-	formattedResponse := fmt.Sprintf("🎵 *پیشنهاد موسیقی*\n\n%s", response)
-
-	// ویرایش پیام پردازش با پاسخ نهایی
-	if sentMsg.MessageID != 0 {
-		editMsg := tgbotapi.NewEditMessageText(chatID, sentMsg.MessageID, formattedResponse)
-		editMsg.ParseMode = tgbotapi.ModeMarkdown
-		_, err = r.bot.Send(editMsg)
-		if err != nil {
-			log.Printf("خطا در ویرایش پیام: %v", err)
-			// اگر ویرایش ناموفق بود، پیام جدید ارسال کن
-			msg := tgbotapi.NewMessage(chatID, formattedResponse)
-			msg.ParseMode = tgbotapi.ModeMarkdown
-			return msg
+	// ویرایش پیام پردازش با پاسخ نهایی (یا ارسال پیام جدید)
+	r.ai.Deliver(job, 0, answer)
+	return tgbotapi.MessageConfig{}
+}
+
+// generate دریافت آهنگ‌های تازه برای حس و حال کاربر (job باید صف‌شده باشد)
+func (r *MusicCommand) generate(job *AIJob, userID int64, preference string, fresh bool) (AIAnswer, error) {
+	// آهنگ‌هایی که قبلاً به این کاربر پیشنهاد شده
+	previous, err := r.storage.RecentMusicRecommendations(userID, 100)
+	if err != nil {
+		log.Printf("Error reading music recommendations: %v", err)
+	}
+	seen := make(map[string]bool, len(previous))
+	var exclude []string
+	for i, p := range previous {
+		seen[p.TrackKey] = true
+		if i < 30 {
+			exclude = append(exclude, p.Artist+" - "+p.Title)
 		}
-		// پیام خالی برگردان چون پیام ویرایش شده
-		return tgbotapi.MessageConfig{}
 	}
 
-	// اگر پیام پردازش ارسال نشده بود، پیام عادی ارسال کن
-	msg := tgbotapi.NewMessage(chatID, formattedResponse)
-	msg.ParseMode = tgbotapi.ModeMarkdown
-	return msg
+	// ساخت درخواست برای هوش مصنوعی از قالب music؛ لینک را خود ربات می‌سازد
+	prompt, version, err := r.ai.Prompt("music", map[string]any{
+		"Preference": preference,
+		"Exclude":    exclude,
+	})
+	if err != nil {
+		return AIAnswer{}, err
+	}
+
+	// دریافت پاسخ از هوش مصنوعی؛ کش فقط بر اساس حس و حال است و پاسخ کش‌شده‌ای که
+	// آهنگ تازه‌ای برای این کاربر ندارد کنار گذاشته می‌شود
+	response, err := r.ai.Run(job, AIRequest{
+		ChatID:        job.ChatID,
+		UserID:        userID,
+		Command:       "music",
+		Prompt:        prompt,
+		PromptVersion: version,
+		Fresh:         fresh,
+		CacheKey:      preference,
+		AcceptCached: func(cached string) bool {
+			tracks, err := parseMusicTracks(cached)
+			return err == nil && hasUnseenTrack(tracks, seen)
+		},
+	})
+	if err != nil {
+		return AIAnswer{}, err
+	}
+	tracks, err := parseMusicTracks(response)
+	if err != nil {
+		return AIAnswer{}, err
+	}
+	tracks = filterSeenTracks(tracks, seen)
+	if len(tracks) == 0 {
+		return AIAnswer{}, errNoNewTracks
+	}
+
+	text, rows := r.renderTracks(userID, tracks)
+	return AIAnswer{
+		Text:          text,
+		Rows:          rows,
+		UserID:        userID,
+		Command:       "music",
+		Input:         preference,
+		Question:      prompt,
+		Answer:        text,
+		PromptVersion: version,
+	}, nil
+}
+
+// regenerate پیشنهاد تازه برای همان حس و حال (دکمه «🔄 پاسخ دیگر»)
+func (r *MusicCommand) regenerate(job *AIJob, prev *storage.AIReply) (AIAnswer, error) {
+	return r.generate(job, prev.UserID, prev.Input, true)
+}
+
+// renderTracks متن آهنگ‌ها با دکمه جستجوی یوتیوب و اسپاتیفای، و ثبت آن‌ها برای کاربر
+func (r *MusicCommand) renderTracks(userID int64, tracks []musicTrack) (string, [][]tgbotapi.InlineKeyboardButton) {
+	var b strings.Builder
+	b.WriteString("🎵 پیشنهاد موسیقی\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	recs := make([]storage.MusicRecommendation, 0, len(tracks))
+	for i, t := range tracks {
+		fmt.Fprintf(&b, "\n%d. 🎤 %s — %s", i+1, t.Artist, t.Title)
+		if t.Year > 0 {
+			fmt.Fprintf(&b, " (%d)", t.Year)
+		}
+		if t.Mood != "" {
+			fmt.Fprintf(&b, "\n   🎭 %s", t.Mood)
+		}
+		if t.Reason != "" {
+			fmt.Fprintf(&b, "\n   💬 %s", t.Reason)
+		}
+		b.WriteString("\n")
+
+		query := t.Artist + " " + t.Title
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(fmt.Sprintf("▶️ %d یوتیوب", i+1), "https://www.youtube.com/results?search_query="+url.QueryEscape(query)),
+			tgbotapi.NewInlineKeyboardButtonURL(fmt.Sprintf("🟢 %d اسپاتیفای", i+1), "https://open.spotify.com/search/"+url.PathEscape(query)),
+		))
+		recs = append(recs, storage.MusicRecommendation{
+			UserID:   userID,
+			TrackKey: musicTrackKey(t),
+			Artist:   t.Artist,
+			Title:    t.Title,
+			Year:     t.Year,
+			Mood:     t.Mood,
+		})
+	}
+	if err := r.storage.SaveMusicRecommendations(recs); err != nil {
+		log.Printf("Error saving music recommendations: %v", err)
+	}
+	return b.String(), rows
+}
+
+// parseMusicTracks استخراج آرایه JSON از پاسخ (مدل گاهی آن را داخل ```json می‌گذارد)
+func parseMusicTracks(response string) ([]musicTrack, error) {
+	start := strings.Index(response, "[")
+	end := strings.LastIndex(response, "]")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("music response is not a JSON array: %q", response)
+	}
+	var tracks []musicTrack
+	if err := json.Unmarshal([]byte(response[start:end+1]), &tracks); err != nil {
+		return nil, fmt.Errorf("invalid music JSON: %v", err)
+	}
+
+	valid := tracks[:0]
+	for _, t := range tracks {
+		t.Artist = strings.TrimSpace(t.Artist)
+		t.Title = strings.TrimSpace(t.Title)
+		if t.Artist != "" && t.Title != "" {
+			valid = append(valid, t)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("music response has no tracks: %q", response)
+	}
+	return valid, nil
+}
+
+// filterSeenTracks حذف آهنگ‌های تکراری و آهنگ‌هایی که قبلاً به کاربر پیشنهاد شده
+func filterSeenTracks(tracks []musicTrack, seen map[string]bool) []musicTrack {
+	var result []musicTrack
+	for _, t := range tracks {
+		key := musicTrackKey(t)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, t)
+	}
+	return result
+}
+
+// hasUnseenTrack آیا حداقل یکی از آهنگ‌ها قبلاً به کاربر پیشنهاد نشده است (بدون تغییر seen)
+func hasUnseenTrack(tracks []musicTrack, seen map[string]bool) bool {
+	for _, t := range tracks {
+		if !seen[musicTrackKey(t)] {
+			return true
+		}
+	}
+	return false
+}
+
+func musicTrackKey(t musicTrack) string {
+	key := strings.ToLower(normalizePersian(t.Artist) + "|" + normalizePersian(t.Title))
+	if runes := []rune(key); len(runes) > 255 {
+		key = string(runes[:255])
+	}
+	return key
 }
@@ -0,0 +1,22 @@
+package commands
+
+import "testing"
+
+func TestHasUnseenTrack(t *testing.T) {
+	a := musicTrack{Artist: "Googoosh", Title: "Talaaq"}
+	b := musicTrack{Artist: "Ebi", Title: "Khalij"}
+	seen := map[string]bool{musicTrackKey(a): true}
+
+	if hasUnseenTrack([]musicTrack{a}, seen) {
+		t.Fatal("cached answer with only seen tracks must be rejected")
+	}
+	if !hasUnseenTrack([]musicTrack{a, b}, seen) {
+		t.Fatal("cached answer with a new track should be accepted")
+	}
+	if len(seen) != 1 {
+		t.Fatalf("hasUnseenTrack must not change seen: %d keys", len(seen))
+	}
+	if got := filterSeenTracks([]musicTrack{a, b, b}, seen); len(got) != 1 || got[0] != b {
+		t.Fatalf("filterSeenTracks = %v, want only %v once", got, b)
+	}
+}
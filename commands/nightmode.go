@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// تنظیمات حالت شب در GroupSetting هر گروه
+const (
+	featureNightMode = "night_mode"
+
+	settingNightStart       = "night_start"       // HH:MM
+	settingNightEnd         = "night_end"         // HH:MM
+	settingNightTimezone    = "night_tz"          // نام منطقه زمانی IANA
+	settingNightActive      = "night_active"      // "1" وقتی گروه توسط حالت شب قفل شده است
+	settingNightPermissions = "night_permissions" // دسترسی‌های پیش‌فرض گروه پیش از قفل (JSON)
+
+	defaultNightStart    = "23:00"
+	defaultNightEnd      = "07:00"
+	defaultNightTimezone = "Asia/Tehran"
+)
+
+// NightMode قفل خودکار گروه در ساعات شب با تغییر دسترسی‌های پیش‌فرض گروه؛ ادمین‌ها مشمول این دسترسی‌ها نیستند
+type NightMode struct {
+	bot        *tgbotapi.BotAPI
+	storage    *storage.MySQLStorage
+	moderation *ModerationCommand
+}
+
+func NewNightMode(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage, moderation *ModerationCommand) *NightMode {
+	return &NightMode{bot: bot, storage: storage, moderation: moderation}
+}
+
+// nightSchedule برنامه حالت شب یک گروه؛ start و end دقیقه از نیمه‌شب هستند
+type nightSchedule struct {
+	start, end int
+	location   *time.Location
+}
+
+func (s nightSchedule) contains(t time.Time) bool {
+	local := t.In(s.location)
+	now := local.Hour()*60 + local.Minute()
+	if s.start < s.end {
+		return now >= s.start && now < s.end
+	}
+	// بازه از نیمه‌شب می‌گذرد (مثلاً ۲۳:۰۰ تا ۰۷:۰۰)
+	return now >= s.start || now < s.end
+}
+
+func (s nightSchedule) describe() string {
+	return fmt.Sprintf("%s تا %s (%s)", formatClock(s.start), formatClock(s.end), s.location.String())
+}
+
+func (n *NightMode) schedule(chatID int64) nightSchedule {
+	get := func(key, fallback string) string {
+		if value, err := n.storage.GetGroupSetting(chatID, key); err == nil && value != "" {
+			return value
+		}
+		return fallback
+	}
+	start, ok := parseClock(get(settingNightStart, defaultNightStart))
+	if !ok {
+		start, _ = parseClock(defaultNightStart)
+	}
+	end, ok := parseClock(get(settingNightEnd, defaultNightEnd))
+	if !ok {
+		end, _ = parseClock(defaultNightEnd)
+	}
+	location, err := time.LoadLocation(get(settingNightTimezone, defaultNightTimezone))
+	if err != nil {
+		location, err = time.LoadLocation(defaultNightTimezone)
+		if err != nil {
+			location = time.Local
+		}
+	}
+	return nightSchedule{start: start, end: end, location: location}
+}
+
+// parseClock «23:00» یا «۷» -> دقیقه از نیمه‌شب
+func parseClock(value string) (int, bool) {
+	value = toLatinDigits(strings.TrimSpace(value))
+	if !strings.Contains(value, ":") {
+		value += ":00"
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// toLatinDigits تبدیل ارقام فارسی و عربی به لاتین
+func toLatinDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '۰' && r <= '۹':
+			return '0' + (r - '۰')
+		case r >= '٠' && r <= '٩':
+			return '0' + (r - '٠')
+		}
+		return r
+	}, s)
+}
+
+// Tick بررسی برنامه همه گروه‌های دارای حالت شب؛ هر دقیقه توسط کران اجرا می‌شود
+func (n *NightMode) Tick() {
+	groups, err := n.storage.GetEnabledGroupsForFeature(featureNightMode)
+	if err != nil {
+		log.Printf("Error loading night mode groups: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, chatID := range groups {
+		schedule := n.schedule(chatID)
+		active, _ := n.storage.GetGroupSetting(chatID, settingNightActive)
+		switch inside := schedule.contains(now); {
+		case inside && active == "":
+			n.lock(chatID, schedule)
+		case !inside && active != "":
+			n.unlock(chatID)
+		}
+	}
+}
+
+// lock ذخیره دسترسی‌های فعلی گروه، بستن ارسال پیام و اعلام شروع حالت شب
+func (n *NightMode) lock(chatID int64, schedule nightSchedule) {
+	chat, err := n.bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+	if err != nil {
+		log.Printf("getChat (night mode) error for %d: %v", chatID, err)
+		return
+	}
+	if chat.Permissions != nil {
+		if raw, err := json.Marshal(chat.Permissions); err == nil {
+			if err := n.storage.SetGroupSetting(chatID, settingNightPermissions, string(raw)); err != nil {
+				log.Printf("Error saving night mode permissions: %v", err)
+				return
+			}
+		}
+	}
+
+	cfg := tgbotapi.SetChatPermissionsConfig{
+		ChatConfig:  tgbotapi.ChatConfig{ChatID: chatID},
+		Permissions: &tgbotapi.ChatPermissions{},
+	}
+	if _, err := n.bot.Request(cfg); err != nil {
+		log.Printf("setChatPermissions (night mode) error for %d: %v", chatID, err)
+		return
+	}
+	if err := n.storage.SetGroupSetting(chatID, settingNightActive, "1"); err != nil {
+		log.Printf("Error saving night mode state: %v", err)
+	}
+	text := fmt.Sprintf("🌙 حالت شب شروع شد\nارسال پیام تا ساعت %s بسته است. شب بخیر!", formatClock(schedule.end))
+	if _, err := n.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("Error announcing night mode: %v", err)
+	}
+}
+
+// unlock بازگرداندن دسترسی‌های ذخیره‌شده گروه و اعلام پایان حالت شب
+func (n *NightMode) unlock(chatID int64) {
+	permissions := &tgbotapi.ChatPermissions{
+		CanSendMessages:       true,
+		CanSendMediaMessages:  true,
+		CanSendPolls:          true,
+		CanSendOtherMessages:  true,
+		CanAddWebPagePreviews: true,
+	}
+	if raw, err := n.storage.GetGroupSetting(chatID, settingNightPermissions); err == nil && raw != "" {
+		var saved tgbotapi.ChatPermissions
+		if err := json.Unmarshal([]byte(raw), &saved); err == nil {
+			permissions = &saved
+		}
+	}
+
+	cfg := tgbotapi.SetChatPermissionsConfig{
+		ChatConfig:  tgbotapi.ChatConfig{ChatID: chatID},
+		Permissions: permissions,
+	}
+	if _, err := n.bot.Request(cfg); err != nil {
+		log.Printf("setChatPermissions (night mode end) error for %d: %v", chatID, err)
+		return
+	}
+	_ = n.storage.DeleteGroupSetting(chatID, settingNightActive)
+	_ = n.storage.DeleteGroupSetting(chatID, settingNightPermissions)
+	if _, err := n.bot.Send(tgbotapi.NewMessage(chatID, "☀️ حالت شب تمام شد\nگروه دوباره باز است. صبح بخیر!")); err != nil {
+		log.Printf("Error announcing night mode end: %v", err)
+	}
+}
+
+// HandleCommand «حالت شب [روشن | خاموش | ساعت شروع ساعت پایان [منطقه زمانی]]»
+func (n *NightMode) HandleCommand(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chat := update.Message.Chat
+	chatID := chat.ID
+
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
+	}
+	isAdmin, err := n.moderation.isUserAdmin(chatID, update.Message.From.ID)
+	if err != nil {
+		log.Printf("getChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !isAdmin {
+		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند حالت شب را تنظیم کنند")
+	}
+
+	args := strings.Fields(strings.TrimPrefix(strings.TrimSpace(update.Message.Text), "حالت شب"))
+	usage := "\n\nروشن کردن: حالت شب روشن\nتنظیم ساعت: حالت شب 23:00 07:00\nمنطقه زمانی دلخواه: حالت شب 23:00 07:00 Europe/Istanbul\nخاموش کردن: حالت شب خاموش"
+
+	switch {
+	case len(args) == 0:
+		enabled, _ := n.storage.IsFeatureEnabled(chatID, featureNightMode)
+		status := "خاموش ❌"
+		if enabled {
+			status = "روشن ✅"
+		}
+		text := "🌙 حالت شب: " + status + "\nبازه: " + n.schedule(chatID).describe() +
+			"\nدر این بازه ارسال پیام برای اعضا بسته می‌شود (ادمین‌ها معاف‌اند)." + usage
+		return tgbotapi.NewMessage(chatID, text)
+
+	case len(args) == 1 && args[0] == "خاموش":
+		if err := n.storage.SetFeatureEnabled(chatID, featureNightMode, false); err != nil {
+			log.Printf("Error disabling night mode: %v", err)
+			return tgbotapi.NewMessage(chatID, "❌ خطا در خاموش کردن حالت شب")
+		}
+		// اگر همین حالا گروه قفل است، فوراً باز شود
+		if active, _ := n.storage.GetGroupSetting(chatID, settingNightActive); active != "" {
+			n.unlock(chatID)
+		}
+		return tgbotapi.NewMessage(chatID, "✅ حالت شب خاموش شد")
+
+	case len(args) == 1 && args[0] == "روشن":
+		// بازه ذخیره‌شده (یا پیش‌فرض) استفاده می‌شود
+
+	case len(args) == 2 || len(args) == 3:
+		start, okStart := parseClock(args[0])
+		end, okEnd := parseClock(args[1])
+		if !okStart || !okEnd || start == end {
+			return tgbotapi.NewMessage(chatID, "❌ ساعت نامعتبر است"+usage)
+		}
+		settings := map[string]string{settingNightStart: formatClock(start), settingNightEnd: formatClock(end)}
+		if len(args) == 3 {
+			if _, err := time.LoadLocation(args[2]); err != nil {
+				return tgbotapi.NewMessage(chatID, "❌ منطقه زمانی نامعتبر است (مثال: Asia/Tehran)")
+			}
+			settings[settingNightTimezone] = args[2]
+		}
+		for key, value := range settings {
+			if err := n.storage.SetGroupSetting(chatID, key, value); err != nil {
+				log.Printf("Error saving night mode setting: %v", err)
+				return tgbotapi.NewMessage(chatID, "❌ خطا در ذخیره تنظیمات حالت شب")
+			}
+		}
+
+	default:
+		return tgbotapi.NewMessage(chatID, "نحوه استفاده:"+usage)
+	}
+
+	if err := n.storage.SetFeatureEnabled(chatID, featureNightMode, true); err != nil {
+		log.Printf("Error enabling night mode: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در روشن کردن حالت شب")
+	}
+	return tgbotapi.NewMessage(chatID, "✅ حالت شب روشن شد\nبازه: "+n.schedule(chatID).describe()+
+		"\nشروع و پایان آن در گروه اعلام می‌شود. ربات باید دسترسی تغییر دسترسی‌های گروه (Ban users) را داشته باشد.")
+}
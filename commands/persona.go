@@ -0,0 +1,243 @@
+package commands
+
+import (
+	"log"
+	"strings"
+
+	"redhat-bot/ai"
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// PersonaCommand تنظیم شخصیت، پرامپت سفارشی و زبان پاسخ هوش مصنوعی برای هر گروه
+type PersonaCommand struct {
+	bot     *tgbotapi.BotAPI
+	storage *storage.MySQLStorage
+}
+
+func NewPersonaCommand(bot *tgbotapi.BotAPI, storage *storage.MySQLStorage) *PersonaCommand {
+	return &PersonaCommand{bot: bot, storage: storage}
+}
+
+// BuildMenu ساخت منوی انتخاب شخصیت و زبان با علامت گزینه فعلی
+func (r *PersonaCommand) BuildMenu(chatID int64) tgbotapi.MessageConfig {
+	current, _ := r.storage.GetGroupSetting(chatID, settingAIPersona)
+	language, _ := r.storage.GetGroupSetting(chatID, settingAILanguage)
+	chatEnabled, _ := r.storage.IsFeatureEnabled(chatID, featureAIChat)
+	triggerName, _ := r.storage.GetGroupSetting(chatID, settingAITriggerName)
+	if triggerName == "" {
+		triggerName = defaultAITriggerName
+	}
+
+	mark := func(selected bool) string {
+		if selected {
+			return " ✅"
+		}
+		return ""
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, p := range ai.Personas {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(p.Title+mark(current == p.Key), "persona_set:"+p.Key),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✍️ پرامپت سفارشی"+mark(current == ai.PersonaCustom), "persona_set:"+ai.PersonaCustom),
+			tgbotapi.NewInlineKeyboardButtonData("♻️ پیش‌فرض"+mark(current == ""), "persona_set:none"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🇮🇷 فارسی"+mark(language == "fa"), "persona_lang:fa"),
+			tgbotapi.NewInlineKeyboardButtonData("🇬🇧 English"+mark(language == "en"), "persona_lang:en"),
+			tgbotapi.NewInlineKeyboardButtonData("🌐 خودکار"+mark(language == "auto" || language == ""), "persona_lang:auto"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💬 گفتگو بدون اسلش "+boolIcon(chatEnabled), "persona_chat_toggle"),
+		),
+	)
+
+	text := "🤖 شخصیت هوش مصنوعی\n\nلحن و قوانین پاسخ‌های /covo، /cj و /music را انتخاب کنید.\n\nبرای پرامپت سفارشی بنویسید:\nپرامپت <متن دستورالعمل>\nبرای پاک کردن آن: پرامپت پاک" +
+		"\n\n💬 با فعال بودن گفتگو، اعضا می‌توانند ربات را منشن کنند، پیام را با «" + triggerName + " …» شروع کنند یا روی پاسخ‌های ربات ریپلای کنند." +
+		"\nبرای تغییر نام: نام ربات <نام>"
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return msg
+}
+
+// HandleCallback پردازش دکمه‌های منوی شخصیت (persona_*)
+func (r *PersonaCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	chat := cq.Message.Chat
+	data := cq.Data
+
+	if data != "persona_menu" {
+		if ok, err := r.canConfigure(chat, cq.From.ID); err != nil || !ok {
+			return tgbotapi.NewCallback(cq.ID, "❌ فقط ادمین‌های گروه می‌توانند تنظیمات را تغییر دهند")
+		}
+	}
+
+	switch {
+	case data == "persona_menu":
+		// فقط نمایش منو
+
+	case strings.HasPrefix(data, "persona_set:"):
+		key := strings.TrimPrefix(data, "persona_set:")
+		var err error
+		switch {
+		case key == "none":
+			err = r.storage.DeleteGroupSetting(chat.ID, settingAIPersona)
+		case key == ai.PersonaCustom:
+			prompt, _ := r.storage.GetGroupSetting(chat.ID, settingAICustomPrompt)
+			if prompt == "" {
+				return tgbotapi.NewCallback(cq.ID, "ابتدا با «پرامپت <متن>» یک پرامپت سفارشی ثبت کنید")
+			}
+			err = r.storage.SetGroupSetting(chat.ID, settingAIPersona, key)
+		default:
+			if _, ok := ai.FindPersona(key); !ok {
+				return tgbotapi.NewCallback(cq.ID, "شخصیت نامعتبر")
+			}
+			err = r.storage.SetGroupSetting(chat.ID, settingAIPersona, key)
+		}
+		if err != nil {
+			log.Printf("Error saving ai persona: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در ذخیره تنظیمات")
+		}
+
+	case data == "persona_chat_toggle":
+		enabled, err := r.storage.IsFeatureEnabled(chat.ID, featureAIChat)
+		if err != nil {
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در بررسی وضعیت گفتگو")
+		}
+		if err := r.storage.SetFeatureEnabled(chat.ID, featureAIChat, !enabled); err != nil {
+			log.Printf("Error toggling ai chat: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در تغییر وضعیت گفتگو")
+		}
+
+	case strings.HasPrefix(data, "persona_lang:"):
+		lang := strings.TrimPrefix(data, "persona_lang:")
+		if _, ok := ai.Languages[lang]; !ok {
+			return tgbotapi.NewCallback(cq.ID, "زبان نامعتبر")
+		}
+		if err := r.storage.SetGroupSetting(chat.ID, settingAILanguage, lang); err != nil {
+			log.Printf("Error saving ai language: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در ذخیره تنظیمات")
+		}
+	}
+
+	r.bot.Send(r.BuildMenu(chat.ID))
+	return tgbotapi.NewCallback(cq.ID, "✅")
+}
+
+// HandlePromptText پردازش «پرامپت <متن>» و «پرامپت پاک» برای تنظیم پرامپت سفارشی
+func (r *PersonaCommand) HandlePromptText(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chat := update.Message.Chat
+	chatID := chat.ID
+
+	ok, err := r.canConfigure(chat, update.Message.From.ID)
+	if err != nil {
+		log.Printf("getChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !ok {
+		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند پرامپت را تغییر دهند")
+	}
+
+	prompt := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(update.Message.Text), "پرامپت"))
+	switch prompt {
+	case "":
+		current, _ := r.storage.GetGroupSetting(chatID, settingAICustomPrompt)
+		if current == "" {
+			return tgbotapi.NewMessage(chatID, "ℹ️ پرامپت سفارشی ثبت نشده است.\n\nنحوه استفاده: پرامپت <متن دستورالعمل>")
+		}
+		return tgbotapi.NewMessage(chatID, "📝 پرامپت سفارشی فعلی:\n\n"+current)
+
+	case "پاک":
+		if err := r.storage.DeleteGroupSetting(chatID, settingAICustomPrompt); err != nil {
+			log.Printf("Error clearing custom prompt: %v", err)
+			return tgbotapi.NewMessage(chatID, "❌ خطا در حذف پرامپت")
+		}
+		// اگر شخصیت روی سفارشی بود، به پیش‌فرض برگردد
+		if current, _ := r.storage.GetGroupSetting(chatID, settingAIPersona); current == ai.PersonaCustom {
+			_ = r.storage.DeleteGroupSetting(chatID, settingAIPersona)
+		}
+		return tgbotapi.NewMessage(chatID, "✅ پرامپت سفارشی حذف شد")
+	}
+
+	if len([]rune(prompt)) > 1500 {
+		return tgbotapi.NewMessage(chatID, "❌ پرامپت خیلی طولانی است (حداکثر ۱۵۰۰ کاراکتر)")
+	}
+	if err := r.storage.SetGroupSetting(chatID, settingAICustomPrompt, prompt); err != nil {
+		log.Printf("Error saving custom prompt: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در ذخیره پرامپت")
+	}
+	if err := r.storage.SetGroupSetting(chatID, settingAIPersona, ai.PersonaCustom); err != nil {
+		log.Printf("Error saving ai persona: %v", err)
+	}
+	return tgbotapi.NewMessage(chatID, "✅ پرامپت سفارشی ذخیره و فعال شد")
+}
+
+// IsTriggerNameCommand آیا متن دستور «نام ربات [نام]» است؛ نام باید یک کلمه بدون علامت سؤال باشد تا
+// پرسشی مثل «نام ربات چیه؟» یا «نام ربات‌ها» دستور حساب نشود
+func (r *PersonaCommand) IsTriggerNameCommand(text string) bool {
+	text = strings.TrimSpace(text)
+	if !CommandMatches(text, "نام ربات") {
+		return false
+	}
+	args := strings.Fields(strings.TrimPrefix(text, "نام ربات"))
+	return len(args) == 0 || (len(args) == 1 && !strings.ContainsAny(args[0], "?؟"))
+}
+
+// HandleTriggerNameText پردازش «نام ربات <نام>» برای تغییر نامی که ربات با آن صدا زده می‌شود
+func (r *PersonaCommand) HandleTriggerNameText(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chat := update.Message.Chat
+	chatID := chat.ID
+
+	ok, err := r.canConfigure(chat, update.Message.From.ID)
+	if err != nil {
+		log.Printf("getChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !ok {
+		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند نام ربات را تغییر دهند")
+	}
+
+	name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(update.Message.Text), "نام ربات"))
+	if name == "" {
+		current, _ := r.storage.GetGroupSetting(chatID, settingAITriggerName)
+		if current == "" {
+			current = defaultAITriggerName
+		}
+		return tgbotapi.NewMessage(chatID, "ℹ️ نام فعلی ربات: "+current+"\n\nنحوه تغییر: نام ربات <نام>")
+	}
+	if len(strings.Fields(name)) > 1 || len([]rune(name)) > 32 {
+		return tgbotapi.NewMessage(chatID, "❌ نام باید یک کلمه و حداکثر ۳۲ کاراکتر باشد")
+	}
+	if err := r.storage.SetGroupSetting(chatID, settingAITriggerName, name); err != nil {
+		log.Printf("Error saving trigger name: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در ذخیره نام")
+	}
+	return tgbotapi.NewMessage(chatID, "✅ از این به بعد می‌توانید با «"+name+" …» با ربات صحبت کنید")
+}
+
+// canConfigure در گروه فقط ادمین‌ها و در چت خصوصی خود کاربر اجازه تغییر دارد
+func (r *PersonaCommand) canConfigure(chat *tgbotapi.Chat, userID int64) (bool, error) {
+	if chat.Type == "private" {
+		return true, nil
+	}
+	cfg := tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chat.ID, UserID: userID}}
+	member, err := r.bot.GetChatMember(cfg)
+	if err != nil {
+		return false, err
+	}
+	return member.IsAdministrator() || member.IsCreator(), nil
+}
+
+// boolIcon نمایش وضعیت فعال/غیرفعال
+func boolIcon(enabled bool) string {
+	if enabled {
+		return "✅"
+	}
+	return "❌"
+}
@@ -0,0 +1,25 @@
+package commands
+
+import "testing"
+
+func TestIsTriggerNameCommand(t *testing.T) {
+	r := &PersonaCommand{}
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"نام ربات", true},
+		{"نام ربات کوو", true},
+		{"  نام ربات   رفیق ", true},
+		{"نام ربات چیه؟", false},
+		{"نام ربات چیه?", false},
+		{"نام ربات رو عوض کنید", false},
+		{"نام رباتت چیه", false},
+		{"اسم ربات", false},
+	}
+	for _, tt := range tests {
+		if got := r.IsTriggerNameCommand(tt.text); got != tt.want {
+			t.Errorf("IsTriggerNameCommand(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"redhat-bot/storage"
+)
+
+// promptTemplate یک قالب پرامپت کامپایل‌شده همراه با متن و نسخه آن
+type promptTemplate struct {
+	tmpl    *template.Template
+	body    string
+	version string // منبع + هش کوتاه متن، مثلاً file:1a2b3c4d یا admin:5e6f7a8b
+}
+
+// PromptInfo وضعیت یک قالب برای نمایش به ادمین
+type PromptInfo struct {
+	Name       string
+	Body       string
+	Version    string
+	Overridden bool
+}
+
+// PromptLibrary قالب‌های text/template پرامپت هر قابلیت هوش مصنوعی.
+// قالب‌ها از فایل‌های <name>.tmpl خوانده می‌شوند و ادمین ربات می‌تواند متن هرکدام را در دیتابیس جایگزین کند.
+type PromptLibrary struct {
+	storage   *storage.MySQLStorage
+	mu        sync.RWMutex
+	files     map[string]promptTemplate
+	overrides map[string]promptTemplate
+}
+
+// NewPromptLibrary بارگذاری قالب‌های پوشه dir و قالب‌های سفارشی ذخیره‌شده
+func NewPromptLibrary(storage *storage.MySQLStorage, dir string) (*PromptLibrary, error) {
+	l := &PromptLibrary{
+		storage:   storage,
+		files:     make(map[string]promptTemplate),
+		overrides: make(map[string]promptTemplate),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		t, err := parsePromptTemplate(name, "file", string(body))
+		if err != nil {
+			return nil, fmt.Errorf("prompt template %s: %w", path, err)
+		}
+		l.files[name] = t
+	}
+	if len(l.files) == 0 {
+		log.Printf("⚠️ هیچ قالب پرامپتی در %s پیدا نشد", dir)
+	}
+
+	overrides, err := storage.ListPromptOverrides()
+	if err != nil {
+		log.Printf("Error loading prompt overrides: %v", err)
+	}
+	for _, o := range overrides {
+		t, err := parsePromptTemplate(o.Name, "admin", o.Body)
+		if err != nil {
+			log.Printf("Error parsing prompt override %s: %v", o.Name, err)
+			continue
+		}
+		l.overrides[o.Name] = t
+	}
+	return l, nil
+}
+
+// Render اجرای قالب name با داده data؛ دوم: نسخه قالب به شکل name@source:hash.
+// اگر قالب سفارشی ادمین خطا بدهد (مثلاً فیلد ناموجود)، قالب فایل استفاده می‌شود.
+func (l *PromptLibrary) Render(name string, data any) (string, string, error) {
+	l.mu.RLock()
+	override, hasOverride := l.overrides[name]
+	file, hasFile := l.files[name]
+	l.mu.RUnlock()
+
+	if hasOverride {
+		text, err := executePromptTemplate(override, data)
+		if err == nil {
+			return text, name + "@" + override.version, nil
+		}
+		log.Printf("Error rendering prompt override %s, falling back to file: %v", name, err)
+	}
+	if !hasFile {
+		return "", "", fmt.Errorf("prompt template %q not found", name)
+	}
+	text, err := executePromptTemplate(file, data)
+	if err != nil {
+		return "", "", err
+	}
+	return text, name + "@" + file.version, nil
+}
+
+// List همه قالب‌ها به ترتیب نام
+func (l *PromptLibrary) List() []PromptInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	names := make([]string, 0, len(l.files))
+	for name := range l.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]PromptInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, l.info(name))
+	}
+	return infos
+}
+
+// Get وضعیت فعلی یک قالب؛ false اگر چنین قالبی نیست
+func (l *PromptLibrary) Get(name string) (PromptInfo, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if _, ok := l.files[name]; !ok {
+		return PromptInfo{}, false
+	}
+	return l.info(name), true
+}
+
+// SetOverride جایگزینی متن قالب توسط ادمین؛ فقط برای قالب‌های موجود و با نحو معتبر
+func (l *PromptLibrary) SetOverride(name string, body string, userID int64) (PromptInfo, error) {
+	l.mu.RLock()
+	_, ok := l.files[name]
+	l.mu.RUnlock()
+	if !ok {
+		return PromptInfo{}, fmt.Errorf("prompt template %q not found", name)
+	}
+
+	t, err := parsePromptTemplate(name, "admin", body)
+	if err != nil {
+		return PromptInfo{}, err
+	}
+	if err := l.storage.SavePromptOverride(name, body, userID); err != nil {
+		return PromptInfo{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides[name] = t
+	return l.info(name), nil
+}
+
+// ResetOverride حذف متن سفارشی و بازگشت به فایل
+func (l *PromptLibrary) ResetOverride(name string) error {
+	if err := l.storage.DeletePromptOverride(name); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.overrides, name)
+	return nil
+}
+
+// info وضعیت قالب (با قفل گرفته‌شده)
+func (l *PromptLibrary) info(name string) PromptInfo {
+	if t, ok := l.overrides[name]; ok {
+		return PromptInfo{Name: name, Body: t.body, Version: name + "@" + t.version, Overridden: true}
+	}
+	t := l.files[name]
+	return PromptInfo{Name: name, Body: t.body, Version: name + "@" + t.version}
+}
+
+func parsePromptTemplate(name string, source string, body string) (promptTemplate, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return promptTemplate{}, err
+	}
+	sum := sha256.Sum256([]byte(body))
+	return promptTemplate{
+		tmpl:    tmpl,
+		body:    body,
+		version: source + ":" + hex.EncodeToString(sum[:4]),
+	}, nil
+}
+
+func executePromptTemplate(t promptTemplate, data any) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
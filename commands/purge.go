@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	purgeDefaultCount = 50   // «پاکسازی» بدون عدد
+	purgeMaxCount     = 500  // سقف تعداد پیام در «پاکسازی»
+	purgeMaxRange     = 1000 // سقف بازه «حذف از»
+	deleteBatchSize   = 100  // سقف deleteMessages در هر درخواست
+)
+
+// HandlePurge «پاکسازی [@نام یا شناسه] [تعداد | مدت]» یا روی ریپلای: حذف آخرین پیام‌های ثبت‌شده یک کاربر
+func (m *ModerationCommand) HandlePurge(update tgbotapi.Update) tgbotapi.MessageConfig {
+	target, args, errMsg := m.moderationTarget(update, "پاکسازی", "پیام‌هایش پاک شود", "پاکسازی [@نام یا شناسه] [تعداد مثل 20 یا مدت مثل 30m، ۲ ساعت]")
+	if target == nil {
+		return errMsg
+	}
+	chatID := update.Message.Chat.ID
+
+	// عدد تنها = تعداد پیام؛ عدد با واحد = بازه زمانی
+	count, since, reason := purgeDefaultCount, time.Time{}, ""
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(toLatinDigits(args[0])); err == nil {
+			if n <= 0 {
+				return tgbotapi.NewMessage(chatID, "❌ تعداد نامعتبر است")
+			}
+			count = min(n, purgeMaxCount)
+		} else {
+			duration, _, ok := parseModDuration(args, time.Minute)
+			if !ok || duration == 0 {
+				return tgbotapi.NewMessage(chatID, "❌ تعداد یا مدت نامعتبر است. نمونه: پاکسازی 20 یا پاکسازی 30m یا پاکسازی ۲ ساعت")
+			}
+			count, since = purgeMaxCount, time.Now().Add(-duration)
+			reason = "پیام‌های " + formatModerationDuration(duration) + " اخیر"
+		}
+	}
+
+	ids, err := m.storage.RecentUserMessageIDs(chatID, target.ID, since, count)
+	if err != nil {
+		log.Printf("Error loading user messages for purge: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در دریافت پیام‌های کاربر")
+	}
+	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
+	if len(ids) == 0 {
+		return tgbotapi.NewMessage(chatID, "ℹ️ پیام ثبت‌شده‌ای از "+warnUserName(target)+" پیدا نشد")
+	}
+
+	done := m.deleteWithProgress(chatID, ids, "🧹 پاکسازی پیام‌های "+warnUserName(target))
+	if err := m.storage.DeleteGroupMessagesByID(chatID, ids); err != nil {
+		log.Printf("Error removing purged messages: %v", err)
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("%d پیام آخر", len(ids))
+	}
+	m.Record(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: update.Message.Chat.Title,
+		Actor:     update.Message.From,
+		Target:    target,
+		Action:    modActionPurge,
+		Reason:    reason,
+	})
+	return done(fmt.Sprintf("✅ %d پیام از %s پاک شد", len(ids), warnUserName(target)))
+}
+
+// HandleDeleteRange «حذف از» روی ریپلای: حذف همه پیام‌ها از پیام ریپلای‌شده تا همین پیام
+func (m *ModerationCommand) HandleDeleteRange(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chat := update.Message.Chat
+	chatID := chat.ID
+
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		return tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
+	}
+	isAdmin, err := m.isUserAdmin(chatID, update.Message.From.ID)
+	if err != nil {
+		log.Printf("getChatMember error: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !isAdmin {
+		return tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند پیام حذف کنند")
+	}
+	if update.Message.ReplyToMessage == nil {
+		return tgbotapi.NewMessage(chatID, "لطفاً روی اولین پیامی که باید حذف شود ریپلای کنید و بنویسید: حذف از")
+	}
+
+	from, to := update.Message.ReplyToMessage.MessageID, update.Message.MessageID
+	if to-from+1 > purgeMaxRange {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ بازه بیش از %d پیام است؛ پیام جدیدتری را انتخاب کنید", purgeMaxRange))
+	}
+	// شناسه پیام‌ها در گروه پشت‌سرهم است؛ پیام‌های ربات و پیام‌های ثبت‌نشده هم حذف می‌شوند
+	ids := make([]int, 0, to-from+1)
+	for id := to; id >= from; id-- {
+		ids = append(ids, id)
+	}
+
+	done := m.deleteWithProgress(chatID, ids, "🧹 حذف پیام‌ها")
+	if err := m.storage.DeleteGroupMessagesByID(chatID, ids); err != nil {
+		log.Printf("Error removing deleted messages: %v", err)
+	}
+	m.Record(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: chat.Title,
+		Actor:     update.Message.From,
+		Target:    update.Message.ReplyToMessage.From,
+		Action:    modActionPurge,
+		Reason:    fmt.Sprintf("بازه %d پیام تا اکنون", len(ids)),
+		Excerpt:   messageExcerpt(update.Message.ReplyToMessage),
+	})
+	return done(fmt.Sprintf("✅ %d پیام از بازه انتخاب‌شده پاک شد", len(ids)))
+}
+
+// deleteWithProgress حذف دسته‌ای پیام‌ها؛ برای بیش از یک دسته، پیشرفت در یک پیام وضعیت نمایش داده می‌شود.
+// تابع خروجی متن نهایی را در پیام وضعیت می‌نویسد یا (بدون پیام وضعیت) آن را برای ارسال برمی‌گرداند.
+func (m *ModerationCommand) deleteWithProgress(chatID int64, ids []int, title string) func(text string) tgbotapi.MessageConfig {
+	statusID := 0
+	if len(ids) > deleteBatchSize {
+		status, err := m.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%s: ۰ از %s", title, toPersianDigits(strconv.Itoa(len(ids))))))
+		if err == nil {
+			statusID = status.MessageID
+		}
+	}
+
+	m.deleteMessages(chatID, ids, func(done int) {
+		if statusID == 0 || done == len(ids) {
+			return
+		}
+		text := fmt.Sprintf("%s: %s از %s", title, toPersianDigits(strconv.Itoa(done)), toPersianDigits(strconv.Itoa(len(ids))))
+		_, _ = m.bot.Send(tgbotapi.NewEditMessageText(chatID, statusID, text))
+	})
+
+	return func(text string) tgbotapi.MessageConfig {
+		if statusID == 0 {
+			return tgbotapi.NewMessage(chatID, text)
+		}
+		if _, err := m.bot.Send(tgbotapi.NewEditMessageText(chatID, statusID, text)); err != nil {
+			return tgbotapi.NewMessage(chatID, text)
+		}
+		return tgbotapi.MessageConfig{}
+	}
+}
+
+// deleteMessages حذف پیام‌ها در دسته‌های ۱۰۰تایی با deleteMessages؛ پیام‌های ناموجود نادیده گرفته می‌شوند.
+// progress پس از هر دسته با تعداد پیام‌های پردازش‌شده صدا زده می‌شود.
+func (m *ModerationCommand) deleteMessages(chatID int64, ids []int, progress func(done int)) {
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		batch := ids[start:min(start+deleteBatchSize, len(ids))]
+		params := tgbotapi.Params{"chat_id": strconv.FormatInt(chatID, 10)}
+		if err := params.AddInterface("message_ids", batch); err != nil {
+			log.Printf("Error encoding message ids: %v", err)
+			return
+		}
+		if _, err := m.bot.MakeRequest("deleteMessages", params); err != nil {
+			// اگر حذف دسته‌ای ممکن نبود (مثلاً پیام خیلی قدیمی در دسته)، تک‌تک حذف شود
+			for _, id := range batch {
+				_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: id})
+			}
+		}
+		if progress != nil {
+			progress(start + len(batch))
+		}
+	}
+}
@@ -5,23 +5,28 @@ import (
 	"log"
 	"redhat-bot/ai"
 	"redhat-bot/limiter"
+	"redhat-bot/storage"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type CovoCommand struct {
-	aiClient    *ai.DeepSeekClient
+	ai          *AIService
 	rateLimiter *limiter.RateLimiter
 	bot         *tgbotapi.BotAPI
 }
 
-func NewCovoCommand(aiClient *ai.DeepSeekClient, rateLimiter *limiter.RateLimiter, bot *tgbotapi.BotAPI) *CovoCommand {
-	return &CovoCommand{
-		aiClient:    aiClient,
+func NewCovoCommand(aiService *AIService, rateLimiter *limiter.RateLimiter, bot *tgbotapi.BotAPI) *CovoCommand {
+	r := &CovoCommand{
+		ai:          aiService,
 		rateLimiter: rateLimiter,
 		bot:         bot,
 	}
+	aiService.RegisterRegenerator("covo", r.regenerate)
+	return r
 }
 
 func (r *CovoCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
@@ -43,46 +48,175 @@ func (r *CovoCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
 		return msg
 	}
 
-	// ارسال پیام "در حال پردازش"
-	processingMsg := tgbotapi.NewMessage(chatID, "درحال پردازش - کمی شکیبا باشید ✨")
-	sentMsg, err := r.bot.Send(processingMsg)
+	return r.answer(chatID, userID, 0, question, nil)
+}
+
+// DetectConversation بررسی می‌کند پیام بدون اسلش خطاب به ربات است یا نه:
+// منشن @ربات، شروع با نام ربات یا ریپلای به یکی از پاسخ‌های هوش مصنوعی ربات.
+// سوال استخراج‌شده و تاریخچه لازم برای ادامه گفتگو را برمی‌گرداند.
+func (r *CovoCommand) DetectConversation(update tgbotapi.Update) (string, []ai.Message, bool) {
+	message := update.Message
+	if message == nil || message.From == nil {
+		return "", nil, false
+	}
+	chat := message.Chat
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		return "", nil, false
+	}
+	text := strings.TrimSpace(message.Text)
+	if text == "" || strings.HasPrefix(text, "/") {
+		return "", nil, false
+	}
+	if !r.ai.ChatEnabled(chat.ID) {
+		return "", nil, false
+	}
+
+	reply := message.ReplyToMessage
+
+	// ریپلای به پاسخ هوش مصنوعی ربات -> ادامه همان گفتگو
+	if reply != nil && reply.From != nil && reply.From.ID == r.bot.Self.ID {
+		if prev := r.ai.FindReply(chat.ID, reply.MessageID); prev != nil {
+			history := []ai.Message{
+				{Role: "user", Content: prev.Question},
+				{Role: "assistant", Content: prev.Answer},
+			}
+			return text, history, true
+		}
+	}
+
+	question, called := stripBotMention(text, r.bot.Self.UserName)
+	if !called {
+		question, called = stripTriggerName(text, r.ai.TriggerName(chat.ID))
+	}
+	if !called {
+		return "", nil, false
+	}
+
+	// اگر روی پیام دیگری ریپلای شده، متن آن به‌عنوان زمینه ارسال شود
+	var history []ai.Message
+	if reply != nil && reply.Text != "" {
+		history = append(history, ai.Message{Role: "user", Content: "پیام مورد اشاره:\n" + reply.Text})
+	}
+	return question, history, true
+}
+
+// HandleConversation پاسخ به پیامی که DetectConversation تشخیص داده است
+func (r *CovoCommand) HandleConversation(update tgbotapi.Update, question string, history []ai.Message) tgbotapi.MessageConfig {
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if question == "" {
+		msg := tgbotapi.NewMessage(chatID, "جانم؟ سوالت رو بپرس 🙂")
+		msg.ReplyToMessageID = update.Message.MessageID
+		return msg
+	}
+
+	// بررسی محدودیت درخواست
+	if allowed, message := r.rateLimiter.CheckRateLimit(userID); !allowed {
+		return tgbotapi.NewMessage(chatID, message)
+	}
+
+	return r.answer(chatID, userID, update.Message.MessageID, question, history)
+}
+
+// answer ارسال پیام «در حال پردازش»، دریافت پاسخ و جایگزینی آن با پاسخ نهایی
+func (r *CovoCommand) answer(chatID int64, userID int64, replyTo int, question string, history []ai.Message) tgbotapi.MessageConfig {
+	// اگر مدارشکن باز است، بدون انتظار پیام عدم دسترسی بده
+	if !r.ai.Available() {
+		return tgbotapi.NewMessage(chatID, aiUnavailableText)
+	}
+
+	// ثبت در صف و ارسال پیام "در حال پردازش"
+	job, err := r.ai.Begin(chatID, userID, replyTo)
 	if err != nil {
-		log.Printf("خطا در ارسال پیام پردازش: %v", err)
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه در پردازش سوال شما مشکلی پیش آمد. لطفاً دوباره تلاش کنید."))
 	}
 
-	// دریافت پاسخ از هوش مصنوعی
-	response, err := r.aiClient.AskQuestion(question)
+	answer, err := r.generate(job, userID, question, history, false)
 	if err != nil {
 		log.Printf("خطا در دریافت پاسخ هوش مصنوعی: %v", err)
 		// حذف پیام پردازش و ارسال پیام خطا
-		if sentMsg.MessageID != 0 {
-			deleteMsg := tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID)
+		if job.MessageID != 0 {
+			deleteMsg := tgbotapi.NewDeleteMessage(chatID, job.MessageID)
 			r.bot.Send(deleteMsg)
 		}
-		return tgbotapi.NewMessage(chatID, "❌ متأسفانه در پردازش سوال شما مشکلی پیش آمد. لطفاً دوباره تلاش کنید.")
-	}
-
-	// فرمت‌بندی پاسخ
-	formattedResponse := fmt.Sprintf("🤖 *هوش مصنوعی کوو*\n\n%s", response)
-
-	// ویرایش پیام پردازش با پاسخ نهایی
-	if sentMsg.MessageID != 0 {
-		editMsg := tgbotapi.NewEditMessageText(chatID, sentMsg.MessageID, formattedResponse)
-		editMsg.ParseMode = tgbotapi.ModeMarkdown
-		_, err = r.bot.Send(editMsg)
-		if err != nil {
-			log.Printf("خطا در ویرایش پیام: %v", err)
-			// اگر ویرایش ناموفق بود، پیام جدید ارسال کن
-			msg := tgbotapi.NewMessage(chatID, formattedResponse)
-			msg.ParseMode = tgbotapi.ModeMarkdown
-			return msg
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه در پردازش سوال شما مشکلی پیش آمد. لطفاً دوباره تلاش کنید."))
+	}
+
+	// ویرایش پیام پردازش با پاسخ نهایی (یا ارسال پیام جدید)
+	r.ai.Deliver(job, replyTo, answer)
+	return tgbotapi.MessageConfig{}
+}
+
+// regenerate پاسخ تازه برای همان ورودی و همان تاریخچه گفتگو (دکمه «🔄 پاسخ دیگر»)
+func (r *CovoCommand) regenerate(job *AIJob, prev *storage.AIReply) (AIAnswer, error) {
+	return r.generate(job, prev.UserID, prev.Input, replyHistory(prev), true)
+}
+
+// generate دریافت پاسخ از هوش مصنوعی برای یک سوال (job باید صف‌شده باشد)
+func (r *CovoCommand) generate(job *AIJob, userID int64, question string, history []ai.Message, fresh bool) (AIAnswer, error) {
+	prompt, version, err := r.ai.Prompt("covo", map[string]any{"Question": question})
+	if err != nil {
+		return AIAnswer{}, err
+	}
+
+	// دریافت پاسخ از هوش مصنوعی
+	response, err := r.ai.Run(job, AIRequest{
+		ChatID:        job.ChatID,
+		UserID:        userID,
+		Command:       "covo",
+		Prompt:        prompt,
+		History:       history,
+		PromptVersion: version,
+		Fresh:         fresh,
+	})
+	if err != nil {
+		return AIAnswer{}, err
+	}
+
+	return AIAnswer{
+		Text:          fmt.Sprintf("🤖 *هوش مصنوعی کوو*\n\n%s", response),
+		ParseMode:     tgbotapi.ModeMarkdown,
+		UserID:        userID,
+		Command:       "covo",
+		Input:         question,
+		Question:      question,
+		Answer:        response,
+		History:       history,
+		PromptVersion: version,
+	}, nil
+}
+
+// stripBotMention حذف @یوزرنیم ربات از متن؛ دوم: آیا منشن وجود داشت
+func stripBotMention(text string, botUsername string) (string, bool) {
+	if botUsername == "" {
+		return text, false
+	}
+	// جستجو روی خود متن انجام می‌شود؛ ToLower ممکن است طول بایتی حروف غیرلاتین را تغییر دهد
+	mention := "@" + botUsername
+	for idx := strings.IndexByte(text, '@'); idx >= 0; {
+		if end := idx + len(mention); end <= len(text) && strings.EqualFold(text[idx:end], mention) {
+			rest := text[:idx] + text[end:]
+			return strings.TrimSpace(strings.Trim(strings.TrimSpace(rest), "،,:!")), true
+		}
+		next := strings.IndexByte(text[idx+1:], '@')
+		if next < 0 {
+			break
 		}
-		// پیام خالی برگردان چون پیام ویرایش شده
-		return tgbotapi.MessageConfig{}
+		idx += 1 + next
 	}
+	return text, false
+}
 
-	// اگر پیام پردازش ارسال نشده بود، پیام عادی ارسال کن
-	msg := tgbotapi.NewMessage(chatID, formattedResponse)
-	msg.ParseMode = tgbotapi.ModeMarkdown
-	return msg
+// stripTriggerName اگر متن با نام ربات شروع شود، باقی متن را برمی‌گرداند
+func stripTriggerName(text string, name string) (string, bool) {
+	if name == "" || !strings.HasPrefix(text, name) {
+		return text, false
+	}
+	rest := strings.TrimPrefix(text, name)
+	// نام باید کلمه کامل باشد (مثلاً «کووید» نباید تریگر شود)
+	if next, _ := utf8.DecodeRuneInString(rest); rest != "" && !unicode.IsSpace(next) && !unicode.IsPunct(next) {
+		return text, false
+	}
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(rest), "،,:!")), true
 }
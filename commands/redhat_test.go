@@ -0,0 +1,26 @@
+package commands
+
+import "testing"
+
+func TestStripBotMention(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+		found            bool
+	}{
+		{"plain", "@covobot سلام", "سلام", true},
+		{"case insensitive", "سلام @CovoBot!", "سلام", true},
+		{"non-ascii before mention", "Ⱥ@covobot", "Ⱥ", true},
+		{"persian before mention", "سلام، @covobot چطوری", "سلام،  چطوری", true},
+		{"other mention first", "@someone @covobot hi", "@someone  hi", true},
+		{"no mention", "سلام ربات", "سلام ربات", false},
+		{"truncated mention", "ȺȺ@covo", "ȺȺ@covo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := stripBotMention(tt.text, "covobot")
+			if got != tt.want || found != tt.found {
+				t.Errorf("stripBotMention(%q) = (%q, %v), want (%q, %v)", tt.text, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
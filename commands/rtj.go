@@ -3,25 +3,27 @@ package commands
 import (
 	"fmt"
 	"log"
-	"redhat-bot/ai"
 	"redhat-bot/limiter"
+	"redhat-bot/storage"
 	"strings"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type CovoJokeCommand struct {
-	aiClient    *ai.DeepSeekClient
+	ai          *AIService
 	rateLimiter *limiter.RateLimiter
 	bot         *tgbotapi.BotAPI
 }
 
-func NewCovoJokeCommand(aiClient *ai.DeepSeekClient, rateLimiter *limiter.RateLimiter, bot *tgbotapi.BotAPI) *CovoJokeCommand {
-	return &CovoJokeCommand{
-		aiClient:    aiClient,
+func NewCovoJokeCommand(aiService *AIService, rateLimiter *limiter.RateLimiter, bot *tgbotapi.BotAPI) *CovoJokeCommand {
+	r := &CovoJokeCommand{
+		ai:          aiService,
 		rateLimiter: rateLimiter,
 		bot:         bot,
 	}
+	aiService.RegisterRegenerator("cj", r.regenerate)
+	return r
 }
 
 func (r *CovoJokeCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig {
@@ -42,49 +44,66 @@ func (r *CovoJokeCommand) Handle(update tgbotapi.Update) tgbotapi.MessageConfig
 		return msg
 	}
 
-	// ارسال پیام "در حال پردازش"
-	processingMsg := tgbotapi.NewMessage(chatID, "درحال پردازش - کمی شکیبا باشید ✨")
-	sentMsg, err := r.bot.Send(processingMsg)
-	if err != nil {
-		log.Printf("خطا در ارسال پیام پردازش: %v", err)
+	// اگر مدارشکن باز است، بدون انتظار پیام عدم دسترسی بده
+	if !r.ai.Available() {
+		return tgbotapi.NewMessage(chatID, aiUnavailableText)
 	}
 
-	// ساخت درخواست برای هوش مصنوعی
-	prompt := fmt.Sprintf("هی، یک جوک خنده‌دار و مناسب خانواده درباره '%s' تولید کن و ارسال کن.", topic)
+	// ثبت در صف و ارسال پیام "در حال پردازش"
+	job, err := r.ai.Begin(chatID, userID, 0)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه نتوانستم جوک تولید کنم. لطفاً دوباره تلاش کنید."))
+	}
 
-	// استفاده از AskQuestion برای ارسال درخواست
-	joke, err := r.aiClient.AskQuestion(prompt)
+	answer, err := r.generate(job, userID, topic, false)
 	if err != nil {
 		log.Printf("خطا در تولید جوک: %v", err)
 		// حذف پیام پردازش و ارسال پیام خطا
-		if sentMsg.MessageID != 0 {
-			deleteMsg := tgbotapi.NewDeleteMessage(chatID, sentMsg.MessageID)
+		if job.MessageID != 0 {
+			deleteMsg := tgbotapi.NewDeleteMessage(chatID, job.MessageID)
 			r.bot.Send(deleteMsg)
 		}
-		return tgbotapi.NewMessage(chatID, "❌ متأسفانه نتوانستم جوک تولید کنم. لطفاً دوباره تلاش کنید.")
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه نتوانستم جوک تولید کنم. لطفاً دوباره تلاش کنید."))
 	}
 
-	// فرمت‌بندی پاسخ
-	formattedResponse := fmt.Sprintf("😄 *تولیدکننده جوک کوو*\n\n*موضوع:* %s\n\n%s", topic, joke)
-
-	// ویرایش پیام پردازش با پاسخ نهایی
-	if sentMsg.MessageID != 0 {
-		editMsg := tgbotapi.NewEditMessageText(chatID, sentMsg.MessageID, formattedResponse)
-		editMsg.ParseMode = tgbotapi.ModeMarkdown
-		_, err = r.bot.Send(editMsg)
-		if err != nil {
-			log.Printf("خطا در ویرایش پیام: %v", err)
-			// اگر ویرایش ناموفق بود، پیام جدید ارسال کن
-			msg := tgbotapi.NewMessage(chatID, formattedResponse)
-			msg.ParseMode = tgbotapi.ModeMarkdown
-			return msg
-		}
-		// پیام خالی برگردان چون پیام ویرایش شده
-		return tgbotapi.MessageConfig{}
+	// ویرایش پیام پردازش با پاسخ نهایی (یا ارسال پیام جدید)
+	r.ai.Deliver(job, 0, answer)
+	return tgbotapi.MessageConfig{}
+}
+
+// regenerate پاسخ تازه برای همان ورودی (دکمه «🔄 پاسخ دیگر»)
+func (r *CovoJokeCommand) regenerate(job *AIJob, prev *storage.AIReply) (AIAnswer, error) {
+	return r.generate(job, prev.UserID, prev.Input, true)
+}
+
+// generate تولید جوک درباره topic از قالب cj (job باید صف‌شده باشد)
+func (r *CovoJokeCommand) generate(job *AIJob, userID int64, topic string, fresh bool) (AIAnswer, error) {
+	prompt, version, err := r.ai.Prompt("cj", map[string]any{"Topic": topic})
+	if err != nil {
+		return AIAnswer{}, err
 	}
 
-	// اگر پیام پردازش ارسال نشده بود، پیام عادی ارسال کن
-	msg := tgbotapi.NewMessage(chatID, formattedResponse)
-	msg.ParseMode = tgbotapi.ModeMarkdown
-	return msg
+	joke, err := r.ai.Run(job, AIRequest{
+		ChatID:        job.ChatID,
+		UserID:        userID,
+		Command:       "cj",
+		Prompt:        prompt,
+		PromptVersion: version,
+		Fresh:         fresh,
+	})
+	if err != nil {
+		return AIAnswer{}, err
+	}
+
+	// فرمت‌بندی پاسخ
+	return AIAnswer{
+		Text:          fmt.Sprintf("😄 *تولیدکننده جوک کوو*\n\n*موضوع:* %s\n\n%s", topic, joke),
+		ParseMode:     tgbotapi.ModeMarkdown,
+		UserID:        userID,
+		Command:       "cj",
+		Input:         topic,
+		Question:      prompt,
+		Answer:        joke,
+		PromptVersion: version,
+	}, nil
 }
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"redhat-bot/ai"
+	"redhat-bot/config"
+	"redhat-bot/limiter"
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SessionCommand جلسه‌های گفتگوی خصوصی با هوش مصنوعی (/new، /reset، /history)
+type SessionCommand struct {
+	ai          *AIService
+	storage     *storage.MySQLStorage
+	rateLimiter *limiter.RateLimiter
+	bot         *tgbotapi.BotAPI
+}
+
+func NewSessionCommand(aiService *AIService, storage *storage.MySQLStorage, rateLimiter *limiter.RateLimiter, bot *tgbotapi.BotAPI) *SessionCommand {
+	return &SessionCommand{
+		ai:          aiService,
+		storage:     storage,
+		rateLimiter: rateLimiter,
+		bot:         bot,
+	}
+}
+
+// HandleNew شروع جلسه جدید؛ از این به بعد هر پیام متنی کاربر به هوش مصنوعی می‌رود
+func (r *SessionCommand) HandleNew(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	if update.Message.Chat.Type != "private" {
+		return tgbotapi.NewMessage(chatID, "❌ جلسه گفتگو فقط در چت خصوصی با ربات قابل استفاده است.")
+	}
+
+	if _, err := r.storage.CreateAISession(update.Message.From.ID); err != nil {
+		log.Printf("Error creating ai session: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در شروع جلسه جدید")
+	}
+	return tgbotapi.NewMessage(chatID, "🆕 جلسه گفتگوی جدید شروع شد.\n\nهر پیامی بفرستید با توجه به پیام‌های قبلی همین جلسه جواب می‌دهم.\n\n• /new - شروع دوباره\n• /reset - پایان جلسه\n• /history - جلسه‌های قبلی")
+}
+
+// HandleReset پایان جلسه فعال و بازگشت به حالت عادی
+func (r *SessionCommand) HandleReset(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	if update.Message.Chat.Type != "private" {
+		return tgbotapi.NewMessage(chatID, "❌ جلسه گفتگو فقط در چت خصوصی با ربات قابل استفاده است.")
+	}
+
+	if err := r.storage.EndAISession(update.Message.From.ID); err != nil {
+		log.Printf("Error ending ai session: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در پایان جلسه")
+	}
+	return tgbotapi.NewMessage(chatID, "✅ جلسه گفتگو بسته شد. برای شروع دوباره /new را بزنید.")
+}
+
+// HandleHistory فهرست جلسه‌های قبلی با دکمه ادامه هر جلسه
+func (r *SessionCommand) HandleHistory(update tgbotapi.Update) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+	if update.Message.Chat.Type != "private" {
+		return tgbotapi.NewMessage(chatID, "❌ جلسه گفتگو فقط در چت خصوصی با ربات قابل استفاده است.")
+	}
+
+	sessions, err := r.storage.ListAISessions(userID, 10)
+	if err != nil {
+		log.Printf("Error listing ai sessions: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در دریافت جلسه‌ها")
+	}
+	if len(sessions) == 0 {
+		return tgbotapi.NewMessage(chatID, "📭 هنوز جلسه‌ای ندارید. با /new شروع کنید.")
+	}
+
+	var b strings.Builder
+	b.WriteString("🗂️ جلسه‌های گفتگوی شما:\n\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, s := range sessions {
+		mark := ""
+		if s.Active {
+			mark = " (فعال)"
+		}
+		fmt.Fprintf(&b, "%d. %s%s\n   %s | %d پیام\n", i+1, s.Title, mark, s.UpdatedAt.Format("2006-01-02 15:04"), s.Messages)
+		if !s.Active {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("↩️ ادامه جلسه %d", i+1), fmt.Sprintf("session_open:%d", s.ID)),
+			))
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	return msg
+}
+
+// HandleCallback دکمه ادامه جلسه قبلی (session_open:<id>)
+func (r *SessionCommand) HandleCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	var id uint
+	if _, err := fmt.Sscanf(cq.Data, "session_open:%d", &id); err != nil {
+		return tgbotapi.NewCallback(cq.ID, "شناسه نامعتبر")
+	}
+	found, err := r.storage.ActivateAISession(cq.From.ID, id)
+	if err != nil {
+		log.Printf("Error activating ai session: %v", err)
+		return tgbotapi.NewCallback(cq.ID, "❌ خطا در بازکردن جلسه")
+	}
+	if !found {
+		return tgbotapi.NewCallback(cq.ID, "جلسه پیدا نشد")
+	}
+	r.bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "↩️ جلسه قبلی دوباره فعال شد. ادامه بدهید!"))
+	return tgbotapi.NewCallback(cq.ID, "✅")
+}
+
+// ActiveSession جلسه فعال کاربر در چت خصوصی (یا nil)
+func (r *SessionCommand) ActiveSession(message *tgbotapi.Message) *storage.AISession {
+	if message.Chat.Type != "private" || message.From == nil || strings.TrimSpace(message.Text) == "" {
+		return nil
+	}
+	session, err := r.storage.GetActiveAISession(message.From.ID)
+	if err != nil {
+		log.Printf("Error reading ai session: %v", err)
+		return nil
+	}
+	return session
+}
+
+// HandleMessage ارسال پیام کاربر همراه با تاریخچه جلسه به هوش مصنوعی
+func (r *SessionCommand) HandleMessage(update tgbotapi.Update, session *storage.AISession) tgbotapi.MessageConfig {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+	text := strings.TrimSpace(update.Message.Text)
+
+	if limit := config.AppConfig.AISessionMaxTokens; limit > 0 && session.Tokens >= limit {
+		return tgbotapi.NewMessage(chatID, "⚠️ این جلسه به سقف طول گفتگو رسیده است. برای ادامه با /new یک جلسه جدید شروع کنید.")
+	}
+
+	// بررسی محدودیت درخواست
+	if allowed, message := r.rateLimiter.CheckRateLimit(userID); !allowed {
+		return tgbotapi.NewMessage(chatID, message)
+	}
+
+	// اگر مدارشکن باز است، بدون انتظار پیام عدم دسترسی بده
+	if !r.ai.Available() {
+		return tgbotapi.NewMessage(chatID, aiUnavailableText)
+	}
+
+	past, err := r.storage.RecentAISessionMessages(session.ID, config.AppConfig.AISessionHistory)
+	if err != nil {
+		log.Printf("Error reading session history: %v", err)
+	}
+	history := make([]ai.Message, 0, len(past))
+	for _, m := range past {
+		history = append(history, ai.Message{Role: m.Role, Content: m.Content})
+	}
+
+	// ثبت در صف و ارسال پیام "در حال پردازش"
+	job, err := r.ai.Begin(chatID, userID, 0)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه در پردازش پیام شما مشکلی پیش آمد. لطفاً دوباره تلاش کنید."))
+	}
+
+	response, err := r.ai.Run(job, AIRequest{
+		ChatID:    chatID,
+		UserID:    userID,
+		Command:   "session",
+		Prompt:    text,
+		History:   history,
+		SessionID: session.ID,
+	})
+	if err != nil {
+		log.Printf("خطا در پاسخ جلسه: %v", err)
+		if job.MessageID != 0 {
+			r.bot.Send(tgbotapi.NewDeleteMessage(chatID, job.MessageID))
+		}
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه در پردازش پیام شما مشکلی پیش آمد. لطفاً دوباره تلاش کنید."))
+	}
+
+	if err := r.storage.AddAISessionMessage(session.ID, "user", text); err != nil {
+		log.Printf("Error saving session message: %v", err)
+	}
+	if err := r.storage.AddAISessionMessage(session.ID, "assistant", response); err != nil {
+		log.Printf("Error saving session message: %v", err)
+	}
+
+	// ویرایش پیام پردازش با پاسخ نهایی
+	if job.MessageID != 0 {
+		_, err = r.bot.Send(tgbotapi.NewEditMessageText(chatID, job.MessageID, response))
+		if err == nil {
+			return tgbotapi.MessageConfig{}
+		}
+		log.Printf("خطا در ویرایش پیام: %v", err)
+	}
+	return tgbotapi.NewMessage(chatID, response)
+}
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxModDuration بیشترین مدت سکوت/بن موقت؛ تلگرام مدت بیشتر از ۳۶۶ روز را دائمی در نظر می‌گیرد
+const maxModDuration = 366 * 24 * time.Hour
+
+// modDurationUnits واحدهای قابل قبول مدت (لاتین و فارسی)
+var modDurationUnits = map[string]time.Duration{
+	"m": time.Minute, "min": time.Minute, "دقیقه": time.Minute, "دقیقه‌ای": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "ساعت": time.Hour, "ساعته": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "روز": 24 * time.Hour, "روزه": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "هفته": 7 * 24 * time.Hour,
+}
+
+// CommandMatches آیا متن همان دستور است (خود دستور یا دستور + فاصله یا خط جدید + آرگومان)؛
+// «پرامپتش» یا «بنده» دستور حساب نمی‌شوند
+func CommandMatches(text string, command string) bool {
+	rest, ok := strings.CutPrefix(text, command)
+	if !ok {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || unicode.IsSpace(first)
+}
+
+// commandArgs آرگومان‌های بعد از دستور؛ متن text_mentionها حذف می‌شود چون کاربر هدف از خود entity خوانده می‌شود
+func commandArgs(message *tgbotapi.Message, command string) []string {
+	units := utf16.Encode([]rune(message.Text))
+	for _, e := range message.Entities {
+		if e.Type != "text_mention" || e.Offset < 0 || e.Offset+e.Length > len(units) {
+			continue
+		}
+		for i := e.Offset; i < e.Offset+e.Length; i++ {
+			units[i] = ' '
+		}
+	}
+	text := strings.TrimSpace(string(utf16.Decode(units)))
+	return strings.Fields(strings.TrimPrefix(text, command))
+}
+
+// parseModDuration مدت از ابتدای آرگومان‌ها: «30m»، «2h»، «3d»، «1w»، «۲ ساعت»، «۱ روز» یا عدد تنها با واحد پیش‌فرض.
+// defaultUnit صفر یعنی نوشتن واحد الزامی است. اگر مدتی نوشته نشده باشد صفر برمی‌گرداند؛
+// false یعنی آرگومان اول عددی است ولی مدت معتبری نیست.
+func parseModDuration(args []string, defaultUnit time.Duration) (time.Duration, []string, bool) {
+	if len(args) == 0 {
+		return 0, args, true
+	}
+	token := strings.ToLower(toLatinDigits(args[0]))
+	digits := strings.IndexFunc(token, func(r rune) bool { return r < '0' || r > '9' })
+	if digits == 0 {
+		return 0, args, true
+	}
+	if digits < 0 {
+		digits = len(token)
+	}
+	n, err := strconv.Atoi(token[:digits])
+	if err != nil || n <= 0 {
+		return 0, args, false
+	}
+
+	unit, rest := defaultUnit, args[1:]
+	if suffix := token[digits:]; suffix != "" {
+		u, ok := modDurationUnits[suffix]
+		if !ok {
+			return 0, args, false
+		}
+		unit = u
+	} else if len(rest) > 0 {
+		if u, ok := modDurationUnits[strings.ToLower(rest[0])]; ok {
+			unit, rest = u, rest[1:]
+		}
+	}
+	if unit == 0 {
+		return 0, args, false
+	}
+
+	d := time.Duration(n) * unit
+	if d > maxModDuration || d/unit != time.Duration(n) {
+		return 0, args, false
+	}
+	return d, rest, true
+}
+
+// startsWithTarget آیا آرگومان‌های بعد از دستور با هدف (@نام یا شناسه عددی) یا مدت معتبر شروع می‌شوند؛
+// برای دستورهای بدون مدت durationUnit منفی است. تا «سکوت لطفاً» یا «بن شدی؟» دستور حساب نشود.
+func startsWithTarget(args []string, durationUnit time.Duration) bool {
+	if len(args) == 0 {
+		return false
+	}
+	arg := toLatinDigits(args[0])
+	if (strings.HasPrefix(arg, "@") && len(arg) > 1) || isNumericID(arg) {
+		return true
+	}
+	if durationUnit < 0 {
+		return false
+	}
+	d, _, ok := parseModDuration(args, durationUnit)
+	return ok && d > 0
+}
+
+// moderationTarget بررسی‌های مشترک دستورات مدیریتی: گروه، ادمین بودن درخواست‌کننده و کاربر هدف غیرادمین.
+// هدف به ترتیب از ریپلای، text_mention، @نام (از اعضای ثبت‌شده گروه) یا شناسه عددی خوانده می‌شود.
+// اگر کاربر هدف nil باشد، پیام سوم باید برای کاربر ارسال شود؛ خروجی دوم آرگومان‌های باقی‌مانده است.
+func (m *ModerationCommand) moderationTarget(update tgbotapi.Update, command string, action string, usage string) (*tgbotapi.User, []string, tgbotapi.MessageConfig) {
+	message := update.Message
+	chatID := message.Chat.ID
+
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		return nil, nil, tgbotapi.NewMessage(chatID, "❌ این دستور فقط در گروه‌ها قابل استفاده است")
+	}
+
+	isAdmin, err := m.isUserAdmin(chatID, message.From.ID)
+	if err != nil {
+		log.Printf("getChatMember error (requester): %v", err)
+		return nil, nil, tgbotapi.NewMessage(chatID, "❌ خطا در بررسی دسترسی ادمین")
+	}
+	if !isAdmin {
+		return nil, nil, tgbotapi.NewMessage(chatID, "❌ فقط ادمین‌های گروه می‌توانند از این دستور استفاده کنند")
+	}
+
+	args := commandArgs(message, command)
+	var target *tgbotapi.User
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil {
+		target = reply.From
+	}
+	for _, e := range message.Entities {
+		if target == nil && e.Type == "text_mention" && e.User != nil {
+			target = e.User
+		}
+	}
+	if target == nil && len(args) > 0 {
+		arg := toLatinDigits(args[0])
+		switch {
+		case strings.HasPrefix(arg, "@") && len(arg) > 1:
+			member, err := m.storage.FindGroupMemberByName(chatID, arg)
+			if err != nil {
+				log.Printf("Error finding group member %s: %v", arg, err)
+				return nil, nil, tgbotapi.NewMessage(chatID, "❌ خطا در جستجوی کاربر")
+			}
+			if member == nil {
+				return nil, nil, tgbotapi.NewMessage(chatID, "❌ کاربر "+arg+" بین اعضای ثبت‌شده این گروه پیدا نشد. روی پیامش ریپلای کنید یا شناسه عددی‌اش را بنویسید")
+			}
+			target = m.lookupUser(chatID, member.UserID)
+			args = args[1:]
+		case isNumericID(arg):
+			id, _ := strconv.ParseInt(arg, 10, 64)
+			target = m.lookupUser(chatID, id)
+			args = args[1:]
+		}
+	}
+	if target == nil {
+		return nil, nil, tgbotapi.NewMessage(chatID, "لطفاً روی پیام کاربری که می‌خواهید "+action+" ریپلای کنید یا او را با @نام یا شناسه مشخص کنید:\n"+usage)
+	}
+	if target.IsBot {
+		return nil, nil, tgbotapi.NewMessage(chatID, "❌ این دستور برای ربات‌ها قابل استفاده نیست")
+	}
+
+	isTargetAdmin, err := m.isUserAdmin(chatID, target.ID)
+	if err != nil {
+		log.Printf("getChatMember error (target): %v", err)
+		return nil, nil, tgbotapi.NewMessage(chatID, "❌ خطا در بررسی نقش کاربر هدف")
+	}
+	if isTargetAdmin {
+		return nil, nil, tgbotapi.NewMessage(chatID, "❌ این دستور روی ادمین یا صاحب گروه قابل اجرا نیست")
+	}
+	return target, args, tgbotapi.MessageConfig{}
+}
+
+// lookupUser اطلاعات کاربر از تلگرام؛ اگر در دسترس نباشد فقط شناسه برمی‌گردد
+func (m *ModerationCommand) lookupUser(chatID int64, userID int64) *tgbotapi.User {
+	cfg := tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID}}
+	if member, err := m.bot.GetChatMember(cfg); err == nil && member.User != nil {
+		return member.User
+	}
+	return &tgbotapi.User{ID: userID}
+}
+
+// isNumericID شناسه عددی کاربر (برای تمایز از مدت‌هایی مثل «۲» حداقل ۵ رقم)
+func isNumericID(s string) bool {
+	if len(s) < 5 {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) || r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseModDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		defaultUnit time.Duration
+		want        time.Duration
+		rest        []string
+		ok          bool
+	}{
+		{"no args", nil, time.Hour, 0, nil, true},
+		{"reason only", []string{"اسپم"}, time.Hour, 0, []string{"اسپم"}, true},
+		{"latin suffix", []string{"30m", "اسپم"}, time.Hour, 30 * time.Minute, []string{"اسپم"}, true},
+		{"upper case suffix", []string{"2H"}, time.Hour, 2 * time.Hour, []string{}, true},
+		{"persian digits and unit", []string{"۲", "ساعت", "تبلیغ"}, time.Minute, 2 * time.Hour, []string{"تبلیغ"}, true},
+		{"arabic digits", []string{"٣d"}, 0, 3 * 24 * time.Hour, []string{}, true},
+		{"week", []string{"1", "هفته"}, 0, 7 * 24 * time.Hour, []string{}, true},
+		{"bare number uses default unit", []string{"3", "اسپم"}, time.Hour, 3 * time.Hour, []string{"اسپم"}, true},
+		{"bare number without default unit", []string{"3", "اسپم"}, 0, 0, []string{"3", "اسپم"}, false},
+		{"unknown suffix", []string{"3x"}, time.Hour, 0, []string{"3x"}, false},
+		{"zero", []string{"0m"}, time.Hour, 0, []string{"0m"}, false},
+		{"over limit", []string{"400d"}, time.Hour, 0, []string{"400d"}, false},
+		{"overflow", []string{"99999999999999w"}, time.Hour, 0, []string{"99999999999999w"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, ok := parseModDuration(tt.args, tt.defaultUnit)
+			if got != tt.want || ok != tt.ok || len(rest) != len(tt.rest) || (len(rest) > 0 && !reflect.DeepEqual(rest, tt.rest)) {
+				t.Fatalf("parseModDuration(%q) = (%v, %q, %v), want (%v, %q, %v)", tt.args, got, rest, ok, tt.want, tt.rest, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIsNumericID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"123456789", true},
+		{"12345", true},
+		{"1234", false},
+		{"", false},
+		{"12a456", false},
+		{"-123456", false},
+		{"۱۲۳۴۵۶", false}, // ارقام فارسی پیش از این تابع با toLatinDigits تبدیل می‌شوند
+	}
+	for _, tt := range tests {
+		if got := isNumericID(tt.in); got != tt.want {
+			t.Errorf("isNumericID(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []tgbotapi.MessageEntity
+		command  string
+		want     []string
+	}{
+		{"no args", "بن", nil, "بن", []string{}},
+		{"surrounding spaces", "  سکوت   2h  اسپم ", nil, "سکوت", []string{"2h", "اسپم"}},
+		{"username", "بن @user 3d", nil, "بن", []string{"@user", "3d"}},
+		{
+			name:     "text mention removed",
+			text:     "سکوت Ali Reza 2h",
+			entities: []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 5, Length: 8}},
+			command:  "سکوت",
+			want:     []string{"2h"},
+		},
+		{
+			// ایموجی در UTF-16 دو واحد است؛ offset تلگرام بر حسب UTF-16 است
+			name:     "text mention after emoji",
+			text:     "بن 😀 Ali 1d",
+			entities: []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 6, Length: 3}},
+			command:  "بن",
+			want:     []string{"😀", "1d"},
+		},
+		{
+			name:     "other entities kept",
+			text:     "بن @user",
+			entities: []tgbotapi.MessageEntity{{Type: "mention", Offset: 3, Length: 5}},
+			command:  "بن",
+			want:     []string{"@user"},
+		},
+		{
+			name:     "out of range entity ignored",
+			text:     "بن 2h",
+			entities: []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 3, Length: 50}},
+			command:  "بن",
+			want:     []string{"2h"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commandArgs(&tgbotapi.Message{Text: tt.text, Entities: tt.entities}, tt.command)
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Fatalf("commandArgs(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTargetCommand(t *testing.T) {
+	m := &ModerationCommand{}
+	tests := []struct {
+		name     string
+		text     string
+		entities []tgbotapi.MessageEntity
+		want     bool
+	}{
+		{"bare ban", "بن", nil, true},
+		{"bare unmute without madda", "ازاد", nil, true},
+		{"ban with username", "بن @user", nil, true},
+		{"ban with id", "بن 123456789 1d", nil, true},
+		{"ban with duration", "بن 3d اسپم", nil, true},
+		{"ban with persian duration", "بن ۲ ساعت", nil, true},
+		{"ban with bare number", "بن 3 اسپم", nil, false},
+		{"ban chat", "بن شدی؟", nil, false},
+		{"mute with bare number", "سکوت 2", nil, true},
+		{"mute please", "سکوت لطفاً", nil, false},
+		{"unban with username", "رفع بن @user", nil, true},
+		{"unmute with duration", "آزاد 2h", nil, false},
+		{"free chat", "آزاد باشید", nil, false},
+		{
+			name:     "text mention with reason",
+			text:     "بن Ali اسپم",
+			entities: []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 3, Length: 3, User: &tgbotapi.User{ID: 42}}},
+			want:     true,
+		},
+		{"word starting with command", "بنده", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.IsTargetCommand(&tgbotapi.Message{Text: tt.text, Entities: tt.entities}); got != tt.want {
+				t.Fatalf("IsTargetCommand(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandMatches(t *testing.T) {
+	tests := []struct {
+		text, command string
+		want          bool
+	}{
+		{"پرامپت", "پرامپت", true},
+		{"پرامپت مودب باش", "پرامپت", true},
+		{"پرامپت\nمودب باش", "پرامپت", true},
+		{"پرامپتش خوب بود", "پرامپت", false},
+		{"نام ربات چیه؟", "نام ربات", true},
+		{"نام رباتت چیه؟", "نام ربات", false},
+		{"بنده", "بن", false},
+		{"", "بن", false},
+	}
+	for _, tt := range tests {
+		if got := CommandMatches(tt.text, tt.command); got != tt.want {
+			t.Errorf("CommandMatches(%q, %q) = %v, want %v", tt.text, tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"log"
+	"strings"
+	"unicode"
+
+	"redhat-bot/limiter"
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// قابلیت‌های ابزار متن در FeatureSetting هر گروه
+const (
+	featureTranslate = "translate"
+	featureFinglish  = "finglish"
+	featureFormal    = "formal"
+)
+
+// translateLanguages نام‌های قابل قبول برای زبان مقصد -> نام انگلیسی برای مدل و نام فارسی برای نمایش
+var translateLanguages = map[string]struct{ English, Persian string }{
+	"فارسی":     {"Persian", "فارسی"},
+	"fa":        {"Persian", "فارسی"},
+	"انگلیسی":   {"English", "انگلیسی"},
+	"en":        {"English", "انگلیسی"},
+	"عربی":      {"Arabic", "عربی"},
+	"ar":        {"Arabic", "عربی"},
+	"ترکی":      {"Turkish", "ترکی"},
+	"tr":        {"Turkish", "ترکی"},
+	"فرانسوی":   {"French", "فرانسوی"},
+	"fr":        {"French", "فرانسوی"},
+	"آلمانی":    {"German", "آلمانی"},
+	"de":        {"German", "آلمانی"},
+	"روسی":      {"Russian", "روسی"},
+	"ru":        {"Russian", "روسی"},
+	"اسپانیایی": {"Spanish", "اسپانیایی"},
+	"es":        {"Spanish", "اسپانیایی"},
+	"ایتالیایی": {"Italian", "ایتالیایی"},
+	"it":        {"Italian", "ایتالیایی"},
+	"چینی":      {"Chinese", "چینی"},
+	"zh":        {"Chinese", "چینی"},
+	"ژاپنی":     {"Japanese", "ژاپنی"},
+	"ja":        {"Japanese", "ژاپنی"},
+	"کره‌ای":    {"Korean", "کره‌ای"},
+	"ko":        {"Korean", "کره‌ای"},
+}
+
+// TextToolRequest درخواست تشخیص‌داده‌شده از متن پیام
+type TextToolRequest struct {
+	Feature  string // translate, finglish, formal
+	Language string // فقط برای ترجمه؛ خالی = تشخیص خودکار
+}
+
+// TranslateCommand ترجمه، تبدیل فینگلیش به فارسی و رسمی‌نویسی پیامِ ریپلای‌شده
+type TranslateCommand struct {
+	ai          *AIService
+	rateLimiter *limiter.RateLimiter
+	storage     *storage.MySQLStorage
+	bot         *tgbotapi.BotAPI
+}
+
+func NewTranslateCommand(aiService *AIService, rateLimiter *limiter.RateLimiter, storage *storage.MySQLStorage, bot *tgbotapi.BotAPI) *TranslateCommand {
+	return &TranslateCommand{
+		ai:          aiService,
+		rateLimiter: rateLimiter,
+		storage:     storage,
+		bot:         bot,
+	}
+}
+
+// Detect آیا متن یکی از دستورات «ترجمه [زبان]»، «/tr [زبان]»، «فارسی کن» یا «رسمی کن» است؟
+func (r *TranslateCommand) Detect(text string) (TextToolRequest, bool) {
+	text = strings.TrimSpace(text)
+	switch text {
+	case "فارسی کن":
+		return TextToolRequest{Feature: featureFinglish}, true
+	case "رسمی کن":
+		return TextToolRequest{Feature: featureFormal}, true
+	}
+
+	var rest string
+	switch {
+	case text == "ترجمه" || strings.HasPrefix(text, "ترجمه "):
+		rest = strings.TrimPrefix(text, "ترجمه")
+	case text == "/tr" || strings.HasPrefix(text, "/tr ") || strings.HasPrefix(text, "/tr@"):
+		rest = strings.TrimPrefix(text, "/tr")
+		if strings.HasPrefix(rest, "@") {
+			_, rest, _ = strings.Cut(rest, " ")
+		}
+	default:
+		return TextToolRequest{}, false
+	}
+
+	lang := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), "به")))
+	if lang == "" {
+		return TextToolRequest{Feature: featureTranslate}, true
+	}
+	if _, ok := translateLanguages[lang]; !ok {
+		// «ترجمه» در وسط یک جمله عادی نباید دستور حساب شود
+		return TextToolRequest{}, false
+	}
+	return TextToolRequest{Feature: featureTranslate, Language: lang}, true
+}
+
+// Handle اجرای ابزار متن روی پیام ریپلای‌شده
+func (r *TranslateCommand) Handle(update tgbotapi.Update, req TextToolRequest) tgbotapi.MessageConfig {
+	message := update.Message
+	chatID := message.Chat.ID
+	userID := message.From.ID
+
+	if message.Chat.Type != "private" {
+		if enabled, err := r.storage.IsFeatureEnabled(chatID, req.Feature); err != nil || !enabled {
+			return tgbotapi.NewMessage(chatID, "❌ این قابلیت در این گروه غیرفعال است (پنل ← قابلیت‌ها ← ابزارهای متن)")
+		}
+	}
+
+	reply := message.ReplyToMessage
+	source := ""
+	if reply != nil {
+		source = reply.Text
+		if source == "" {
+			source = reply.Caption
+		}
+	}
+	if strings.TrimSpace(source) == "" {
+		return tgbotapi.NewMessage(chatID, "ℹ️ این دستور را روی یک پیام متنی ریپلای کنید.\n\nمثال: ترجمه انگلیسی، /tr fa، فارسی کن، رسمی کن")
+	}
+	if len([]rune(source)) > 3000 {
+		return tgbotapi.NewMessage(chatID, "❌ متن خیلی طولانی است (حداکثر ۳۰۰۰ کاراکتر)")
+	}
+
+	// بررسی محدودیت درخواست
+	if allowed, msg := r.rateLimiter.CheckRateLimit(userID); !allowed {
+		return tgbotapi.NewMessage(chatID, msg)
+	}
+	// اگر مدارشکن باز است، بدون انتظار پیام عدم دسترسی بده
+	if !r.ai.Available() {
+		return tgbotapi.NewMessage(chatID, aiUnavailableText)
+	}
+
+	system, version, header, err := r.instructions(req, source)
+	if err != nil {
+		log.Printf("خطا در ساخت پرامپت (%s): %v", req.Feature, err)
+		return tgbotapi.NewMessage(chatID, "❌ متأسفانه در پردازش متن مشکلی پیش آمد. لطفاً دوباره تلاش کنید.")
+	}
+
+	// ثبت در صف و ارسال پیام "در حال پردازش"
+	job, err := r.ai.Begin(chatID, userID, reply.MessageID)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه در پردازش متن مشکلی پیش آمد. لطفاً دوباره تلاش کنید."))
+	}
+
+	result, err := r.ai.Run(job, AIRequest{
+		ChatID:        chatID,
+		UserID:        userID,
+		Command:       req.Feature,
+		Prompt:        source,
+		System:        system,
+		PromptVersion: version,
+	})
+	if err != nil {
+		log.Printf("خطا در پردازش متن (%s): %v", req.Feature, err)
+		if job.MessageID != 0 {
+			r.bot.Send(tgbotapi.NewDeleteMessage(chatID, job.MessageID))
+		}
+		return tgbotapi.NewMessage(chatID, aiErrorText(err, "❌ متأسفانه در پردازش متن مشکلی پیش آمد. لطفاً دوباره تلاش کنید."))
+	}
+
+	text := header + "\n\n" + strings.TrimSpace(result)
+	if job.MessageID != 0 {
+		_, err = r.bot.Send(tgbotapi.NewEditMessageText(chatID, job.MessageID, text))
+		if err == nil {
+			return tgbotapi.MessageConfig{}
+		}
+		log.Printf("خطا در ویرایش پیام: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyToMessageID = reply.MessageID
+	return msg
+}
+
+// instructions پیام سیستمی (از قالب هم‌نام ابزار)، نسخه قالب و عنوان پاسخ برای هر ابزار
+func (r *TranslateCommand) instructions(req TextToolRequest, source string) (string, string, string, error) {
+	switch req.Feature {
+	case featureFinglish:
+		system, version, err := r.ai.Prompt(featureFinglish, nil)
+		return system, version, "🔤 فارسی‌شده:", err
+	case featureFormal:
+		system, version, err := r.ai.Prompt(featureFormal, nil)
+		return system, version, "👔 نسخه رسمی:", err
+	}
+
+	lang, ok := translateLanguages[req.Language]
+	if !ok {
+		// تشخیص خودکار: متن فارسی -> انگلیسی، بقیه -> فارسی
+		lang = translateLanguages["fa"]
+		if isMostlyPersian(source) {
+			lang = translateLanguages["en"]
+		}
+	}
+	system, version, err := r.ai.Prompt(featureTranslate, map[string]any{"Language": lang.English})
+	return system, version, "🌐 ترجمه (" + lang.Persian + "):", err
+}
+
+// isMostlyPersian آیا بیشتر حروف متن از الفبای عربی/فارسی است؟
+func isMostlyPersian(text string) bool {
+	persian, latin := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Arabic, r):
+			persian++
+		case unicode.IsLetter(r) && r < unicode.MaxLatin1:
+			latin++
+		}
+	}
+	return persian > latin
+}
@@ -0,0 +1,405 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"redhat-bot/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// تنظیمات پلکان اخطار در GroupSetting هر گروه (مقدار ۰ = غیرفعال)
+const (
+	settingWarnMuteAt     = "warn_mute_at"
+	settingWarnMuteHours  = "warn_mute_hours"
+	settingWarnBanAt      = "warn_ban_at"
+	settingWarnExpiryDays = "warn_expiry_days"
+
+	// featureLockWarn اگر فعال باشد، تخلف از قفل لینک و فحش به‌جای حذف بی‌صدا اخطار هم می‌گیرد
+	featureLockWarn = "lock_warn"
+)
+
+// warnLadderOptions مقادیر قابل انتخاب هر تنظیم در پنل؛ اولین مقدار پیش‌فرض است و هر بار کلیک مقدار بعدی را انتخاب می‌کند
+var warnLadderOptions = map[string][]int{
+	settingWarnMuteAt:     {3, 4, 5, 2, 0},
+	settingWarnMuteHours:  {24, 72, 168, 1, 6, 12},
+	settingWarnBanAt:      {5, 7, 10, 3, 0},
+	settingWarnExpiryDays: {30, 90, 0, 1, 7},
+}
+
+// WarnLadder پلکان برخورد با اخطارهای یک گروه
+type WarnLadder struct {
+	MuteAt     int // تعداد اخطار برای سکوت
+	MuteHours  int
+	BanAt      int // تعداد اخطار برای بن
+	ExpiryDays int // عمر هر اخطار؛ ۰ = همیشگی
+}
+
+// loadWarnLadder خواندن پلکان اخطار گروه با مقادیر پیش‌فرض برای تنظیمات ثبت‌نشده
+func loadWarnLadder(s *storage.MySQLStorage, chatID int64) WarnLadder {
+	value := func(key string) int {
+		return groupIntSetting(s, chatID, key, warnLadderOptions[key])
+	}
+	return WarnLadder{
+		MuteAt:     value(settingWarnMuteAt),
+		MuteHours:  value(settingWarnMuteHours),
+		BanAt:      value(settingWarnBanAt),
+		ExpiryDays: value(settingWarnExpiryDays),
+	}
+}
+
+// groupIntSetting مقدار عددی یک تنظیم گروه؛ اگر ثبت نشده یا نامعتبر باشد، اولین گزینه (پیش‌فرض)
+func groupIntSetting(s *storage.MySQLStorage, chatID int64, key string, options []int) int {
+	raw, err := s.GetGroupSetting(chatID, key)
+	if err != nil {
+		log.Printf("Error loading group setting %s: %v", key, err)
+	}
+	if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+		return n
+	}
+	return options[0]
+}
+
+// cycleGroupIntSetting ذخیره گزینه بعدی یک تنظیم عددی برای دکمه‌های چرخشی پنل
+func cycleGroupIntSetting(s *storage.MySQLStorage, chatID int64, key string, options []int) error {
+	current := groupIntSetting(s, chatID, key, options)
+	next := options[0]
+	for i, option := range options {
+		if option == current {
+			next = options[(i+1)%len(options)]
+			break
+		}
+	}
+	return s.SetGroupSetting(chatID, key, strconv.Itoa(next))
+}
+
+// HandleWarn «اخطار [@نام یا شناسه] [دلیل]» یا روی ریپلای: ثبت اخطار و اعمال پلکان سکوت/بن
+func (m *ModerationCommand) HandleWarn(update tgbotapi.Update) tgbotapi.MessageConfig {
+	target, args, errMsg := m.moderationTarget(update, "اخطار", "اخطار بدهید", "اخطار [@نام یا شناسه] [دلیل]")
+	if target == nil {
+		return errMsg
+	}
+	chatID := update.Message.Chat.ID
+
+	reason := strings.Join(args, " ")
+	if len([]rune(reason)) > 200 {
+		reason = string([]rune(reason)[:200])
+	}
+
+	text, err := m.warn(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: update.Message.Chat.Title,
+		Actor:     update.Message.From,
+		Target:    target,
+		Reason:    reason,
+		Excerpt:   messageExcerpt(update.Message.ReplyToMessage),
+	})
+	if err != nil {
+		log.Printf("Error adding warning: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در ثبت اخطار")
+	}
+
+	_, _ = m.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: update.Message.MessageID})
+	return tgbotapi.NewMessage(chatID, text)
+}
+
+// WarnAutomatically اخطار خودکار برای تخلف از قفل‌ها (lock: link یا badword)؛ ادمین‌ها اخطار نمی‌گیرند
+func (m *ModerationCommand) WarnAutomatically(message *tgbotapi.Message, lock string, reason string) {
+	user := message.From
+	chatID := message.Chat.ID
+	if user == nil || user.IsBot {
+		return
+	}
+	if isAdmin, err := m.isUserAdmin(chatID, user.ID); err != nil || isAdmin {
+		return
+	}
+	text, err := m.warn(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: message.Chat.Title,
+		Target:    user,
+		Reason:    reason,
+		Excerpt:   messageExcerpt(message),
+		Source:    "lock:" + lock,
+	})
+	if err != nil {
+		log.Printf("Error adding automatic warning: %v", err)
+		return
+	}
+	if _, err := m.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("Error sending warning message: %v", err)
+	}
+}
+
+// IsLockWarnEnabled آیا تخلف از قفل‌ها در این گروه اخطار می‌گیرد؟
+func (m *ModerationCommand) IsLockWarnEnabled(chatID int64) bool {
+	enabled, err := m.storage.IsFeatureEnabled(chatID, featureLockWarn)
+	return err == nil && enabled
+}
+
+// warn ثبت اخطار، اعمال پلکان و ساخت متن اعلان. Actor = nil یعنی اخطار خودکار؛
+// اخطار و سکوت/بن ناشی از پلکان در گزارش مدیریت ثبت می‌شوند.
+func (m *ModerationCommand) warn(a ModerationAction) (string, error) {
+	chatID, target, reason := a.ChatID, a.Target, a.Reason
+	var adminID int64
+	if a.Actor != nil {
+		adminID = a.Actor.ID
+	}
+	ladder := loadWarnLadder(m.storage, chatID)
+	expiry := time.Duration(ladder.ExpiryDays) * 24 * time.Hour
+	if _, err := m.storage.AddWarning(chatID, target.ID, adminID, reason, expiry); err != nil {
+		return "", err
+	}
+	a.Action = modActionWarn
+	m.Record(a)
+	escalation := ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: a.ChatTitle,
+		Target:    target,
+		Reason:    "رسیدن به سقف اخطار",
+		Source:    modSourceWarnLadder,
+	}
+	count, err := m.storage.CountActiveWarnings(chatID, target.ID)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚠️ %s اخطار گرفت", warnUserName(target))
+	if ladder.BanAt > 0 {
+		fmt.Fprintf(&b, " (%d/%d)", count, ladder.BanAt)
+	} else {
+		fmt.Fprintf(&b, " (%d)", count)
+	}
+	if reason != "" {
+		b.WriteString("\nدلیل: " + reason)
+	}
+
+	switch {
+	case ladder.BanAt > 0 && count >= int64(ladder.BanAt):
+		if err := m.ban(chatID, target.ID, 0); err != nil {
+			log.Printf("banChatMember (warn) error: %v", err)
+			b.WriteString("\n❌ بن خودکار انجام نشد. مطمئن شوید ربات دسترسی بن دارد")
+		} else {
+			// پس از بن، شمارش از صفر شروع شود تا در صورت رفع بن دوباره بن نشود
+			if _, err := m.storage.DeleteWarnings(chatID, target.ID); err != nil {
+				log.Printf("Error clearing warnings after ban: %v", err)
+			}
+			escalation.Action = modActionBan
+			m.Record(escalation)
+			b.WriteString("\n⛔️ به دلیل رسیدن به سقف اخطار، کاربر بن شد")
+		}
+	case ladder.MuteAt > 0 && count >= int64(ladder.MuteAt):
+		until := time.Now().Add(time.Duration(ladder.MuteHours) * time.Hour)
+		if err := m.mute(chatID, target.ID, until.Unix()); err != nil {
+			log.Printf("restrictChatMember (warn) error: %v", err)
+			b.WriteString("\n❌ سکوت خودکار انجام نشد. مطمئن شوید ربات دسترسی مناسب دارد")
+		} else {
+			escalation.Action = modActionMute
+			escalation.Duration = time.Duration(ladder.MuteHours) * time.Hour
+			m.Record(escalation)
+			fmt.Fprintf(&b, "\n🔇 کاربر به مدت %d ساعت سکوت شد", ladder.MuteHours)
+		}
+	}
+	return b.String(), nil
+}
+
+// HandleWarnings «اخطارها [@نام یا شناسه]» یا روی ریپلای: فهرست اخطارهای فعال کاربر
+func (m *ModerationCommand) HandleWarnings(update tgbotapi.Update) tgbotapi.MessageConfig {
+	target, _, errMsg := m.moderationTarget(update, "اخطارها", "اخطارهایش را ببینید", "اخطارها [@نام یا شناسه]")
+	if target == nil {
+		return errMsg
+	}
+	chatID := update.Message.Chat.ID
+
+	warnings, err := m.storage.ActiveWarnings(chatID, target.ID)
+	if err != nil {
+		log.Printf("Error loading warnings: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در دریافت اخطارها")
+	}
+	if len(warnings) == 0 {
+		return tgbotapi.NewMessage(chatID, "✅ "+warnUserName(target)+" هیچ اخطار فعالی ندارد")
+	}
+
+	ladder := loadWarnLadder(m.storage, chatID)
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚠️ اخطارهای فعال %s: %d\n", warnUserName(target), len(warnings))
+	for i, w := range warnings {
+		reason := w.Reason
+		if reason == "" {
+			reason = "بدون دلیل"
+		}
+		source := ""
+		if w.AdminID == 0 {
+			source = " (خودکار)"
+		}
+		fmt.Fprintf(&b, "\n%d. %s%s — %s", i+1, reason, source, w.CreatedAt.Format("2006-01-02 15:04"))
+		if w.ExpiresAt != nil {
+			fmt.Fprintf(&b, " (تا %s)", w.ExpiresAt.Format("2006-01-02"))
+		}
+	}
+	b.WriteString("\n\n" + ladder.describe())
+	b.WriteString("\nبرای حذف: حذف اخطار (آخرین) یا حذف اخطار همه")
+	return tgbotapi.NewMessage(chatID, b.String())
+}
+
+// HandleRemoveWarning «حذف اخطار [@نام یا شناسه]» (آخرین اخطار) یا «حذف اخطار [@نام یا شناسه] همه»
+func (m *ModerationCommand) HandleRemoveWarning(update tgbotapi.Update) tgbotapi.MessageConfig {
+	target, args, errMsg := m.moderationTarget(update, "حذف اخطار", "اخطارش حذف شود", "حذف اخطار [@نام یا شناسه] [همه]")
+	if target == nil {
+		return errMsg
+	}
+	chatID := update.Message.Chat.ID
+
+	if len(args) > 0 && args[0] == "همه" {
+		removed, err := m.storage.DeleteWarnings(chatID, target.ID)
+		if err != nil {
+			log.Printf("Error deleting warnings: %v", err)
+			return tgbotapi.NewMessage(chatID, "❌ خطا در حذف اخطارها")
+		}
+		if removed == 0 {
+			return tgbotapi.NewMessage(chatID, "ℹ️ "+warnUserName(target)+" اخطاری ندارد")
+		}
+		m.Record(ModerationAction{
+			ChatID:    chatID,
+			ChatTitle: update.Message.Chat.Title,
+			Actor:     update.Message.From,
+			Target:    target,
+			Action:    modActionUnwarn,
+			Reason:    fmt.Sprintf("همه اخطارها (%d)", removed),
+		})
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ %d اخطار %s حذف شد", removed, warnUserName(target)))
+	}
+
+	removed, err := m.storage.DeleteLastWarning(chatID, target.ID)
+	if err != nil {
+		log.Printf("Error deleting warning: %v", err)
+		return tgbotapi.NewMessage(chatID, "❌ خطا در حذف اخطار")
+	}
+	if !removed {
+		return tgbotapi.NewMessage(chatID, "ℹ️ "+warnUserName(target)+" اخطاری ندارد")
+	}
+	m.Record(ModerationAction{
+		ChatID:    chatID,
+		ChatTitle: update.Message.Chat.Title,
+		Actor:     update.Message.From,
+		Target:    target,
+		Action:    modActionUnwarn,
+		Reason:    "آخرین اخطار",
+	})
+	count, _ := m.storage.CountActiveWarnings(chatID, target.ID)
+	return tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ آخرین اخطار %s حذف شد (اخطارهای فعال: %d)", warnUserName(target), count))
+}
+
+// describe توضیح پلکان برای نمایش به ادمین
+func (l WarnLadder) describe() string {
+	parts := make([]string, 0, 3)
+	if l.MuteAt > 0 {
+		parts = append(parts, fmt.Sprintf("%d اخطار ← سکوت %d ساعته", l.MuteAt, l.MuteHours))
+	}
+	if l.BanAt > 0 {
+		parts = append(parts, fmt.Sprintf("%d اخطار ← بن", l.BanAt))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "بدون برخورد خودکار")
+	}
+	expiry := "همیشگی"
+	if l.ExpiryDays > 0 {
+		expiry = fmt.Sprintf("%d روز", l.ExpiryDays)
+	}
+	return "📶 پلکان: " + strings.Join(parts, "، ") + "\n⏳ اعتبار هر اخطار: " + expiry
+}
+
+// BuildWarnMenu منوی تنظیم پلکان اخطار گروه
+func (m *ModerationCommand) BuildWarnMenu(chatID int64) tgbotapi.MessageConfig {
+	ladder := loadWarnLadder(m.storage, chatID)
+	offOr := func(n int, format string) string {
+		if n == 0 {
+			return "خاموش"
+		}
+		return fmt.Sprintf(format, n)
+	}
+	expiry := "همیشگی"
+	if ladder.ExpiryDays > 0 {
+		expiry = fmt.Sprintf("%d روز", ladder.ExpiryDays)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔇 سکوت با "+offOr(ladder.MuteAt, "%d اخطار"), "warn_cycle:"+settingWarnMuteAt),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏱ مدت سکوت: "+fmt.Sprintf("%d ساعت", ladder.MuteHours), "warn_cycle:"+settingWarnMuteHours),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⛔️ بن با "+offOr(ladder.BanAt, "%d اخطار"), "warn_cycle:"+settingWarnBanAt),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏳ اعتبار اخطار: "+expiry, "warn_cycle:"+settingWarnExpiryDays),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔒 اخطار برای تخلف از قفل‌ها "+boolIcon(m.IsLockWarnEnabled(chatID)), "warn_lock_toggle"),
+		),
+	)
+
+	text := "⚠️ تنظیمات اخطار\n\n" + ladder.describe() +
+		"\n\nبا ریپلای روی پیام کاربر:\n• اخطار [دلیل]\n• اخطارها — فهرست اخطارهای فعال\n• حذف اخطار [همه]" +
+		"\n\nبا هر کلیک روی دکمه‌ها مقدار بعدی انتخاب می‌شود."
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	return msg
+}
+
+// HandleWarnCallback دکمه‌های منوی اخطار (warn_*)؛ فقط ادمین‌های گروه
+func (m *ModerationCommand) HandleWarnCallback(update tgbotapi.Update) tgbotapi.CallbackConfig {
+	cq := update.CallbackQuery
+	chatID := cq.Message.Chat.ID
+	data := cq.Data
+
+	if data != "warn_menu" {
+		if isAdmin, err := m.isUserAdmin(chatID, cq.From.ID); err != nil || !isAdmin {
+			return tgbotapi.NewCallback(cq.ID, "❌ فقط ادمین‌های گروه می‌توانند تنظیمات را تغییر دهند")
+		}
+	}
+
+	switch {
+	case data == "warn_menu":
+		// فقط نمایش منو
+
+	case strings.HasPrefix(data, "warn_cycle:"):
+		key := strings.TrimPrefix(data, "warn_cycle:")
+		options, ok := warnLadderOptions[key]
+		if !ok {
+			return tgbotapi.NewCallback(cq.ID, "تنظیم نامعتبر")
+		}
+		if err := cycleGroupIntSetting(m.storage, chatID, key, options); err != nil {
+			log.Printf("Error saving warn setting: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در ذخیره تنظیمات")
+		}
+
+	case data == "warn_lock_toggle":
+		enabled := m.IsLockWarnEnabled(chatID)
+		if err := m.storage.SetFeatureEnabled(chatID, featureLockWarn, !enabled); err != nil {
+			log.Printf("Error toggling lock warn: %v", err)
+			return tgbotapi.NewCallback(cq.ID, "❌ خطا در تغییر وضعیت")
+		}
+	}
+
+	m.bot.Send(m.BuildWarnMenu(chatID))
+	return tgbotapi.NewCallback(cq.ID, "✅")
+}
+
+// warnUserName نام نمایشی کاربر در پیام‌های اخطار
+func warnUserName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		return strconv.FormatInt(user.ID, 10)
+	}
+	return name
+}
@@ -13,6 +13,36 @@ type Config struct {
 	DeepSeekToken     string
 	MaxRequestsPerDay int
 	CooldownSeconds   int
+	// AI client resilience
+	AITimeoutSeconds         int
+	AIMaxRetries             int
+	AIBreakerThreshold       int
+	AIBreakerCooldownSeconds int
+	// AI models and token budgets (0 = unlimited)
+	AIModel               string
+	AICheapModel          string
+	AIMaxTokens           int
+	AIReducedMaxTokens    int
+	AIBudgetSoftPercent   int
+	AIUserDailyTokens     int
+	AIUserMonthlyTokens   int
+	AIGroupDailyTokens    int
+	AIGroupMonthlyTokens  int
+	AIGlobalDailyTokens   int
+	AIGlobalMonthlyTokens int
+	// AI request queue
+	AIMaxConcurrent int
+	AIQueuePerUser  int
+	// Private AI sessions
+	AISessionMaxTokens int
+	AISessionHistory   int
+	// AI response cache (TTL 0 = no caching for that command)
+	AICacheVariants        int
+	AICacheJokeTTLMinutes  int
+	AICacheMusicTTLMinutes int
+	AIInlineCacheMinutes   int
+	// AI prompt templates (text/template files, overridable by bot admins)
+	AIPromptsDir string
 	// MySQL Config
 	MySQLHost     string
 	MySQLPort     string
@@ -34,6 +64,36 @@ func LoadConfig() {
 		// DeepSeekToken:     getEnv("DEEPSEEK_TOKEN", ""),
 		MaxRequestsPerDay: getEnvAsInt("MAX_REQUESTS_PER_DAY", 5),
 		CooldownSeconds:   getEnvAsInt("COOLDOWN_SECONDS", 10),
+		// AI client resilience
+		AITimeoutSeconds:         getEnvAsInt("AI_TIMEOUT_SECONDS", 60),
+		AIMaxRetries:             getEnvAsInt("AI_MAX_RETRIES", 3),
+		AIBreakerThreshold:       getEnvAsInt("AI_BREAKER_THRESHOLD", 5),
+		AIBreakerCooldownSeconds: getEnvAsInt("AI_BREAKER_COOLDOWN_SECONDS", 60),
+		// AI models and token budgets (0 = unlimited)
+		AIModel:               getEnv("AI_MODEL", "deepseek/deepseek-r1-0528:free"),
+		AICheapModel:          getEnv("AI_CHEAP_MODEL", "deepseek/deepseek-chat-v3-0324:free"),
+		AIMaxTokens:           getEnvAsInt("AI_MAX_TOKENS", 0),
+		AIReducedMaxTokens:    getEnvAsInt("AI_REDUCED_MAX_TOKENS", 400),
+		AIBudgetSoftPercent:   getEnvAsInt("AI_BUDGET_SOFT_PERCENT", 80),
+		AIUserDailyTokens:     getEnvAsInt("AI_USER_DAILY_TOKENS", 20000),
+		AIUserMonthlyTokens:   getEnvAsInt("AI_USER_MONTHLY_TOKENS", 300000),
+		AIGroupDailyTokens:    getEnvAsInt("AI_GROUP_DAILY_TOKENS", 100000),
+		AIGroupMonthlyTokens:  getEnvAsInt("AI_GROUP_MONTHLY_TOKENS", 2000000),
+		AIGlobalDailyTokens:   getEnvAsInt("AI_GLOBAL_DAILY_TOKENS", 0),
+		AIGlobalMonthlyTokens: getEnvAsInt("AI_GLOBAL_MONTHLY_TOKENS", 0),
+		// AI request queue
+		AIMaxConcurrent: getEnvAsInt("AI_MAX_CONCURRENT", 3),
+		AIQueuePerUser:  getEnvAsInt("AI_QUEUE_PER_USER", 2),
+		// Private AI sessions
+		AISessionMaxTokens: getEnvAsInt("AI_SESSION_MAX_TOKENS", 30000),
+		AISessionHistory:   getEnvAsInt("AI_SESSION_HISTORY", 20),
+		// AI response cache (TTL 0 = no caching for that command)
+		AICacheVariants:        getEnvAsInt("AI_CACHE_VARIANTS", 3),
+		AICacheJokeTTLMinutes:  getEnvAsInt("AI_CACHE_JOKE_TTL_MINUTES", 1440),
+		AICacheMusicTTLMinutes: getEnvAsInt("AI_CACHE_MUSIC_TTL_MINUTES", 360),
+		AIInlineCacheMinutes:   getEnvAsInt("AI_INLINE_CACHE_MINUTES", 60),
+		// AI prompt templates
+		AIPromptsDir: getEnv("AI_PROMPTS_DIR", "prompts"),
 		// MySQL Config
 		MySQLHost:     getEnv("MYSQL_HOST", "localhost"),
 		MySQLPort:     getEnv("MYSQL_PORT", "3306"),
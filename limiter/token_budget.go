@@ -0,0 +1,93 @@
+package limiter
+
+import (
+	"log"
+	"redhat-bot/config"
+	"redhat-bot/storage"
+	"time"
+)
+
+// BudgetPlan نتیجه بررسی بودجه توکن برای یک درخواست
+type BudgetPlan struct {
+	Allowed   bool
+	Degraded  bool   // نزدیک به سقف: مدل ارزان‌تر و پاسخ کوتاه‌تر
+	Model     string // مدل جایگزین در حالت Degraded
+	MaxTokens int    // سقف توکن خروجی در حالت Degraded
+	Message   string // پیام کاربر وقتی Allowed=false
+}
+
+// TokenBudget بودجه روزانه و ماهانه توکن در سطح کاربر، گروه و کل ربات
+type TokenBudget struct {
+	storage *storage.MySQLStorage
+}
+
+func NewTokenBudget(storage *storage.MySQLStorage) *TokenBudget {
+	return &TokenBudget{
+		storage: storage,
+	}
+}
+
+type budgetCheck struct {
+	limit   int
+	used    func() (int64, error)
+	message string
+}
+
+// Plan بررسی مصرف فعلی و تصمیم‌گیری درباره اجرای درخواست (groupID=0 برای چت خصوصی)
+func (t *TokenBudget) Plan(userID int64, groupID int64) BudgetPlan {
+	cfg := config.AppConfig
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	checks := []budgetCheck{
+		{cfg.AIGlobalDailyTokens, func() (int64, error) { return t.storage.SumGlobalAITokens(dayStart) },
+			"⚠️ سهمیه روزانه هوش مصنوعی ربات تمام شده است. لطفاً فردا دوباره تلاش کنید."},
+		{cfg.AIGlobalMonthlyTokens, func() (int64, error) { return t.storage.SumGlobalAITokens(monthStart) },
+			"⚠️ سهمیه ماهانه هوش مصنوعی ربات تمام شده است."},
+		{cfg.AIUserDailyTokens, func() (int64, error) { return t.storage.SumUserAITokens(userID, dayStart) },
+			"⚠️ سهمیه روزانه هوش مصنوعی شما تمام شده است. لطفاً فردا دوباره تلاش کنید."},
+		{cfg.AIUserMonthlyTokens, func() (int64, error) { return t.storage.SumUserAITokens(userID, monthStart) },
+			"⚠️ سهمیه ماهانه هوش مصنوعی شما تمام شده است."},
+	}
+	if groupID != 0 {
+		checks = append(checks,
+			budgetCheck{cfg.AIGroupDailyTokens, func() (int64, error) { return t.storage.SumGroupAITokens(groupID, dayStart) },
+				"⚠️ سهمیه روزانه هوش مصنوعی این گروه تمام شده است. لطفاً فردا دوباره تلاش کنید."},
+			budgetCheck{cfg.AIGroupMonthlyTokens, func() (int64, error) { return t.storage.SumGroupAITokens(groupID, monthStart) },
+				"⚠️ سهمیه ماهانه هوش مصنوعی این گروه تمام شده است."},
+		)
+	}
+
+	plan := BudgetPlan{Allowed: true}
+	for _, c := range checks {
+		if c.limit <= 0 {
+			continue
+		}
+		used, err := c.used()
+		if err != nil {
+			// در صورت خطا، اجازه درخواست بده
+			log.Printf("Error reading token usage: %v", err)
+			continue
+		}
+		if used >= int64(c.limit) {
+			return BudgetPlan{Allowed: false, Message: c.message}
+		}
+		if used*100 >= int64(c.limit)*int64(cfg.AIBudgetSoftPercent) {
+			plan.Degraded = true
+		}
+	}
+
+	if plan.Degraded {
+		plan.Model = cfg.AICheapModel
+		plan.MaxTokens = cfg.AIReducedMaxTokens
+	}
+	return plan
+}
+
+// Record ثبت مصرف توکن یک فراخوانی همراه با نسخه قالب پرامپت
+func (t *TokenBudget) Record(userID int64, groupID int64, command string, model string, promptTokens int, completionTokens int, promptVersion string) {
+	if err := t.storage.SaveAIUsage(userID, groupID, command, model, promptTokens, completionTokens, promptVersion); err != nil {
+		log.Printf("Error saving ai usage: %v", err)
+	}
+}
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"redhat-bot/ai"
 	"redhat-bot/commands"
@@ -14,7 +12,6 @@ import (
 	// "redhat-bot/scheduler"
 	"redhat-bot/storage"
 	"strings"
-	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -26,6 +23,7 @@ type CovoBot struct {
 	storage           *storage.MySQLStorage
 	rateLimiter       *limiter.RateLimiter
 	aiClient          *ai.DeepSeekClient
+	aiService         *commands.AIService
 	covoCommand       *commands.CovoCommand
 	covoJokeCommand   *commands.CovoJokeCommand
 	musicCommand      *commands.MusicCommand
@@ -36,68 +34,24 @@ type CovoBot struct {
 	hafezCommand      *commands.HafezCommand
 	adminCommand      *commands.AdminCommand
 	moderationCommand *commands.ModerationCommand
+	floodGuard        *commands.FloodGuard
+	badWords          *commands.BadWordFilter
+	linkFilter        *commands.LinkFilter
+	captchaCommand    *commands.CaptchaCommand
+	mediaLocks        *commands.MediaLocks
+	nightMode         *commands.NightMode
 	truthDareCommand  *commands.TruthDareCommand
 	tagCommand        *commands.TagCommand
+	personaCommand    *commands.PersonaCommand
+	conversations     *commands.ConversationManager
+	sessionCommand    *commands.SessionCommand
+	translateCommand  *commands.TranslateCommand
+	inlineCommand     *commands.InlineCommand
 	dailyChallenge    *commands.DailyChallengeCommand
 	// summaryScheduler *scheduler.DailySummaryScheduler
 	cron *cron.Cron
 }
 
-// bad words cache
-var loadBadWordsOnce sync.Once
-var cachedBadWords []string
-var cachedFinglishWords []string
-
-type badWordsFile struct {
-	FarsiWords    []string `json:"farsiWords"`
-	FinglishWords []string `json:"finglishWords"`
-}
-
-func loadBadWords() {
-	loadBadWordsOnce.Do(func() {
-		filePath := filepath.Join("jsonfile", "badwords.json")
-		f, err := os.Open(filePath)
-		if err != nil {
-			log.Printf("cannot open badwords file: %v", err)
-			return
-		}
-		defer f.Close()
-
-		var bw badWordsFile
-		if err := json.NewDecoder(f).Decode(&bw); err != nil {
-			log.Printf("cannot decode badwords file: %v", err)
-			return
-		}
-		cachedBadWords = bw.FarsiWords
-		cachedFinglishWords = bw.FinglishWords
-	})
-}
-
-func containsBadWord(text string) bool {
-	if text == "" {
-		return false
-	}
-	loadBadWords()
-	t := strings.ToLower(text)
-	for _, w := range cachedBadWords {
-		if w == "" {
-			continue
-		}
-		if strings.Contains(t, strings.ToLower(w)) {
-			return true
-		}
-	}
-	for _, w := range cachedFinglishWords {
-		if w == "" {
-			continue
-		}
-		if strings.Contains(t, strings.ToLower(w)) {
-			return true
-		}
-	}
-	return false
-}
-
 func NewCovoBot() (*CovoBot, error) {
 	// بارگذاری تنظیمات
 	config.LoadConfig()
@@ -123,20 +77,31 @@ func NewCovoBot() (*CovoBot, error) {
 	// راه‌اندازی اجزا
 	rateLimiter := limiter.NewRateLimiter(storage)
 	aiClient := ai.NewDeepSeekClient()
+	tokenBudget := limiter.NewTokenBudget(storage)
+	aiQueue := ai.NewQueue(config.AppConfig.AIMaxConcurrent, config.AppConfig.AIQueuePerUser)
+	aiCache := ai.NewCache(config.AppConfig.AICacheVariants)
+	prompts, err := commands.NewPromptLibrary(storage, config.AppConfig.AIPromptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error loading prompt templates: %v", err)
+	}
+	aiService := commands.NewAIService(aiClient, storage, tokenBudget, rateLimiter, aiQueue, aiCache, prompts, bot)
 
 	// راه‌اندازی دستورات
-	covoCommand := commands.NewCovoCommand(aiClient, rateLimiter, bot)
-	covoJokeCommand := commands.NewCovoJokeCommand(aiClient, rateLimiter, bot)
-	musicCommand := commands.NewMusicCommand(aiClient, rateLimiter, bot)
+	conversations := commands.NewConversationManager(storage)
+	covoCommand := commands.NewCovoCommand(aiService, rateLimiter, bot)
+	covoJokeCommand := commands.NewCovoJokeCommand(aiService, rateLimiter, bot)
+	musicCommand := commands.NewMusicCommand(aiService, storage, rateLimiter, bot, conversations)
 	crsCommand := commands.NewCrsCommand(rateLimiter)
 	clownCommand := commands.NewClownCommand(storage, rateLimiter, bot)
 	crushCommand := commands.NewCrushCommand(storage, bot)
-	hafezCommand := commands.NewHafezCommand(bot)
-	adminCommand := commands.NewAdminCommand(bot, storage)
+	hafezCommand := commands.NewHafezCommand(bot, aiService, rateLimiter, conversations)
+	adminCommand := commands.NewAdminCommand(bot, storage, aiService, conversations)
 	gapCommand := commands.NewGapCommand(bot, storage, hafezCommand)
-	moderationCommand := commands.NewModerationCommand(bot)
+	moderationCommand := commands.NewModerationCommand(bot, storage)
 	truthDareCommand := commands.NewTruthDareCommand(bot, adminCommand)
 	tagCommand := commands.NewTagCommand(bot, storage)
+	personaCommand := commands.NewPersonaCommand(bot, storage)
+	sessionCommand := commands.NewSessionCommand(aiService, storage, rateLimiter, bot)
 
 	// راه‌اندازی زمان‌بند
 	// summaryScheduler := scheduler.NewDailySummaryScheduler(bot, storage, aiClient)
@@ -154,6 +119,7 @@ func NewCovoBot() (*CovoBot, error) {
 		storage:           storage,
 		rateLimiter:       rateLimiter,
 		aiClient:          aiClient,
+		aiService:         aiService,
 		covoCommand:       covoCommand,
 		covoJokeCommand:   covoJokeCommand,
 		musicCommand:      musicCommand,
@@ -164,9 +130,20 @@ func NewCovoBot() (*CovoBot, error) {
 		hafezCommand:      hafezCommand,
 		adminCommand:      adminCommand,
 		moderationCommand: moderationCommand,
+		floodGuard:        commands.NewFloodGuard(bot, storage, moderationCommand),
+		linkFilter:        commands.NewLinkFilter(bot, storage, moderationCommand),
+		captchaCommand:    commands.NewCaptchaCommand(bot, storage, moderationCommand),
+		mediaLocks:        commands.NewMediaLocks(bot, storage, moderationCommand),
+		nightMode:         commands.NewNightMode(bot, storage, moderationCommand),
+		badWords:          commands.NewBadWordFilter(storage, moderationCommand, filepath.Join("jsonfile", "badwords.json")),
 		truthDareCommand:  truthDareCommand,
 		tagCommand:        tagCommand,
-		dailyChallenge:    commands.NewDailyChallengeCommand(storage, bot),
+		personaCommand:    personaCommand,
+		conversations:     conversations,
+		sessionCommand:    sessionCommand,
+		translateCommand:  commands.NewTranslateCommand(aiService, rateLimiter, storage, bot),
+		inlineCommand:     commands.NewInlineCommand(aiService, hafezCommand, rateLimiter, storage, bot),
+		dailyChallenge:    commands.NewDailyChallengeCommand(storage, bot, aiService, rateLimiter),
 		// summaryScheduler: summaryScheduler,
 		cron: cronJob,
 	}, nil
@@ -191,6 +168,27 @@ func (r *CovoBot) Start() error {
 		return err
 	}
 
+	// پاکسازی گفتگوهای چندمرحله‌ای منقضی‌شده
+	if _, err := r.cron.AddFunc("@hourly", func() {
+		r.conversations.CleanupExpired()
+	}); err != nil {
+		return err
+	}
+
+	// اخراج اعضایی که مهلت کپچای ورودشان تمام شده است (کپچاها در دیتابیس می‌مانند، پس پس از ری‌استارت هم بررسی می‌شوند)
+	if _, err := r.cron.AddFunc("@every 30s", func() {
+		r.captchaCommand.ExpireDue()
+	}); err != nil {
+		return err
+	}
+
+	// حالت شب: قفل و بازکردن گروه‌ها طبق برنامه هر گروه (با منطقه زمانی همان گروه)
+	if _, err := r.cron.AddFunc("@every 1m", func() {
+		r.nightMode.Tick()
+	}); err != nil {
+		return err
+	}
+
 	r.cron.Start()
 	log.Println("⏰ زمان‌بندها راه‌اندازی شد (خلاصه ۹:۰۰، چلنج ~۱۰:۳۰ تهران)")
 
@@ -218,13 +216,22 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 		r.handleMyChatMember(update)
 		return
 	}
+	// حالت inline (@bot ...) و ثبت نتیجه انتخاب‌شده
+	if update.InlineQuery != nil {
+		r.inlineCommand.HandleQuery(update)
+		return
+	}
+	if update.ChosenInlineResult != nil {
+		r.inlineCommand.HandleChosen(update)
+		return
+	}
 	// Handle callback queries from inline keyboard
 	if update.CallbackQuery != nil {
 		var callback tgbotapi.CallbackConfig
 
 		// بررسی نوع callback
-		// گیت عضویت برای تمام کال‌بک‌ها به جز موارد ادمین و بررسی عضویت
-		if !(strings.HasPrefix(update.CallbackQuery.Data, "admin_") || strings.HasPrefix(update.CallbackQuery.Data, "admin_check_join")) {
+		// گیت عضویت برای تمام کال‌بک‌ها به جز موارد ادمین، بررسی عضویت و پاسخ کپچای ورود
+		if !(strings.HasPrefix(update.CallbackQuery.Data, "admin_") || strings.HasPrefix(update.CallbackQuery.Data, "admin_check_join") || strings.HasPrefix(update.CallbackQuery.Data, "captcha_ans:")) {
 			if ok, prompt := r.checkRequiredMembershipAndPromptUser(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.From.ID); !ok {
 				if prompt.ChatID != 0 {
 					_, _ = r.bot.Send(prompt)
@@ -259,6 +266,26 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 			}
 		case strings.HasPrefix(update.CallbackQuery.Data, "admin_"):
 			callback = r.adminCommand.HandleCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "ai_cancel:"):
+			callback = r.aiService.HandleCancelCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "fb_"):
+			callback = r.aiService.HandleFeedbackCallback(update)
+		case update.CallbackQuery.Data == "conv_cancel":
+			callback = r.conversations.HandleCancelCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "persona_"):
+			callback = r.personaCommand.HandleCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "warn_"):
+			callback = r.moderationCommand.HandleWarnCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "flood_"):
+			callback = r.floodGuard.HandleCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "link_"):
+			callback = r.linkFilter.HandleCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "captcha_"):
+			callback = r.captchaCommand.HandleCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "media_"):
+			callback = r.mediaLocks.HandleCallback(update)
+		case strings.HasPrefix(update.CallbackQuery.Data, "session_"):
+			callback = r.sessionCommand.HandleCallback(update)
 		case strings.HasPrefix(update.CallbackQuery.Data, "td_"):
 			// گیت عضویت برای کلیک‌های بازی
 			if ok, prompt := r.checkRequiredMembershipAndPromptUser(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.From.ID); !ok {
@@ -338,6 +365,11 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 				return
 			}
 		}
+
+		// کپچای ورود برای اعضای جدید (در صورت فعال بودن)
+		if r.captchaCommand.HandleJoin(message) {
+			return
+		}
 	}
 
 	// بررسی خروج بات از گروه
@@ -351,12 +383,22 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 		// افزودن گروه به زمان‌بند اگر قبلاً اضافه نشده
 		// r.summaryScheduler.AddGroup(message.Chat.ID, message.Chat.Title)
 
+		// ضد اسپم: پیام‌های پشت‌سرهم یا تکراری پیش از ثبت و پردازش حذف می‌شوند
+		if r.floodGuard.Check(message) {
+			return
+		}
+
+		// قفل رسانه: استیکر، گیف، عکس، فوروارد، ارسال با نام کانال و ...
+		if r.mediaLocks.Check(message) {
+			return
+		}
+
 		// ثبت پیام برای خلاصه روزانه
 		username := message.From.UserName
 		if username == "" {
 			username = message.From.FirstName
 		}
-		if err := r.storage.AddGroupMessage(message.Chat.ID, message.From.ID, username, text); err != nil {
+		if err := r.storage.AddGroupMessage(message.Chat.ID, message.From.ID, message.MessageID, username, commands.MessageContentType(message), text); err != nil {
 			log.Printf("Error adding group message: %v", err)
 		}
 
@@ -368,26 +410,35 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 		if err := r.storage.AddGroupMember(message.Chat.ID, message.From.ID, userName); err != nil {
 			log.Printf("Error adding group member: %v", err)
 		}
-		// اگر قفل لینک فعال است، پیام‌های حاوی لینک حذف شوند
+		// اگر قفل لینک فعال است، پیام‌های حاوی لینک حذف شوند (و در صورت فعال بودن، اخطار بگیرند)
 		if enabled, err := r.storage.IsFeatureEnabled(message.Chat.ID, "link"); err == nil && enabled {
-			if containsLink(text) {
+			// «لینک مجاز <دامنه>» ادمین به دستورات پایین‌تر می‌رسد
+			if reason, found := r.linkFilter.Violation(message); found && !r.linkFilter.IsAdminCommand(message) {
 				_, _ = r.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: message.Chat.ID, MessageID: message.MessageID})
+				r.moderationCommand.DeletedByLock(message, "link", reason)
+				if r.moderationCommand.IsLockWarnEnabled(message.Chat.ID) {
+					r.moderationCommand.WarnAutomatically(message, "link", reason)
+				}
 				return
 			}
 		}
 
-		// اگر قفل فحش فعال است، پیام‌های حاوی کلمات بد حذف شوند
+		// اگر قفل فحش فعال است، پیام‌های حاوی کلمات بد حذف شوند (و در صورت فعال بودن، اخطار بگیرند)
 		if enabled, err := r.storage.IsFeatureEnabled(message.Chat.ID, "badword"); err == nil && enabled {
-			if containsBadWord(text) {
+			// «افزودن فحش»/«حذف فحش» ادمین به دستورات پایین‌تر می‌رسد
+			if r.badWords.Contains(message.Chat.ID, text) && !r.badWords.IsAdminCommand(message) {
 				_, _ = r.bot.Request(tgbotapi.DeleteMessageConfig{ChatID: message.Chat.ID, MessageID: message.MessageID})
+				r.moderationCommand.DeletedByLock(message, "badword", "فحش")
+				if r.moderationCommand.IsLockWarnEnabled(message.Chat.ID) {
+					r.moderationCommand.WarnAutomatically(message, "badword", "فحش")
+				}
 				return
 			}
 		}
 	}
 
-	// اگر پیام خصوصی از ادمین و در حالت افزودن لینک بود، قبل از هرچیز آن را هندل کن
-	if message.Chat.Type == "private" && r.adminCommand.IsAdmin(message.From.ID) && r.adminCommand.HasPendingAdd(message.From.ID) {
-		resp := r.adminCommand.HandlePrivateTextInput(update)
+	// اگر کاربر وسط یک گفتگوی چندمرحله‌ای (موسیقی، افزودن لینک و ...) است، قبل از هرچیز آن را هندل کن
+	if resp, ok := r.conversations.Handle(update); ok {
 		if resp.ChatID != 0 {
 			_, _ = r.bot.Send(resp)
 		}
@@ -406,7 +457,9 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 	if !strings.HasPrefix(text, "/") {
 		// اگر یکی از تریگرهای اکشن بود، ابتدا گیت عضویت را بررسی کن
 		trimmed := strings.TrimSpace(text)
-		if trimmed == "پنل" || trimmed == "بازی" || trimmed == "توقف بازی" || trimmed == "کراش" || trimmed == "فال" || trimmed == "تگ" || strings.HasPrefix(trimmed, "دلقک") || strings.HasPrefix(trimmed, "سکوت") || trimmed == "ازاد" || strings.HasPrefix(trimmed, "حذف") {
+		textTool, isTextTool := r.translateCommand.Detect(trimmed)
+		isHafezIntent := r.hafezCommand.IsIntentTrigger(trimmed)
+		if isTextTool || isHafezIntent || trimmed == "پنل" || trimmed == "بازی" || trimmed == "توقف بازی" || trimmed == "کراش" || trimmed == "فال" || trimmed == "تگ" || commands.CommandMatches(trimmed, "پرامپت") || r.personaCommand.IsTriggerNameCommand(trimmed) || strings.HasPrefix(trimmed, "دلقک") || r.moderationCommand.IsTargetCommand(message) || strings.HasPrefix(trimmed, "حذف") || strings.HasPrefix(trimmed, "اخطار") || strings.HasPrefix(trimmed, "افزودن فحش") || trimmed == "لیست فحش" || r.linkFilter.IsCommand(trimmed) || strings.HasPrefix(trimmed, "کانال گزارش") || strings.HasPrefix(trimmed, "گزارش مدیریت") || strings.HasPrefix(trimmed, "حالت شب") || strings.HasPrefix(trimmed, "پاکسازی") {
 			if ok, prompt := r.checkRequiredMembershipAndPromptUser(message.Chat.ID, message.From.ID); !ok {
 				if prompt.ChatID != 0 {
 					_, _ = r.bot.Send(prompt)
@@ -464,9 +517,36 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 			}
 			return
 		}
-		// پشتیبانی از «بن» روی ریپلای بدون اسلش
-		if strings.TrimSpace(text) == "بن" {
-			response := r.moderationCommand.HandleBanOnReply(update)
+		// «ترجمه [زبان]»، «فارسی کن» و «رسمی کن» روی ریپلای
+		if isTextTool {
+			response := r.translateCommand.Handle(update, textTool)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام ابزار متن: %v", err)
+				}
+			}
+			return
+		}
+		// «فال با نیت» (در صورت فعال بودن قابلیت)
+		if isHafezIntent {
+			if enabled, err := r.storage.IsFeatureEnabled(message.Chat.ID, "hafez"); message.Chat.Type == "private" || (err == nil && enabled) {
+				response := r.hafezCommand.HandleIntent(update)
+				if response.ChatID != 0 {
+					_, err := r.bot.Send(response)
+					if err != nil {
+						log.Printf("خطا در ارسال پیام فال: %v", err)
+					}
+				}
+			} else {
+				notice := tgbotapi.NewMessage(message.Chat.ID, "❌ قابلیت فال در این گروه غیرفعال است")
+				_, _ = r.bot.Send(notice)
+			}
+			return
+		}
+		// «بن»، «رفع بن»، «سکوت [مدت]» و «آزاد» روی ریپلای یا با @نام، شناسه یا منشن کاربر
+		if r.moderationCommand.IsTargetCommand(message) {
+			response := r.moderationCommand.HandleTargetCommand(update)
 			if response.ChatID != 0 {
 				_, err := r.bot.Send(response)
 				if err != nil {
@@ -476,9 +556,9 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 			return
 		}
 
-		// پشتیبانی از «سکوت [n]» روی ریپلای بدون اسلش (n = ساعت)
-		if strings.HasPrefix(strings.TrimSpace(text), "سکوت") {
-			response := r.moderationCommand.HandleMute(update)
+		// «کانال گزارش [@کانال | شناسه | حذف]» -> اتصال کانال/گروه گزارش اقدامات مدیریتی
+		if strings.HasPrefix(strings.TrimSpace(text), "کانال گزارش") {
+			response := r.moderationCommand.HandleLogChannel(update)
 			if response.ChatID != 0 {
 				_, err := r.bot.Send(response)
 				if err != nil {
@@ -488,9 +568,105 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 			return
 		}
 
-		// پشتیبانی از «آزاد» روی ریپلای بدون اسلش
-		if strings.TrimSpace(text) == "ازاد" {
-			response := r.moderationCommand.HandleUnmute(update)
+		// «حالت شب [روشن | خاموش | شروع پایان [منطقه زمانی]]» -> قفل خودکار گروه در ساعات شب
+		if strings.HasPrefix(strings.TrimSpace(text), "حالت شب") {
+			response := r.nightMode.HandleCommand(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «گزارش مدیریت [@کاربر | شناسه | نوع]» -> آخرین اقدامات مدیریتی گروه
+		if strings.HasPrefix(strings.TrimSpace(text), "گزارش مدیریت") {
+			response := r.moderationCommand.HandleAuditReport(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «اخطارها [@نام یا شناسه]» یا روی ریپلای -> فهرست اخطارهای فعال کاربر
+		if t := strings.TrimSpace(text); t == "اخطارها" || strings.HasPrefix(t, "اخطارها ") {
+			response := r.moderationCommand.HandleWarnings(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «اخطار [@نام یا شناسه] [دلیل]» یا روی ریپلای -> ثبت اخطار و اعمال پلکان سکوت/بن
+		if t := strings.TrimSpace(text); t == "اخطار" || strings.HasPrefix(t, "اخطار ") {
+			response := r.moderationCommand.HandleWarn(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «لینک مجاز»، «حذف لینک مجاز» و «لیست لینک مجاز» (پیش از «حذف [n]»)
+		if r.linkFilter.IsCommand(text) {
+			response := r.linkFilter.HandleCommand(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «افزودن فحش»، «حذف فحش» و «لیست فحش» (پیش از «حذف [n]»)
+		if r.badWords.IsCommand(text) {
+			response := r.badWords.HandleCommand(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «پاکسازی [@نام یا شناسه] [تعداد | مدت]» یا روی ریپلای -> حذف آخرین پیام‌های یک کاربر
+		if t := strings.TrimSpace(text); t == "پاکسازی" || strings.HasPrefix(t, "پاکسازی ") {
+			response := r.moderationCommand.HandlePurge(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «حذف از» روی ریپلای -> حذف همه پیام‌ها از پیام ریپلای‌شده تا اکنون (پیش از «حذف [n]»)
+		if strings.TrimSpace(text) == "حذف از" {
+			response := r.moderationCommand.HandleDeleteRange(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «حذف اخطار [همه]» باید پیش از «حذف [n]» بررسی شود
+		if strings.HasPrefix(strings.TrimSpace(text), "حذف اخطار") {
+			response := r.moderationCommand.HandleRemoveWarning(update)
 			if response.ChatID != 0 {
 				_, err := r.bot.Send(response)
 				if err != nil {
@@ -531,6 +707,30 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 			return
 		}
 
+		// «پرامپت <متن>» بدون اسلش -> تنظیم پرامپت سفارشی هوش مصنوعی (فقط ادمین)
+		if commands.CommandMatches(strings.TrimSpace(text), "پرامپت") {
+			response := r.personaCommand.HandlePromptText(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
+		// «نام ربات <نام>» بدون اسلش -> تغییر نام صدا زدن ربات (فقط ادمین)
+		if r.personaCommand.IsTriggerNameCommand(text) {
+			response := r.personaCommand.HandleTriggerNameText(update)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پیام: %v", err)
+				}
+			}
+			return
+		}
+
 		// «تگ» بدون اسلش روی ریپلای -> تگ همه اعضا (فقط ادمین)
 		if strings.TrimSpace(text) == "تگ" {
 			response := r.tagCommand.HandleTagAllOnReply(update)
@@ -567,19 +767,39 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 			return
 		}
 
-		// بررسی ریپلای به دستور موسیقی
-		if message.ReplyToMessage != nil && message.ReplyToMessage.Text != "" {
-			replyText := message.ReplyToMessage.Text
-			if strings.Contains(replyText, "پیشنهاد موسیقی") || strings.Contains(replyText, "چه نوع آهنگی") {
-				response := r.musicCommand.Handle(update)
-				if response.ChatID != 0 {
-					_, err := r.bot.Send(response)
-					if err != nil {
-						log.Printf("خطا در ارسال پاسخ موسیقی: %v", err)
-					}
+		// گفتگو با ربات بدون اسلش: منشن، شروع با نام ربات یا ریپلای به پاسخ هوش مصنوعی
+		if question, history, ok := r.covoCommand.DetectConversation(update); ok {
+			if ok, prompt := r.checkRequiredMembershipAndPromptUser(message.Chat.ID, message.From.ID); !ok {
+				if prompt.ChatID != 0 {
+					_, _ = r.bot.Send(prompt)
 				}
 				return
 			}
+			response := r.covoCommand.HandleConversation(update, question, history)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پاسخ گفتگو: %v", err)
+				}
+			}
+			return
+		}
+
+		// جلسه گفتگوی خصوصی فعال: هر پیام متنی به هوش مصنوعی می‌رود
+		if session := r.sessionCommand.ActiveSession(message); session != nil {
+			if ok, prompt := r.checkRequiredMembershipAndPromptUser(message.Chat.ID, message.From.ID); !ok {
+				if prompt.ChatID != 0 {
+					_, _ = r.bot.Send(prompt)
+				}
+				return
+			}
+			response := r.sessionCommand.HandleMessage(update, session)
+			if response.ChatID != 0 {
+				_, err := r.bot.Send(response)
+				if err != nil {
+					log.Printf("خطا در ارسال پاسخ جلسه: %v", err)
+				}
+			}
 		}
 		return
 	}
@@ -636,6 +856,24 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 		response = r.adminCommand.HandleShowUsers(update)
 	case strings.HasPrefix(text, "/showgroups"):
 		response = r.adminCommand.HandleShowGroups(update)
+	case message.Command() == "new":
+		response = r.sessionCommand.HandleNew(update)
+	case strings.HasPrefix(text, "/prompt"), strings.HasPrefix(text, "/setprompt"), strings.HasPrefix(text, "/resetprompt"):
+		response = r.adminCommand.HandlePrompts(update)
+	case message.Command() == "reset":
+		response = r.sessionCommand.HandleReset(update)
+	case message.Command() == "history":
+		response = r.sessionCommand.HandleHistory(update)
+	case strings.HasPrefix(text, "/tr"):
+		textTool, ok := r.translateCommand.Detect(text)
+		if !ok {
+			return
+		}
+		response = r.translateCommand.Handle(update, textTool)
+	case strings.HasPrefix(text, "/aiusage"):
+		response = r.adminCommand.HandleAIUsage(update)
+	case strings.HasPrefix(text, "/aifeedback"):
+		response = r.adminCommand.HandleAIFeedback(update)
 	case strings.HasPrefix(text, "/del"):
 		response = r.moderationCommand.Handle(update)
 	default:
@@ -651,22 +889,6 @@ func (r *CovoBot) handleUpdate(update tgbotapi.Update) {
 	}
 }
 
-// containsLink بررسی وجود لینک در متن پیام
-func containsLink(text string) bool {
-	t := strings.ToLower(text)
-	if strings.Contains(t, "http://") || strings.Contains(t, "https://") {
-		return true
-	}
-	if strings.Contains(t, "t.me/") || strings.Contains(t, "telegram.me/") {
-		return true
-	}
-	// تشخیص ساده دامنه‌ها مانند example.com
-	if strings.Contains(t, ".com") || strings.Contains(t, ".ir") || strings.Contains(t, ".org") || strings.Contains(t, ".net") {
-		return true
-	}
-	return false
-}
-
 // handleMyChatMember ثبت/آپدیت اطلاعات چت/کانالی که وضعیت ربات در آن تغییر کرده است
 func (r *CovoBot) handleMyChatMember(update tgbotapi.Update) {
 	m := update.MyChatMember
@@ -876,14 +1098,26 @@ func (r *CovoBot) handleHelpCommand(update tgbotapi.Update) tgbotapi.MessageConf
 🤖 *دستورات دستیار هوشمند:*
 • /covo <سوال> - هر سوالی دارید بپرسید! من پاسخ مفید می‌دهم
 • /cj <موضوع> - جوک خنده‌دار و تمیز درباره هر موضوعی تولید کن
-• /music - پیشنهاد موسیقی بر اساس سلیقه شما (با ریپلای)
+• /music - پیشنهاد موسیقی بر اساس سلیقه شما
 • دلقک <نام> - توهین به شخص مورد نظر
 • /crushon - فعال‌سازی قابلیت کراش
 • /فال - دریافت فال حافظ با تفسیر
+• فال با نیت <نیت> - تفسیر شخصی فال برای نیت شما (یا با ریپلای روی نیت)
 • /crs - بررسی وضعیت بات
 • /gap - نمایش دستورات مخصوص گروه
 • /covog - نمایش راهنما (در گروه‌ها)
 
+💬 *جلسه گفتگو (چت خصوصی):*
+• /new - شروع جلسه جدید؛ پیام‌های بعدی با حافظه گفتگو جواب داده می‌شوند
+• /reset - پایان جلسه فعلی
+• /history - فهرست جلسه‌های قبلی و ادامه آن‌ها
+
+🌐 *ابزارهای متن (با ریپلای روی پیام):*
+• «ترجمه [زبان]» یا /tr [زبان] - ترجمه پیام (بدون زبان: فارسی↔انگلیسی)
+• «فارسی کن» - تبدیل فینگلیش به فارسی
+• «رسمی کن» - بازنویسی رسمی متن
+• در گروه‌ها باید از پنل ← قابلیت‌ها ← ابزارهای متن فعال شوند
+
 📊 *استفاده:*
 • درخواست‌های نامحدود
 • بدون تأخیر بین درخواست‌ها
@@ -913,8 +1147,9 @@ func (r *CovoBot) handleHelpCommand(update tgbotapi.Update) tgbotapi.MessageConf
 
 💡 *نکات:*
 • برای پاسخ‌های بهتر، سوالات خود را دقیق مطرح کنید
+• با 👍/👎 زیر پاسخ‌ها نظر بدهید و با «🔄 پاسخ دیگر» پاسخ تازه بگیرید
 • موضوعات مختلف را برای جوک امتحان کنید
-• برای موسیقی، ابتدا /music بزنید، سپس ترجیحات خود را ریپلای کنید
+• برای موسیقی، ابتدا /music بزنید، سپس حس و حال خود را بنویسید («لغو» برای انصراف)
 • از /crs برای بررسی وضعیت بات استفاده کنید
 • در گروه‌ها از /covog برای راهنما استفاده کنید
 
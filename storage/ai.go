@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AIReply نگهداری پاسخ‌های هوش مصنوعی ارسال‌شده توسط ربات (برای ادامه گفتگو با ریپلای و ارزیابی کاربران)
+type AIReply struct {
+	ID        uint   `gorm:"primaryKey"`
+	ChatID    int64  `gorm:"index:idx_ai_reply_message"`
+	MessageID int    `gorm:"index:idx_ai_reply_message"`
+	UserID    int64  `gorm:"index"`
+	Command   string `gorm:"type:varchar(32)"`
+	// Input ورودی خام کاربر (سوال، موضوع جوک، حس و حال) برای ساخت دوباره پاسخ
+	Input    string `gorm:"type:text"`
+	Question string `gorm:"type:text"`
+	Answer   string `gorm:"type:text"`
+	// History تاریخچه گفتگوی ارسال‌شده همراه سوال (JSON) تا پاسخ دوباره در همان زمینه ساخته شود
+	History string `gorm:"type:text"`
+	Model   string `gorm:"type:varchar(128)"`
+	// PromptVersion نسخه قالب(های) پرامپت استفاده‌شده برای این پاسخ
+	PromptVersion string `gorm:"type:varchar(128);index"`
+	CreatedAt     time.Time
+}
+
+// AIUsageRecord مصرف توکن هر فراخوانی هوش مصنوعی
+type AIUsageRecord struct {
+	ID               uint   `gorm:"primaryKey"`
+	UserID           int64  `gorm:"index"`
+	GroupID          int64  `gorm:"index"` // 0: چت خصوصی
+	Command          string `gorm:"type:varchar(32)"`
+	Model            string `gorm:"type:varchar(128)"`
+	PromptTokens     int
+	CompletionTokens int
+	PromptVersion    string    `gorm:"type:varchar(128);index"`
+	CreatedAt        time.Time `gorm:"index"`
+}
+
+// AITokenUsage مجموع مصرف توکن یک کاربر یا گروه
+type AITokenUsage struct {
+	ID     int64
+	Tokens int64
+	Calls  int64
+}
+
+// SaveAIReply ثبت پیام پاسخ هوش مصنوعی
+func (m *MySQLStorage) SaveAIReply(reply *AIReply) error {
+	if reply.CreatedAt.IsZero() {
+		reply.CreatedAt = time.Now()
+	}
+	return m.db.Create(reply).Error
+}
+
+// GetAIReply returns the AI reply stored for a bot message, or nil if the message is not an AI answer
+func (m *MySQLStorage) GetAIReply(chatID int64, messageID int) (*AIReply, error) {
+	var reply AIReply
+	err := m.db.Where("chat_id = ? AND message_id = ?", chatID, messageID).
+		Order("id DESC").First(&reply).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// SaveAIUsage ثبت مصرف توکن یک فراخوانی
+func (m *MySQLStorage) SaveAIUsage(userID int64, groupID int64, command string, model string, promptTokens int, completionTokens int, promptVersion string) error {
+	rec := AIUsageRecord{
+		UserID:           userID,
+		GroupID:          groupID,
+		Command:          command,
+		Model:            model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		PromptVersion:    promptVersion,
+		CreatedAt:        time.Now(),
+	}
+	return m.db.Create(&rec).Error
+}
+
+// SumUserAITokens مجموع توکن‌های مصرفی کاربر از زمان since
+func (m *MySQLStorage) SumUserAITokens(userID int64, since time.Time) (int64, error) {
+	return m.sumAITokens(m.db.Where("user_id = ?", userID), since)
+}
+
+// SumGroupAITokens مجموع توکن‌های مصرفی گروه از زمان since
+func (m *MySQLStorage) SumGroupAITokens(groupID int64, since time.Time) (int64, error) {
+	return m.sumAITokens(m.db.Where("group_id = ?", groupID), since)
+}
+
+// SumGlobalAITokens مجموع کل توکن‌های مصرفی از زمان since
+func (m *MySQLStorage) SumGlobalAITokens(since time.Time) (int64, error) {
+	return m.sumAITokens(m.db, since)
+}
+
+func (m *MySQLStorage) sumAITokens(query *gorm.DB, since time.Time) (int64, error) {
+	var total int64
+	err := query.Model(&AIUsageRecord{}).
+		Where("created_at >= ?", since).
+		Select("COALESCE(SUM(prompt_tokens + completion_tokens), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// TopAIUsers returns the users with the highest token consumption since the given time
+func (m *MySQLStorage) TopAIUsers(since time.Time, limit int) ([]AITokenUsage, error) {
+	return m.topAIConsumers("user_id", since, limit)
+}
+
+// TopAIGroups returns the groups with the highest token consumption since the given time
+func (m *MySQLStorage) TopAIGroups(since time.Time, limit int) ([]AITokenUsage, error) {
+	return m.topAIConsumers("group_id", since, limit)
+}
+
+func (m *MySQLStorage) topAIConsumers(column string, since time.Time, limit int) ([]AITokenUsage, error) {
+	var results []AITokenUsage
+	err := m.db.Table("ai_usage_records").
+		Select(column+" as id, SUM(prompt_tokens + completion_tokens) as tokens, COUNT(*) as calls").
+		Where("created_at >= ? AND "+column+" <> 0", since).
+		Group(column).
+		Order("tokens DESC").
+		Limit(limit).
+		Scan(&results).Error
+	return results, err
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AIFeedback رأی 👍/👎 یک کاربر به یک پاسخ هوش مصنوعی؛
+// دستور، مدل و نسخه قالب پاسخ برای گزارش‌گیری کنار رأی ذخیره می‌شود
+type AIFeedback struct {
+	ID            uint   `gorm:"primaryKey"`
+	ReplyID       uint   `gorm:"uniqueIndex:idx_ai_feedback_vote"`
+	UserID        int64  `gorm:"uniqueIndex:idx_ai_feedback_vote"`
+	Command       string `gorm:"type:varchar(32);index"`
+	Model         string `gorm:"type:varchar(128)"`
+	PromptVersion string `gorm:"type:varchar(128);index"`
+	Vote          int    // 1: 👍، -1: 👎
+	CreatedAt     time.Time
+	UpdatedAt     time.Time `gorm:"index"`
+}
+
+// AIFeedbackStat مجموع رأی‌ها برای یک کلید (دستور/مدل، نسخه قالب یا پاسخ)
+type AIFeedbackStat struct {
+	Key   string
+	Model string
+	Up    int64
+	Down  int64
+}
+
+// AIWorstReply پاسخی که بیشترین رأی منفی را گرفته است
+type AIWorstReply struct {
+	ReplyID       uint
+	Command       string
+	Input         string
+	PromptVersion string
+	Up            int64
+	Down          int64
+}
+
+// ToggleAIFeedback ثبت رأی کاربر؛ رأی تکراری همان رأی را پس می‌گیرد و رأی مخالف جایگزین آن می‌شود.
+// خروجی: رأی نهایی کاربر (۰ = بدون رأی)
+func (m *MySQLStorage) ToggleAIFeedback(reply *AIReply, userID int64, vote int) (int, error) {
+	var existing AIFeedback
+	err := m.db.Where("reply_id = ? AND user_id = ?", reply.ID, userID).First(&existing).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		feedback := AIFeedback{
+			ReplyID:       reply.ID,
+			UserID:        userID,
+			Command:       reply.Command,
+			Model:         reply.Model,
+			PromptVersion: reply.PromptVersion,
+			Vote:          vote,
+		}
+		return vote, m.db.Create(&feedback).Error
+	case err != nil:
+		return 0, err
+	case existing.Vote == vote:
+		return 0, m.db.Delete(&existing).Error
+	default:
+		existing.Vote = vote
+		return vote, m.db.Save(&existing).Error
+	}
+}
+
+// CountAIFeedback تعداد 👍 و 👎 یک پاسخ
+func (m *MySQLStorage) CountAIFeedback(replyID uint) (up int64, down int64, err error) {
+	var stat AIFeedbackStat
+	err = m.db.Model(&AIFeedback{}).
+		Select("COALESCE(SUM(vote > 0), 0) as up, COALESCE(SUM(vote < 0), 0) as down").
+		Where("reply_id = ?", replyID).
+		Scan(&stat).Error
+	return stat.Up, stat.Down, err
+}
+
+// AIFeedbackByCommand مجموع رأی‌ها به تفکیک دستور و مدل از زمان since
+func (m *MySQLStorage) AIFeedbackByCommand(since time.Time) ([]AIFeedbackStat, error) {
+	var stats []AIFeedbackStat
+	err := m.db.Model(&AIFeedback{}).
+		Select("command as `key`, model, SUM(vote > 0) as up, SUM(vote < 0) as down").
+		Where("updated_at >= ?", since).
+		Group("command, model").
+		Order("command, model").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// WorstAIPromptVersions نسخه‌های قالب با کمترین نرخ رضایت (حداقل minVotes رأی)
+func (m *MySQLStorage) WorstAIPromptVersions(since time.Time, minVotes int, limit int) ([]AIFeedbackStat, error) {
+	var stats []AIFeedbackStat
+	err := m.db.Model(&AIFeedback{}).
+		Select("prompt_version as `key`, SUM(vote > 0) as up, SUM(vote < 0) as down").
+		Where("updated_at >= ? AND prompt_version <> ''", since).
+		Group("prompt_version").
+		Having("COUNT(*) >= ?", minVotes).
+		Order("SUM(vote > 0) / COUNT(*) ASC, COUNT(*) DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// WorstAIReplies پاسخ‌هایی با بیشترین اختلاف رأی منفی از زمان since
+func (m *MySQLStorage) WorstAIReplies(since time.Time, limit int) ([]AIWorstReply, error) {
+	var replies []AIWorstReply
+	err := m.db.Table("ai_feedbacks f").
+		Select("f.reply_id, r.command, r.input, r.prompt_version, SUM(f.vote > 0) as up, SUM(f.vote < 0) as down").
+		Joins("JOIN ai_replies r ON r.id = f.reply_id").
+		Where("f.updated_at >= ?", since).
+		Group("f.reply_id, r.command, r.input, r.prompt_version").
+		Having("SUM(f.vote < 0) > SUM(f.vote > 0)").
+		Order("SUM(f.vote < 0) - SUM(f.vote > 0) DESC").
+		Limit(limit).
+		Scan(&replies).Error
+	return replies, err
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AISession یک جلسه گفتگوی خصوصی کاربر با هوش مصنوعی
+type AISession struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int64  `gorm:"index"`
+	Title     string `gorm:"type:varchar(100)"`
+	Active    bool   `gorm:"index"`
+	Tokens    int    // مجموع توکن‌های مصرفی این جلسه
+	Messages  int    // تعداد پیام‌های ثبت‌شده
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// AISessionMessage یک پیام از تاریخچه جلسه (role: user یا assistant)
+type AISessionMessage struct {
+	ID        uint   `gorm:"primaryKey"`
+	SessionID uint   `gorm:"index"`
+	Role      string `gorm:"type:varchar(16)"`
+	Content   string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+// CreateAISession شروع جلسه جدید؛ جلسه فعال قبلی کاربر بسته می‌شود
+func (m *MySQLStorage) CreateAISession(userID int64) (*AISession, error) {
+	session := AISession{UserID: userID, Active: true}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&AISession{}).Where("user_id = ? AND active = ?", userID, true).
+			Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Create(&session).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetActiveAISession جلسه فعال کاربر (یا nil)
+func (m *MySQLStorage) GetActiveAISession(userID int64) (*AISession, error) {
+	var session AISession
+	err := m.db.Where("user_id = ? AND active = ?", userID, true).Order("id DESC").First(&session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ActivateAISession ادامه یک جلسه قبلی کاربر؛ false اگر جلسه متعلق به کاربر نباشد
+func (m *MySQLStorage) ActivateAISession(userID int64, sessionID uint) (bool, error) {
+	found := false
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&AISession{}).Where("id = ? AND user_id = ?", sessionID, userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		found = true
+		if err := tx.Model(&AISession{}).Where("user_id = ? AND active = ?", userID, true).
+			Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&AISession{}).Where("id = ?", sessionID).Update("active", true).Error
+	})
+	return found, err
+}
+
+// EndAISession بستن جلسه فعال کاربر
+func (m *MySQLStorage) EndAISession(userID int64) error {
+	return m.db.Model(&AISession{}).Where("user_id = ? AND active = ?", userID, true).
+		Update("active", false).Error
+}
+
+// ListAISessions آخرین جلسه‌های کاربر، جدیدترین اول
+func (m *MySQLStorage) ListAISessions(userID int64, limit int) ([]AISession, error) {
+	var sessions []AISession
+	err := m.db.Where("user_id = ? AND messages > 0", userID).Order("updated_at DESC").Limit(limit).Find(&sessions).Error
+	return sessions, err
+}
+
+// AddAISessionMessage ثبت پیام در تاریخچه جلسه؛ عنوان جلسه از اولین پیام کاربر گرفته می‌شود
+func (m *MySQLStorage) AddAISessionMessage(sessionID uint, role string, content string) error {
+	msg := AISessionMessage{SessionID: sessionID, Role: role, Content: content, CreatedAt: time.Now()}
+	if err := m.db.Create(&msg).Error; err != nil {
+		return err
+	}
+	if role == "user" {
+		if err := m.db.Model(&AISession{}).Where("id = ? AND title = ?", sessionID, "").
+			Update("title", sessionTitle(content)).Error; err != nil {
+			return err
+		}
+	}
+	return m.db.Model(&AISession{}).Where("id = ?", sessionID).
+		Update("messages", gorm.Expr("messages + 1")).Error
+}
+
+// RecentAISessionMessages آخرین limit پیام جلسه به ترتیب زمانی
+func (m *MySQLStorage) RecentAISessionMessages(sessionID uint, limit int) ([]AISessionMessage, error) {
+	var messages []AISessionMessage
+	err := m.db.Where("session_id = ?", sessionID).Order("id DESC").Limit(limit).Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// AddAISessionTokens افزودن توکن‌های مصرفی به جلسه
+func (m *MySQLStorage) AddAISessionTokens(sessionID uint, tokens int) error {
+	return m.db.Model(&AISession{}).Where("id = ?", sessionID).
+		Update("tokens", gorm.Expr("tokens + ?", tokens)).Error
+}
+
+// sessionTitle عنوان کوتاه جلسه از متن اولین پیام
+func sessionTitle(text string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) > 40 {
+		return string(runes[:40]) + "…"
+	}
+	return string(runes)
+}
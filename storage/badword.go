@@ -0,0 +1,39 @@
+package storage
+
+import "time"
+
+// GroupBadWord تغییر فهرست فحش‌ها برای یک گروه: افزودن کلمه یا مستثنی کردن کلمه‌ای از فهرست عمومی
+type GroupBadWord struct {
+	ID        uint   `gorm:"primaryKey"`
+	GroupID   int64  `gorm:"uniqueIndex:idx_group_bad_word"`
+	Word      string `gorm:"type:varchar(128);uniqueIndex:idx_group_bad_word"` // شکل نرمال‌شده کلمه
+	Excluded  bool   // true = کلمه فهرست عمومی در این گروه فحش حساب نشود
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+// SetGroupBadWord افزودن کلمه به فهرست گروه یا مستثنی کردن آن (جایگزین رکورد قبلی همان کلمه)
+func (m *MySQLStorage) SetGroupBadWord(groupID int64, word string, excluded bool, createdBy int64) error {
+	if err := m.DeleteGroupBadWord(groupID, word); err != nil {
+		return err
+	}
+	return m.db.Create(&GroupBadWord{
+		GroupID:   groupID,
+		Word:      word,
+		Excluded:  excluded,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// DeleteGroupBadWord حذف تغییر ثبت‌شده یک کلمه در گروه
+func (m *MySQLStorage) DeleteGroupBadWord(groupID int64, word string) error {
+	return m.db.Where("group_id = ? AND word = ?", groupID, word).Delete(&GroupBadWord{}).Error
+}
+
+// ListGroupBadWords همه تغییرات فهرست فحش گروه
+func (m *MySQLStorage) ListGroupBadWords(groupID int64) ([]GroupBadWord, error) {
+	var words []GroupBadWord
+	err := m.db.Where("group_id = ?", groupID).Order("word").Find(&words).Error
+	return words, err
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PendingCaptcha کپچای حل‌نشده عضو تازه‌وارد؛ در دیتابیس می‌ماند تا پس از ری‌استارت هم مهلت آن بررسی شود
+type PendingCaptcha struct {
+	ID               uint  `gorm:"primaryKey"`
+	GroupID          int64 `gorm:"uniqueIndex:idx_pending_captcha"`
+	UserID           int64 `gorm:"uniqueIndex:idx_pending_captcha"`
+	JoinMessageID    int
+	CaptchaMessageID int
+	Kind             string    `gorm:"type:varchar(16)"`
+	Answer           string    `gorm:"type:varchar(32)"`
+	ExpiresAt        time.Time `gorm:"index"`
+	CreatedAt        time.Time
+}
+
+// SavePendingCaptcha ثبت کپچای جدید؛ کپچای قبلی همان کاربر در گروه جایگزین می‌شود
+func (m *MySQLStorage) SavePendingCaptcha(captcha *PendingCaptcha) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ? AND user_id = ?", captcha.GroupID, captcha.UserID).Delete(&PendingCaptcha{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(captcha).Error
+	})
+}
+
+// GetPendingCaptcha کپچای در انتظار کاربر؛ nil اگر وجود ندارد
+func (m *MySQLStorage) GetPendingCaptcha(groupID int64, userID int64) (*PendingCaptcha, error) {
+	var captcha PendingCaptcha
+	err := m.db.Where("group_id = ? AND user_id = ?", groupID, userID).First(&captcha).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &captcha, nil
+}
+
+// DeletePendingCaptcha حذف کپچا؛ false اگر قبلاً (مثلاً توسط زمان‌بند) حذف شده بود
+func (m *MySQLStorage) DeletePendingCaptcha(id uint) (bool, error) {
+	result := m.db.Delete(&PendingCaptcha{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ExpiredCaptchas کپچاهایی که مهلتشان تمام شده است
+func (m *MySQLStorage) ExpiredCaptchas(now time.Time) ([]PendingCaptcha, error) {
+	var captchas []PendingCaptcha
+	err := m.db.Where("expires_at <= ?", now).Find(&captchas).Error
+	return captchas, err
+}
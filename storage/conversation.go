@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConversationState مرحله فعلی یک گفتگوی چندمرحله‌ای برای هر کاربر در هر چت
+type ConversationState struct {
+	ChatID    int64     `gorm:"primaryKey"`
+	UserID    int64     `gorm:"primaryKey"`
+	Flow      string    `gorm:"type:varchar(50)"`
+	Step      string    `gorm:"type:varchar(50)"`
+	Data      string    `gorm:"type:text"` // JSON
+	ExpiresAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+}
+
+// SaveConversationState ایجاد یا به‌روزرسانی وضعیت گفتگو
+func (m *MySQLStorage) SaveConversationState(state *ConversationState) error {
+	return m.db.Save(state).Error
+}
+
+// GetConversationState وضعیت گفتگوی فعال (یا nil اگر وجود ندارد یا منقضی شده)
+func (m *MySQLStorage) GetConversationState(chatID int64, userID int64) (*ConversationState, error) {
+	var state ConversationState
+	err := m.db.Where("chat_id = ? AND user_id = ? AND expires_at > ?", chatID, userID, time.Now()).First(&state).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListActiveConversationStates همه گفتگوهای منقضی‌نشده (برای بارگذاری در حافظه هنگام راه‌اندازی)
+func (m *MySQLStorage) ListActiveConversationStates() ([]ConversationState, error) {
+	var states []ConversationState
+	err := m.db.Select("chat_id", "user_id", "expires_at").Where("expires_at > ?", time.Now()).Find(&states).Error
+	return states, err
+}
+
+// DeleteConversationState پایان گفتگو
+func (m *MySQLStorage) DeleteConversationState(chatID int64, userID int64) error {
+	return m.db.Where("chat_id = ? AND user_id = ?", chatID, userID).Delete(&ConversationState{}).Error
+}
+
+// DeleteExpiredConversationStates پاکسازی گفتگوهای منقضی‌شده
+func (m *MySQLStorage) DeleteExpiredConversationStates() error {
+	return m.db.Where("expires_at <= ?", time.Now()).Delete(&ConversationState{}).Error
+}
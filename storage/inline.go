@@ -0,0 +1,39 @@
+package storage
+
+import "time"
+
+// InlineChoice نتیجه‌ای که کاربر در حالت inline انتخاب کرده (برای آمار)
+type InlineChoice struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int64     `gorm:"index"`
+	Kind      string    `gorm:"type:varchar(16);index"` // fal, joke, ask
+	Query     string    `gorm:"type:varchar(255)"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// InlineKindCount تعداد انتخاب‌های یک نوع نتیجه
+type InlineKindCount struct {
+	Kind  string
+	Count int64
+}
+
+// SaveInlineChoice ثبت انتخاب نتیجه inline
+func (m *MySQLStorage) SaveInlineChoice(userID int64, kind string, query string) error {
+	if runes := []rune(query); len(runes) > 255 {
+		query = string(runes[:255])
+	}
+	choice := InlineChoice{UserID: userID, Kind: kind, Query: query, CreatedAt: time.Now()}
+	return m.db.Create(&choice).Error
+}
+
+// CountInlineChoices تعداد انتخاب‌ها به تفکیک نوع از زمان since
+func (m *MySQLStorage) CountInlineChoices(since time.Time) ([]InlineKindCount, error) {
+	var counts []InlineKindCount
+	err := m.db.Model(&InlineChoice{}).
+		Select("kind, COUNT(*) as count").
+		Where("created_at >= ?", since).
+		Group("kind").
+		Order("count DESC").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,42 @@
+package storage
+
+import "time"
+
+// LinkAllow دامنه یا @نام‌کاربری مجاز در یک گروه که قفل لینک آن را حذف نمی‌کند
+type LinkAllow struct {
+	ID        uint   `gorm:"primaryKey"`
+	GroupID   int64  `gorm:"uniqueIndex:idx_link_allow"`
+	Value     string `gorm:"type:varchar(255);uniqueIndex:idx_link_allow"` // example.com یا @channel (حروف کوچک)
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+// AddLinkAllow افزودن دامنه یا @نام به فهرست مجاز گروه؛ false اگر از قبل وجود داشت
+func (m *MySQLStorage) AddLinkAllow(groupID int64, value string, createdBy int64) (bool, error) {
+	var count int64
+	if err := m.db.Model(&LinkAllow{}).Where("group_id = ? AND value = ?", groupID, value).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	return true, m.db.Create(&LinkAllow{
+		GroupID:   groupID,
+		Value:     value,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// DeleteLinkAllow حذف از فهرست مجاز گروه؛ false اگر وجود نداشت
+func (m *MySQLStorage) DeleteLinkAllow(groupID int64, value string) (bool, error) {
+	result := m.db.Where("group_id = ? AND value = ?", groupID, value).Delete(&LinkAllow{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListLinkAllows فهرست مجاز گروه
+func (m *MySQLStorage) ListLinkAllows(groupID int64) ([]string, error) {
+	var values []string
+	err := m.db.Model(&LinkAllow{}).Where("group_id = ?", groupID).Order("value").Pluck("value", &values).Error
+	return values, err
+}
@@ -0,0 +1,56 @@
+package storage
+
+import "time"
+
+// ModerationLog یک اقدام مدیریتی (دستی یا خودکار) در گروه
+type ModerationLog struct {
+	ID         uint  `gorm:"primaryKey"`
+	GroupID    int64 `gorm:"index:idx_moderation_log_group"`
+	ActorID    int64 // صفر = اقدام خودکار ربات
+	ActorName  string
+	TargetID   int64  `gorm:"index"`
+	TargetName string `gorm:"index;type:varchar(128)"`
+	Action     string `gorm:"type:varchar(32);index"` // ban, unban, mute, unmute, delete, purge, warn, unwarn, kick
+	Reason     string
+	Duration   int64     // ثانیه؛ صفر = نامحدود یا بی‌معنی
+	Excerpt    string    `gorm:"type:text"`
+	Source     string    `gorm:"type:varchar(32)"` // manual, lock:link, lock:badword, antiflood, captcha, warn_ladder
+	CreatedAt  time.Time `gorm:"index:idx_moderation_log_group"`
+}
+
+// ModerationLogFilter شرط‌های جستجو در گزارش مدیریت؛ مقدار صفر یعنی بدون شرط
+type ModerationLogFilter struct {
+	TargetID   int64
+	TargetName string
+	Action     string
+	Limit      int
+}
+
+// SaveModerationLog ثبت اقدام مدیریتی
+func (m *MySQLStorage) SaveModerationLog(entry *ModerationLog) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return m.db.Create(entry).Error
+}
+
+// RecentModerationLogs آخرین اقدامات مدیریتی گروه، جدیدترین اول
+func (m *MySQLStorage) RecentModerationLogs(groupID int64, filter ModerationLogFilter) ([]ModerationLog, error) {
+	query := m.db.Where("group_id = ?", groupID)
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.TargetName != "" {
+		query = query.Where("target_name = ?", filter.TargetName)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	var logs []ModerationLog
+	err := query.Order("created_at DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
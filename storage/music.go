@@ -0,0 +1,34 @@
+package storage
+
+import "time"
+
+// MusicRecommendation آهنگی که به کاربر پیشنهاد شده (برای جلوگیری از پیشنهاد تکراری)
+type MusicRecommendation struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int64  `gorm:"index:idx_music_user_track"`
+	TrackKey  string `gorm:"type:varchar(255);index:idx_music_user_track"` // artist|title نرمال‌شده
+	Artist    string `gorm:"type:varchar(255)"`
+	Title     string `gorm:"type:varchar(255)"`
+	Year      int
+	Mood      string `gorm:"type:varchar(100)"`
+	CreatedAt time.Time
+}
+
+// SaveMusicRecommendations ثبت آهنگ‌های پیشنهادشده به کاربر
+func (m *MySQLStorage) SaveMusicRecommendations(recs []MusicRecommendation) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range recs {
+		recs[i].CreatedAt = now
+	}
+	return m.db.Create(&recs).Error
+}
+
+// RecentMusicRecommendations آخرین آهنگ‌های پیشنهادشده به کاربر، جدیدترین اول
+func (m *MySQLStorage) RecentMusicRecommendations(userID int64, limit int) ([]MusicRecommendation, error) {
+	var recs []MusicRecommendation
+	err := m.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&recs).Error
+	return recs, err
+}
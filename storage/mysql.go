@@ -18,12 +18,14 @@ type UserUsage struct {
 }
 
 type GroupMessage struct {
-	ID        uint  `gorm:"primaryKey"`
-	GroupID   int64 `gorm:"index:idx_group_timestamp"`
-	UserID    int64
-	Username  string
-	Message   string
-	Timestamp time.Time `gorm:"index:idx_group_timestamp"`
+	ID          uint   `gorm:"primaryKey"`
+	GroupID     int64  `gorm:"index:idx_group_timestamp;index:idx_group_message_user"`
+	UserID      int64  `gorm:"index:idx_group_message_user"`
+	MessageID   int    // شناسه پیام در تلگرام (برای پاکسازی پیام‌های یک کاربر)
+	ContentType string `gorm:"type:varchar(16)"` // text, photo, sticker, ...
+	Username    string
+	Message     string
+	Timestamp   time.Time `gorm:"index:idx_group_timestamp"`
 }
 
 type GroupMember struct {
@@ -38,13 +40,21 @@ type FeatureSetting struct {
 	Enabled     bool
 }
 
+// GroupSetting نگهداری تنظیمات متنی هر گروه (مثل شخصیت هوش مصنوعی)
+type GroupSetting struct {
+	GroupID    int64  `gorm:"primaryKey"`
+	SettingKey string `gorm:"primaryKey;type:varchar(100)"`
+	Value      string `gorm:"type:text"`
+}
+
 // DailyChallenge نگهداری وضعیت چالش روزانه در هر گروه
 type DailyChallenge struct {
 	ID         uint      `gorm:"primaryKey"`
 	GroupID    int64     `gorm:"index"`
 	MessageID  int       `gorm:"index"`
-	Proverb    string    `gorm:"type:text"`
+	Proverb    string    `gorm:"type:text"` // پاسخ صحیح (ضرب‌المثل، فیلم، آهنگ یا شهر)
 	Emojis     string    `gorm:"type:text"`
+	Category   string    `gorm:"type:varchar(32)"`
 	Answered   bool      `gorm:"default:false"`
 	WinnerID   int64     `gorm:"index"`
 	WinnerName string    `gorm:"type:varchar(255)"`
@@ -100,7 +110,7 @@ func NewMySQLStorage(host, port, user, password, dbname string) (*MySQLStorage,
 	}
 
 	// Auto Migrate the schemas
-	if err := db.AutoMigrate(&UserUsage{}, &GroupMessage{}, &GroupMember{}, &FeatureSetting{}, &RequiredChannel{}, &UserOnboarding{}, &BotChannel{}, &DailyChallenge{}); err != nil {
+	if err := db.AutoMigrate(&UserUsage{}, &GroupMessage{}, &GroupMember{}, &FeatureSetting{}, &GroupSetting{}, &RequiredChannel{}, &UserOnboarding{}, &BotChannel{}, &DailyChallenge{}, &AIReply{}, &AIUsageRecord{}, &AISession{}, &AISessionMessage{}, &MusicRecommendation{}, &ConversationState{}, &InlineChoice{}, &PromptOverride{}, &AIFeedback{}, &Warning{}, &GroupBadWord{}, &LinkAllow{}, &PendingCaptcha{}, &ModerationLog{}); err != nil {
 		return nil, fmt.Errorf("error migrating database: %v", err)
 	}
 
@@ -193,18 +203,20 @@ func (m *MySQLStorage) IncrementUserUsage(userID int64) error {
 }
 
 // Group Messages Methods
-func (m *MySQLStorage) AddGroupMessage(groupID int64, userID int64, username, message string) error {
+func (m *MySQLStorage) AddGroupMessage(groupID int64, userID int64, messageID int, username, contentType, message string) error {
 	// Clean old messages first
 	if err := m.cleanOldMessages(groupID); err != nil {
 		log.Printf("Error cleaning old messages: %v", err)
 	}
 
 	msg := GroupMessage{
-		GroupID:   groupID,
-		UserID:    userID,
-		Username:  username,
-		Message:   message,
-		Timestamp: time.Now(),
+		GroupID:     groupID,
+		UserID:      userID,
+		MessageID:   messageID,
+		ContentType: contentType,
+		Username:    username,
+		Message:     message,
+		Timestamp:   time.Now(),
 	}
 
 	return m.db.Create(&msg).Error
@@ -233,6 +245,30 @@ func (m *MySQLStorage) ClearGroupMessages(groupID int64) error {
 	return m.db.Where("group_id = ?", groupID).Delete(&GroupMessage{}).Error
 }
 
+// RecentUserMessageIDs شناسه آخرین پیام‌های ثبت‌شده یک کاربر، جدیدترین اول؛
+// since صفر یعنی بدون محدودیت زمانی و limit صفر یعنی بدون محدودیت تعداد
+func (m *MySQLStorage) RecentUserMessageIDs(groupID int64, userID int64, since time.Time, limit int) ([]int, error) {
+	query := m.db.Model(&GroupMessage{}).
+		Where("group_id = ? AND user_id = ? AND message_id > 0", groupID, userID)
+	if !since.IsZero() {
+		query = query.Where("timestamp >= ?", since)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var ids []int
+	err := query.Order("message_id DESC").Pluck("message_id", &ids).Error
+	return ids, err
+}
+
+// DeleteGroupMessagesByID حذف پیام‌های پاک‌شده از ثبت پیام‌ها تا در آمار و خلاصه حساب نشوند
+func (m *MySQLStorage) DeleteGroupMessagesByID(groupID int64, messageIDs []int) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	return m.db.Where("group_id = ? AND message_id IN ?", groupID, messageIDs).Delete(&GroupMessage{}).Error
+}
+
 // Stats and Analytics (24h)
 // UserMessageCount holds aggregated count of messages for a user in last 24 hours
 type UserMessageCount struct {
@@ -306,6 +342,39 @@ func (m *MySQLStorage) SetFeatureEnabled(chatID int64, feature string, enabled b
 	return m.db.Save(&setting).Error
 }
 
+// Group Settings Methods
+
+// GetGroupSetting returns the stored value of a group setting, or "" if it is not set
+func (m *MySQLStorage) GetGroupSetting(chatID int64, key string) (string, error) {
+	var setting GroupSetting
+	err := m.db.Where("group_id = ? AND setting_key = ?", chatID, key).
+		First(&setting).Error
+
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return setting.Value, nil
+}
+
+func (m *MySQLStorage) SetGroupSetting(chatID int64, key string, value string) error {
+	setting := GroupSetting{
+		GroupID:    chatID,
+		SettingKey: key,
+		Value:      value,
+	}
+
+	return m.db.Save(&setting).Error
+}
+
+func (m *MySQLStorage) DeleteGroupSetting(chatID int64, key string) error {
+	return m.db.Where("group_id = ? AND setting_key = ?", chatID, key).
+		Delete(&GroupSetting{}).Error
+}
+
 // GetEnabledGroupsForFeature returns all group IDs that have a specific feature enabled
 func (m *MySQLStorage) GetEnabledGroupsForFeature(feature string) ([]int64, error) {
 	var settings []FeatureSetting
@@ -320,12 +389,13 @@ func (m *MySQLStorage) GetEnabledGroupsForFeature(feature string) ([]int64, erro
 }
 
 // CreateDailyChallenge inserts a new daily challenge row for a group
-func (m *MySQLStorage) CreateDailyChallenge(groupID int64, messageID int, proverb string, emojis string) error {
+func (m *MySQLStorage) CreateDailyChallenge(groupID int64, messageID int, category string, proverb string, emojis string) error {
 	dc := DailyChallenge{
 		GroupID:   groupID,
 		MessageID: messageID,
 		Proverb:   proverb,
 		Emojis:    emojis,
+		Category:  category,
 		Answered:  false,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -348,6 +418,13 @@ func (m *MySQLStorage) GetActiveChallengeForGroup(groupID int64) (*DailyChalleng
 	return &dc, nil
 }
 
+// RecentChallengeAnswers returns the answers of the latest challenges across all groups (for dedupe)
+func (m *MySQLStorage) RecentChallengeAnswers(limit int) ([]string, error) {
+	var answers []string
+	err := m.db.Model(&DailyChallenge{}).Order("id DESC").Limit(limit).Pluck("proverb", &answers).Error
+	return answers, err
+}
+
 // TryMarkChallengeAnswered marks challenge answered if not already answered; returns true if succeeded
 func (m *MySQLStorage) TryMarkChallengeAnswered(id uint, winnerID int64, winnerName string) (bool, error) {
 	// optimistic update where answered=false
@@ -416,6 +493,19 @@ func (m *MySQLStorage) GetGroupMembers(groupID int64) ([]GroupMember, error) {
 	return members, err
 }
 
+// FindGroupMemberByName عضو ثبت‌شده گروه با نام ذخیره‌شده (مثلاً @username)؛ nil اگر پیدا نشد
+func (m *MySQLStorage) FindGroupMemberByName(groupID int64, name string) (*GroupMember, error) {
+	var member GroupMember
+	err := m.db.Where("group_id = ? AND name = ?", groupID, name).First(&member).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
 // Close database connection
 // ساختار برای لیست کاربران
 type UserInfo struct {
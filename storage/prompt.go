@@ -0,0 +1,33 @@
+package storage
+
+import "time"
+
+// PromptOverride متن قالب پرامپتی که ادمین ربات به جای فایل پیش‌فرض تنظیم کرده است
+type PromptOverride struct {
+	Name      string `gorm:"primaryKey;type:varchar(64)"`
+	Body      string `gorm:"type:text"`
+	UpdatedBy int64
+	UpdatedAt time.Time
+}
+
+// SavePromptOverride ثبت یا جایگزینی قالب سفارشی
+func (m *MySQLStorage) SavePromptOverride(name string, body string, updatedBy int64) error {
+	return m.db.Save(&PromptOverride{
+		Name:      name,
+		Body:      body,
+		UpdatedBy: updatedBy,
+		UpdatedAt: time.Now(),
+	}).Error
+}
+
+// ListPromptOverrides همه قالب‌های سفارشی
+func (m *MySQLStorage) ListPromptOverrides() ([]PromptOverride, error) {
+	var overrides []PromptOverride
+	err := m.db.Order("name").Find(&overrides).Error
+	return overrides, err
+}
+
+// DeletePromptOverride حذف قالب سفارشی و بازگشت به فایل پیش‌فرض
+func (m *MySQLStorage) DeletePromptOverride(name string) error {
+	return m.db.Where("name = ?", name).Delete(&PromptOverride{}).Error
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"time"
+)
+
+// Warning اخطار ثبت‌شده برای یک کاربر در یک گروه؛ اخطارهای منقضی‌شده در شمارش پله‌ها حساب نمی‌شوند
+type Warning struct {
+	ID        uint  `gorm:"primaryKey"`
+	GroupID   int64 `gorm:"index:idx_warning_member"`
+	UserID    int64 `gorm:"index:idx_warning_member"`
+	AdminID   int64 // صفر = اخطار خودکار قفل‌ها
+	Reason    string
+	CreatedAt time.Time
+	ExpiresAt *time.Time `gorm:"index"` // nil = بدون انقضا
+}
+
+// AddWarning ثبت اخطار جدید؛ expiry صفر یعنی اخطار منقضی نمی‌شود
+func (m *MySQLStorage) AddWarning(groupID int64, userID int64, adminID int64, reason string, expiry time.Duration) (*Warning, error) {
+	warning := &Warning{
+		GroupID:   groupID,
+		UserID:    userID,
+		AdminID:   adminID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if expiry > 0 {
+		expiresAt := warning.CreatedAt.Add(expiry)
+		warning.ExpiresAt = &expiresAt
+	}
+	if err := m.db.Create(warning).Error; err != nil {
+		return nil, err
+	}
+	return warning, nil
+}
+
+// ActiveWarnings اخطارهای منقضی‌نشده کاربر در گروه به ترتیب ثبت
+func (m *MySQLStorage) ActiveWarnings(groupID int64, userID int64) ([]Warning, error) {
+	var warnings []Warning
+	err := m.db.Where("group_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)", groupID, userID, time.Now()).
+		Order("created_at").
+		Find(&warnings).Error
+	return warnings, err
+}
+
+// CountActiveWarnings تعداد اخطارهای منقضی‌نشده کاربر در گروه
+func (m *MySQLStorage) CountActiveWarnings(groupID int64, userID int64) (int64, error) {
+	var count int64
+	err := m.db.Model(&Warning{}).
+		Where("group_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)", groupID, userID, time.Now()).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteLastWarning حذف آخرین اخطار فعال کاربر؛ false اگر اخطار منقضی‌نشده‌ای نبود
+func (m *MySQLStorage) DeleteLastWarning(groupID int64, userID int64) (bool, error) {
+	var warning Warning
+	result := m.db.Where("group_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)", groupID, userID, time.Now()).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&warning)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	return true, m.db.Delete(&warning).Error
+}
+
+// DeleteWarnings حذف همه اخطارهای کاربر در گروه؛ خروجی: تعداد حذف‌شده
+func (m *MySQLStorage) DeleteWarnings(groupID int64, userID int64) (int64, error) {
+	result := m.db.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&Warning{})
+	return result.RowsAffected, result.Error
+}